@@ -3,12 +3,20 @@ package backendconfig
 import (
 	"encoding/json"
 	"fmt"
-	"reflect"
-	"strings"
+	"time"
 )
 
-// BackendConfigAdmin is container object to expose admin functions
-type BackendConfigAdmin struct{}
+// BackendConfigAdmin is container object to expose admin functions. The zero value reports on the instance created by Setup.
+type BackendConfigAdmin struct {
+	instance *Instance
+}
+
+func (bca *BackendConfigAdmin) backendConfig() *Instance {
+	if bca.instance != nil {
+		return bca.instance
+	}
+	return defaultInstance
+}
 
 // RoutingConfig reports current backend config and process config after masking secret fields
 func (bca *BackendConfigAdmin) RoutingConfig(filterProcessor bool, reply *string) (err error) {
@@ -20,11 +28,12 @@ func (bca *BackendConfigAdmin) RoutingConfig(filterProcessor bool, reply *string
 		}
 	}()
 
-	curSourceJSONLock.RLock()
-	defer curSourceJSONLock.RUnlock()
-	outputJSON := curSourceJSON
+	instance := bca.backendConfig()
+	instance.curSourceJSONLock.RLock()
+	defer instance.curSourceJSONLock.RUnlock()
+	outputJSON := instance.curSourceJSON
 	if filterProcessor {
-		outputJSON = filterProcessorEnabledDestinations(outputJSON)
+		outputJSON, _, _ = processConfig(outputJSON, instance.curScheduleLocations, time.Now())
 	}
 
 	outputObj := make([]interface{}, 0)
@@ -32,53 +41,32 @@ func (bca *BackendConfigAdmin) RoutingConfig(filterProcessor bool, reply *string
 	for _, source := range outputJSON.Sources {
 		destinations := make([]interface{}, 0)
 		for _, destination := range source.Destinations {
-			destinationConfigCopy := make(map[string]interface{})
-			for k, v := range destination.Config {
-				destinationConfigCopy[k] = v
-			}
-
-			//Mask secret config fields by replacing latter 2/3rd of the field with 'x's
-			destinationSecretKeys, ok := destination.DestinationDefinition.Config["secretKeys"]
-			if !ok {
-				destinationSecretKeys = []interface{}{}
-			}
-
-			rt := reflect.TypeOf(destinationSecretKeys)
-			switch rt.Kind() {
-			case reflect.Array:
-			case reflect.Slice:
-			default:
-				return fmt.Errorf("secretKeys field of destination definition config is not an array. Destination definition name: %s", destination.DestinationDefinition.DisplayName)
-			}
-
-			for _, k := range destinationSecretKeys.([]interface{}) {
-				secretKey := k.(string)
-				secret, ok := destinationConfigCopy[secretKey]
-				if ok {
-					switch v := secret.(type) {
-					case string:
-						s := secret.(string)
-						mask := strings.Repeat("x", (len(s)*2)/3)
-						destinationConfigCopy[secretKey] = s[0:len(s)-len(mask)] + mask
-					default:
-						return fmt.Errorf("I don't know how to handle secret config field of type %T", v)
-					}
-				}
+			destinationConfigCopy, err := instance.redactor.RedactDestinationConfig(destination)
+			if err != nil {
+				return err
 			}
 
-			destinations = append(destinations, map[string]interface{}{
+			destinationObj := map[string]interface{}{
 				"name":              destination.Name,
 				"enabled":           destination.Enabled,
 				"processor-enabled": destination.IsProcessorEnabled,
 				"id":                destination.ID,
 				"config":            destinationConfigCopy,
 				"type":              destination.DestinationDefinition.DisplayName,
-			})
+			}
+			if overrides, ok := instance.curConfigOverlay[destination.ID]; ok {
+				destinationObj["overlay-overrides"] = overrides
+			}
+			destinations = append(destinations, destinationObj)
+		}
+		sourceConfigCopy, err := instance.redactor.RedactSourceConfig(source)
+		if err != nil {
+			return err
 		}
 		outputObj = append(outputObj, map[string]interface{}{
 			"name":         source.Name,
-			"config":       source.Config,
-			"writekey":     source.WriteKey,
+			"config":       sourceConfigCopy,
+			"writekey":     instance.redactor.RedactWriteKey(source.WriteKey),
 			"id":           source.ID,
 			"enabled":      source.Enabled,
 			"destinations": destinations,
@@ -90,3 +78,114 @@ func (bca *BackendConfigAdmin) RoutingConfig(filterProcessor bool, reply *string
 	*reply = string(formattedOutput)
 	return err
 }
+
+// CircuitBreakers reports the state of the circuit breakers guarding config and regulation fetches
+func (bca *BackendConfigAdmin) CircuitBreakers(noArgs struct{}, reply *string) (err error) {
+	instance := bca.backendConfig()
+	breakers := make(map[string]CircuitBreakerStatus)
+	for _, cb := range []*circuitBreaker{instance.configCircuitBreaker, instance.regulationsCircuitBreaker} {
+		if cb != nil {
+			breakers[cb.name] = cb.status()
+		}
+	}
+
+	formattedOutput, err := json.MarshalIndent(breakers, "", "  ")
+	*reply = string(formattedOutput)
+	return err
+}
+
+// Endpoints reports the health of the configured config backend URLs
+func (bca *BackendConfigAdmin) Endpoints(noArgs struct{}, reply *string) (err error) {
+	formattedOutput, err := json.MarshalIndent(bca.backendConfig().endpoints.status(), "", "  ")
+	*reply = string(formattedOutput)
+	return err
+}
+
+// ConfigVersion reports the content hash of the current config
+func (bca *BackendConfigAdmin) ConfigVersion(noArgs struct{}, reply *string) (err error) {
+	*reply = bca.backendConfig().GetConfigVersion()
+	return nil
+}
+
+// BackendConfigStatusT is reported by the Status admin function
+type BackendConfigStatusT struct {
+	LastSync             string      `json:"lastSync"`
+	LastRegulationSync   string      `json:"lastRegulationSync"`
+	ConfigVersion        string      `json:"configVersion"`
+	PollingEnabled       bool        `json:"pollingEnabled"`
+	LastConfigError      *SyncErrorT `json:"lastConfigError,omitempty"`
+	LastRegulationsError *SyncErrorT `json:"lastRegulationsError,omitempty"`
+}
+
+// Status reports when config and regulations were last synced, the current config version and the last sync errors
+func (bca *BackendConfigAdmin) Status(noArgs struct{}, reply *string) (err error) {
+	instance := bca.backendConfig()
+	status := BackendConfigStatusT{ConfigVersion: instance.GetConfigVersion()}
+	status.LastSync, status.LastRegulationSync = instance.GetLastSync()
+
+	poller := instance.poller
+	poller.lock.RLock()
+	status.PollingEnabled = poller.pollingEnabled
+	status.LastConfigError = poller.lastConfigError
+	status.LastRegulationsError = poller.lastRegulationsError
+	poller.lock.RUnlock()
+
+	formattedOutput, err := json.MarshalIndent(status, "", "  ")
+	*reply = string(formattedOutput)
+	return err
+}
+
+// RefreshConfig makes the config poller fetch config immediately, even if polling is disabled
+func (bca *BackendConfigAdmin) RefreshConfig(noArgs struct{}, reply *string) (err error) {
+	requestRefresh(bca.backendConfig().poller.configRefreshCh)
+	*reply = "Config refresh requested"
+	return nil
+}
+
+// RefreshRegulations makes the regulations poller fetch regulations immediately, even if polling is disabled
+func (bca *BackendConfigAdmin) RefreshRegulations(noArgs struct{}, reply *string) (err error) {
+	requestRefresh(bca.backendConfig().poller.regulationsRefreshCh)
+	*reply = "Regulations refresh requested"
+	return nil
+}
+
+// SetPolling turns periodic config and regulations polling on or off. Refreshes requested through admin are still served while it is off.
+func (bca *BackendConfigAdmin) SetPolling(enabled bool, reply *string) (err error) {
+	bca.backendConfig().poller.setPollingEnabled(enabled)
+	*reply = fmt.Sprintf("Polling enabled: %v", enabled)
+	return nil
+}
+
+// Regulations reports the current regulations along with their counts per regulation type
+func (bca *BackendConfigAdmin) Regulations(noArgs struct{}, reply *string) (err error) {
+	curRegulationJSON := bca.backendConfig().GetCurrentRegulations()
+
+	countsByType := make(map[string]int)
+	for _, regulation := range curRegulationJSON.WorkspaceRegulations {
+		countsByType[string(regulation.RegulationType)]++
+	}
+	for _, regulation := range curRegulationJSON.SourceRegulations {
+		countsByType[string(regulation.RegulationType)]++
+	}
+
+	formattedOutput, err := json.MarshalIndent(map[string]interface{}{
+		"workspaceRegulationsCount": len(curRegulationJSON.WorkspaceRegulations),
+		"sourceRegulationsCount":    len(curRegulationJSON.SourceRegulations),
+		"countsByType":              countsByType,
+		"workspaceRegulations":      curRegulationJSON.WorkspaceRegulations,
+		"sourceRegulations":         curRegulationJSON.SourceRegulations,
+	}, "", "  ")
+	*reply = string(formattedOutput)
+	return err
+}
+
+// Subscribers reports the cumulative number of channels subscribed to each topic
+func (bca *BackendConfigAdmin) Subscribers(noArgs struct{}, reply *string) (err error) {
+	poller := bca.backendConfig().poller
+	poller.lock.RLock()
+	defer poller.lock.RUnlock()
+
+	formattedOutput, err := json.MarshalIndent(poller.subscriptionCounts, "", "  ")
+	*reply = string(formattedOutput)
+	return err
+}
@@ -3,13 +3,6 @@ package backendconfig
 //go:generate mockgen -destination=../../mocks/config/backend-config/mock_backendconfig.go -package=mock_backendconfig github.com/rudderlabs/rudder-server/config/backend-config BackendConfig
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"reflect"
-	"sort"
-	"sync"
 	"time"
 
 	"github.com/rudderlabs/rudder-utils/diagnostics"
@@ -18,33 +11,18 @@ import (
 	"github.com/rudderlabs/rudder-utils/logger"
 	"github.com/rudderlabs/rudder-utils/utils/types"
 
-	"github.com/rudderlabs/rudder-utils/rruntime"
 	"github.com/rudderlabs/rudder-utils/utils"
 	"github.com/rudderlabs/rudder-utils/utils/sysUtils"
 )
 
 var (
-	backendConfig                         BackendConfig
-	isMultiWorkspace                      bool
-	multiWorkspaceSecret                  string
-	configBackendURL, workspaceToken      string
-	pollInterval, regulationsPollInterval time.Duration
-	configFromFile                        bool
-	configJSONPath                        string
-	curSourceJSON                         ConfigT
-	curSourceJSONLock                     sync.RWMutex
-	curRegulationJSON                     RegulationsT
-	curRegulationJSONLock                 sync.RWMutex
-	initializedLock                       sync.RWMutex
-	initialized                           bool
-	waitForRegulations                    bool
-	LastSync                              string
-	LastRegulationSync                    string
-	maxRegulationsPerRequest              int
-	configEnvReplacementEnabled           bool
-	errorFilePath                         string
-	configEnvHandler                      types.ConfigEnvI
-	Diagnostics                           diagnostics.DiagnosticsI
+	// defaultInstance backs the package level functions, it is replaced by Setup
+	defaultInstance *Instance
+	Diagnostics     diagnostics.DiagnosticsI
+
+	// LastSync and LastRegulationSync are maintained by the instance created by Setup. Use Instance.GetLastSync with other instances.
+	LastSync           string
+	LastRegulationSync string
 
 	//DefaultBackendConfig will be initialized be Setup to either a WorkspaceConfig or MultiWorkspaceConfig.
 	DefaultBackendConfig BackendConfig
@@ -53,6 +31,7 @@ var (
 	IoUtil               sysUtils.IoUtilI = sysUtils.NewIoUtil()
 )
 
+// Eb is the event bus of the instance created by Setup
 var Eb utils.PublishSubscriber = new(utils.EventBus)
 
 // Topic refers to a subset of backend config's updates, received after subscribing using the backend config's Subscribe function.
@@ -70,11 +49,14 @@ const (
 	/*TopicRegulations topic provides updates on regulations, via Subscribe function */
 	TopicRegulations Topic = "regulations"
 
+	/*TopicRegulationChanges topic provides the regulations added and removed by each regulations update, via Subscribe function */
+	TopicRegulationChanges Topic = "regulationChanges"
+
 	/*RegulationSuppress refers to Suppress Regulation */
 	RegulationSuppress Regulation = "Suppress"
 
 	//TODO Will add support soon.
-	/*RegulationDelete refers to Suppress and Delete Regulation */
+	/*RegulationDelete refers to Delete Regulation */
 	RegulationDelete Regulation = "Delete"
 
 	/*RegulationSuppressAndDelete refers to Suppress and Delete Regulation */
@@ -93,6 +75,7 @@ type SourceDefinitionT struct {
 	ID       string
 	Name     string
 	Category string
+	Config   map[string]interface{}
 }
 
 type DestinationT struct {
@@ -103,6 +86,8 @@ type DestinationT struct {
 	Enabled               bool
 	Transformations       []TransformationT
 	IsProcessorEnabled    bool
+	// Schedule pauses the destination at given times, on TopicProcessConfig only
+	Schedule *DestinationScheduleT `json:",omitempty"`
 }
 
 type SourceT struct {
@@ -118,14 +103,14 @@ type SourceT struct {
 
 type WorkspaceRegulationT struct {
 	ID             string
-	RegulationType string
+	RegulationType Regulation
 	WorkspaceID    string
 	UserID         string
 }
 
 type SourceRegulationT struct {
 	ID             string
-	RegulationType string
+	RegulationType Regulation
 	WorkspaceID    string
 	SourceID       string
 	UserID         string
@@ -136,6 +121,10 @@ type ConfigT struct {
 	WorkspaceID   string     `json:"workspaceId"`
 	Sources       []SourceT  `json:"sources"`
 	Libraries     LibrariesT `json:"libraries"`
+	// Settings are the settings of the workspace. In multi workspace mode they are only available through GetWorkspaceSettingsForWorkspaceID.
+	Settings WorkspaceSettingsT `json:"settings"`
+	// Version is the content hash of the config, set when it is published
+	Version string `json:"-"`
 }
 
 type RegulationsT struct {
@@ -161,6 +150,30 @@ type SRegulationsT struct {
 	Next              int                 `json:"next"`
 }
 
+// WorkspaceRegulationsPage returns the page of at most limit regulations from start, paged the way the config backend does
+func WorkspaceRegulationsPage(regulations []WorkspaceRegulationT, start int, limit int) WRegulationsT {
+	from, to, end := pageBounds(len(regulations), start, limit)
+	return WRegulationsT{WorkspaceRegulations: regulations[from:to], Start: start, Limit: limit, Size: to - from, End: end, Next: to}
+}
+
+// SourceRegulationsPage returns the page of at most limit regulations from start, paged the way the config backend does
+func SourceRegulationsPage(regulations []SourceRegulationT, start int, limit int) SRegulationsT {
+	from, to, end := pageBounds(len(regulations), start, limit)
+	return SRegulationsT{SourceRegulations: regulations[from:to], Start: start, Limit: limit, Size: to - from, End: end, Next: to}
+}
+
+func pageBounds(total int, start int, limit int) (from int, to int, end bool) {
+	from = start
+	if from > total {
+		from = total
+	}
+	to = from + limit
+	if to >= total {
+		return from, total, true
+	}
+	return from, to, false
+}
+
 type TransformationT struct {
 	VersionID string
 }
@@ -177,11 +190,13 @@ type BackendConfig interface {
 	GetRegulations() (RegulationsT, bool)
 	GetWorkspaceIDForWriteKey(string) string
 	GetWorkspaceLibrariesForWorkspaceID(string) LibrariesT
+	GetWorkspaceSettingsForWorkspaceID(string) WorkspaceSettingsT
 	WaitForConfig()
 	Subscribe(channel chan utils.DataEvent, topic Topic)
 }
 type CommonBackendConfig struct {
 	configEnvHandler types.ConfigEnvI
+	instance         *Instance
 }
 
 type BackendConfigSetup struct {
@@ -199,10 +214,87 @@ type BackendConfigSetup struct {
 	ConfigLogger                logger.ConfigLogger
 	ConfigStats                 stats.ConfigStats
 	ConfigDiagnostics           diagnostics.ConfigDiagnostics
+	PostRetryPolicy             PostRetryPolicy
+	PostSpoolDir                string
+	PostSpoolRetryInterval      time.Duration
+	// MaxPollInterval and MaxRegulationsPollInterval bound the adaptive poll intervals. Set them to the poll intervals to poll at a fixed rate.
+	MaxPollInterval                time.Duration
+	MaxRegulationsPollInterval     time.Duration
+	PollIntervalJitter             float64
+	CircuitBreakerFailureThreshold int
+	CircuitBreakerOpenTimeout      time.Duration
+	// ConfigBackendMirrorUrls are tried in order when ConfigBackendUrl is unavailable
+	ConfigBackendMirrorUrls       []string
+	ConfigBackendEndpointCooldown time.Duration
+	// ConfigProxyEnabled serves the config as fetched on ConfigProxyAddress, for peers to use as their ConfigBackendUrl. It requires WorkSpaceToken, or MultiWorkspaceSecret in multi workspace mode.
+	ConfigProxyEnabled bool
+	ConfigProxyAddress string
+	// ConfigVerificationMode is one of ConfigVerificationHMAC or ConfigVerificationEd25519 to reject configs that aren't signed. Empty disables verification.
+	ConfigVerificationMode string
+	ConfigHMACSecrets      []string
+	// ConfigPublicKeys are base64 encoded ed25519 public keys
+	ConfigPublicKeys []string
+	// RegisterAdminHandler is called by Start with "BackendConfig" and the BackendConfigAdmin of the instance. This package can't depend on the admin server,
+	// so it is nil by default and no admin RPCs are served. Set it to admin.RegisterAdminHandler of the server to serve them, or register Instance.Admin yourself.
+	RegisterAdminHandler func(name string, handler interface{})
+	// SecretRedactionPolicy is one of RedactFull, RedactPartial or RedactFingerprint, applied to secrets reported through admin
+	SecretRedactionPolicy RedactionPolicy
+	MaskWriteKeys         bool
+	// StrictConfigDecoding reports unknown and missing required fields of fetched configs as warnings, see GenerateConfigSchema
+	StrictConfigDecoding bool
+	// TransformationCacheDir caches the code of transformation and library versions, fetched before a config using them is published. Empty disables the cache.
+	TransformationCacheDir string
+	// ConfigOverlayPath is a ConfigOverlayT file merged into every fetched config. Empty disables the overlay.
+	ConfigOverlayPath string
+	// StatusReportInterval is how often the applied config version and sync errors are posted to StatusReportEndpoint. Zero disables reporting, and so does an empty StatusReportEndpoint.
+	StatusReportInterval time.Duration
+	StatusReportEndpoint string
+	// InstanceID identifies this server in status reports, the host name by default
+	InstanceID string
+	// RegulationStore is RegulationStoreMap or RegulationStoreCompact, which can front its sets with a Bloom filter and spill large sets to RegulationStoreSpillDir
+	RegulationStore            string
+	RegulationStoreBloomFilter bool
+	RegulationStoreSpillDir    string
+	// RegulationAuditLogPath is a JSON lines file the first_seen, applied and revoked events of regulations are appended to. Empty disables the log. Regulations are held back while their events can't be written.
+	RegulationAuditLogPath string
+	// TransformationPrefetchMaxHoldBack bounds how long a config is held back while the code of its versions can't be fetched or cached. It is published anyway after that.
+	TransformationPrefetchMaxHoldBack time.Duration
+}
+
+var DefaultBackendConfigSetup = BackendConfigSetup{IsMultiWorkspace: false, MultiWorkspaceSecret: "password", ConfigBackendUrl: "https://api.rudderlabs.com", WorkSpaceToken: "", RegulationsPollInterval: 300 * time.Second, PollInterval: 5 * time.Second, ConfigJSONPath: "/etc/rudderstack/workspaceConfig.json", ConfigFromFile: false, MaxRegulationsPerRequest: 1000, ConfigEnvReplacementEnabled: true, ErrorFilePath: "/tmp/error_store.json", ConfigLogger: logger.DefaultConfigLogger, ConfigStats: stats.DefaultConfigStats, ConfigDiagnostics: diagnostics.DefaultConfigDiagnostics, PostRetryPolicy: DefaultPostRetryPolicy, PostSpoolDir: "", PostSpoolRetryInterval: 60 * time.Second, MaxPollInterval: 60 * time.Second, MaxRegulationsPollInterval: 900 * time.Second, PollIntervalJitter: 0.1, CircuitBreakerFailureThreshold: 5, CircuitBreakerOpenTimeout: 60 * time.Second, ConfigBackendEndpointCooldown: 30 * time.Second, ConfigProxyEnabled: false, ConfigProxyAddress: ":5002", SecretRedactionPolicy: RedactPartial, MaskWriteKeys: true, StrictConfigDecoding: false, TransformationCacheDir: "", ConfigOverlayPath: "", StatusReportInterval: 0, StatusReportEndpoint: "/dataPlaneStatus", InstanceID: "", RegulationStore: RegulationStoreMap, RegulationStoreBloomFilter: false, RegulationStoreSpillDir: "", RegulationAuditLogPath: "", TransformationPrefetchMaxHoldBack: 10 * time.Minute}
+
+// withDefaults returns setup with the defaults of DefaultBackendConfigSetup for the settings that are zero but must not be
+func (setup BackendConfigSetup) withDefaults() BackendConfigSetup {
+	if setup.PostRetryPolicy == (PostRetryPolicy{}) {
+		setup.PostRetryPolicy = DefaultPostRetryPolicy
+	}
+	if setup.PostSpoolRetryInterval <= 0 {
+		setup.PostSpoolRetryInterval = DefaultBackendConfigSetup.PostSpoolRetryInterval
+	}
+	if setup.PollInterval <= 0 {
+		setup.PollInterval = DefaultBackendConfigSetup.PollInterval
+	}
+	if setup.RegulationsPollInterval <= 0 {
+		setup.RegulationsPollInterval = DefaultBackendConfigSetup.RegulationsPollInterval
+	}
+	if setup.MaxPollInterval <= 0 {
+		setup.MaxPollInterval = DefaultBackendConfigSetup.MaxPollInterval
+	}
+	if setup.MaxRegulationsPollInterval <= 0 {
+		setup.MaxRegulationsPollInterval = DefaultBackendConfigSetup.MaxRegulationsPollInterval
+	}
+	if setup.CircuitBreakerFailureThreshold <= 0 {
+		setup.CircuitBreakerFailureThreshold = DefaultBackendConfigSetup.CircuitBreakerFailureThreshold
+	}
+	if setup.CircuitBreakerOpenTimeout <= 0 {
+		setup.CircuitBreakerOpenTimeout = DefaultBackendConfigSetup.CircuitBreakerOpenTimeout
+	}
+	if setup.ConfigBackendEndpointCooldown <= 0 {
+		setup.ConfigBackendEndpointCooldown = DefaultBackendConfigSetup.ConfigBackendEndpointCooldown
+	}
+	return setup
 }
 
-var DefaultBackendConfigSetup = BackendConfigSetup{IsMultiWorkspace: false, MultiWorkspaceSecret: "password", ConfigBackendUrl: "https://api.rudderlabs.com", WorkSpaceToken: "", RegulationsPollInterval: 300 * time.Second, PollInterval: 5 * time.Second, ConfigJSONPath: "/etc/rudderstack/workspaceConfig.json", ConfigFromFile: false, MaxRegulationsPerRequest: 1000, ConfigEnvReplacementEnabled: true, ErrorFilePath: "/tmp/error_store.json", ConfigLogger: logger.DefaultConfigLogger, ConfigStats: stats.DefaultConfigStats, ConfigDiagnostics: diagnostics.DefaultConfigDiagnostics}
-
 func checkAndValidateConfig(configList []interface{}) BackendConfigSetup {
 	if len(configList) != 1 {
 		return DefaultBackendConfigSetup
@@ -216,67 +308,28 @@ func checkAndValidateConfig(configList []interface{}) BackendConfigSetup {
 	}
 }
 
-func loadConfig(configList ...interface{}) {
+// loadConfig sets up the process wide logger, stats and diagnostics, and returns the setup to create the default instance with
+func loadConfig(configList ...interface{}) BackendConfigSetup {
 
 	config := checkAndValidateConfig(configList)
-	// Rudder supporting multiple workspaces. false by default
-	isMultiWorkspace = config.IsMultiWorkspace
-	// Secret to be sent in basic auth for supporting multiple workspaces. password by default
-	multiWorkspaceSecret = config.MultiWorkspaceSecret
-
-	configBackendURL = config.ConfigBackendUrl
-	workspaceToken = config.WorkSpaceToken
-
-	pollInterval = config.PollInterval
-	regulationsPollInterval = config.RegulationsPollInterval
-	configJSONPath = config.ConfigJSONPath
-	configFromFile = config.ConfigFromFile
-	maxRegulationsPerRequest = config.MaxRegulationsPerRequest
-	configEnvReplacementEnabled = config.ConfigEnvReplacementEnabled
 	diagnostics.LoadConfig(config.ConfigDiagnostics)
 	pkgLogger = logger.NewLogger(config.ConfigLogger).Child("backend-config")
 	stats.Setup(config.ConfigStats)
-	errorFilePath = config.ErrorFilePath
 
 	Diagnostics = diagnostics.Diagnostics
+	return config
 }
 
-func MakePostRequest(url string, endpoint string, data interface{}) (response []byte, ok bool) {
-	client := &http.Client{}
-	backendURL := fmt.Sprintf("%s%s", url, endpoint)
-	dataJSON, _ := json.Marshal(data)
-	request, err := Http.NewRequest("POST", backendURL, bytes.NewBuffer(dataJSON))
-	if err != nil {
-		pkgLogger.Errorf("ConfigBackend: Failed to make request: %s, Error: %s", backendURL, err.Error())
-		return []byte{}, false
-	}
-
-	request.SetBasicAuth(workspaceToken, "")
-	request.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(request)
-	// Not handling errors when sending alert to victorops
-	if err != nil {
-		pkgLogger.Errorf("ConfigBackend: Failed to execute request: %s, Error: %s", backendURL, err.Error())
-		return []byte{}, false
-	}
-	if resp.StatusCode != 200 && resp.StatusCode != 202 {
-		pkgLogger.Errorf("ConfigBackend: Got error response %d", resp.StatusCode)
-	}
-
-	body, err := IoUtil.ReadAll(resp.Body)
-	defer resp.Body.Close()
-
-	pkgLogger.Debugf("ConfigBackend: Successful %s", string(body))
-	return body, true
-}
-
-func MakeBackendPostRequest(endpoint string, data interface{}) (response []byte, ok bool) {
-	return MakePostRequest(configBackendURL, endpoint, data)
+func init() {
+	defaultInstance = newDefaultInstance(loadConfig(), nil)
 }
 
-func init() {
-	loadConfig()
+// newDefaultInstance creates the instance behind the package level functions, publishing on Eb
+func newDefaultInstance(config BackendConfigSetup, configEnvHandler types.ConfigEnvI) *Instance {
+	instance := New(config, configEnvHandler)
+	instance.eb = Eb
+	instance.isDefault = true
+	return instance
 }
 
 func trackConfig(preConfig ConfigT, curConfig ConfigT) {
@@ -301,9 +354,20 @@ func trackConfig(preConfig ConfigT, curConfig ConfigT) {
 	}
 }
 
+/*
+processConfig returns the config published on TopicProcessConfig at now: the processor enabled destinations of config,
+disabled while their schedule pauses them. It also returns the IDs of the paused destinations and when a schedule next changes.
+*/
+func processConfig(config ConfigT, locations scheduleLocations, now time.Time) (ConfigT, []string, time.Time) {
+	filteredConfig := filterProcessorEnabledDestinations(config)
+	pausedDestinations, nextScheduleChange := applyDestinationSchedules(filteredConfig, locations, now)
+	return filteredConfig, pausedDestinations, nextScheduleChange
+}
+
 func filterProcessorEnabledDestinations(config ConfigT) ConfigT {
 	var modifiedConfig ConfigT
 	modifiedConfig.Libraries = config.Libraries
+	modifiedConfig.Version = config.Version
 	modifiedConfig.Sources = make([]SourceT, 0)
 	for _, source := range config.Sources {
 		destinations := make([]DestinationT, 0)
@@ -319,90 +383,42 @@ func filterProcessorEnabledDestinations(config ConfigT) ConfigT {
 	return modifiedConfig
 }
 
-func regulationsUpdate(statConfigBackendError stats.RudderStats) {
-
-	regulationJSON, ok := backendConfig.GetRegulations()
-	if !ok {
-		statConfigBackendError.Increment()
-	}
-
-	//sorting the regulationJSON.
-	//json unmarshal does not guarantee order. For DeepEqual to work as expected, sorting is necessary
-	sort.Slice(regulationJSON.WorkspaceRegulations[:], func(i, j int) bool {
-		return regulationJSON.WorkspaceRegulations[i].ID < regulationJSON.WorkspaceRegulations[j].ID
-	})
-	sort.Slice(regulationJSON.SourceRegulations[:], func(i, j int) bool {
-		return regulationJSON.SourceRegulations[i].ID < regulationJSON.SourceRegulations[j].ID
-	})
-
-	if ok && !reflect.DeepEqual(curRegulationJSON, regulationJSON) {
-		pkgLogger.Info("Regulations changed")
-		curRegulationJSONLock.Lock()
-		curRegulationJSON = regulationJSON
-		curRegulationJSONLock.Unlock()
-		initializedLock.Lock() //Using initializedLock for waitForRegulations too.
-		defer initializedLock.Unlock()
-		waitForRegulations = false
-		LastRegulationSync = time.Now().Format(time.RFC3339)
-		Eb.Publish(string(TopicRegulations), regulationJSON)
-	}
+// GetConfig returns the config last published by the default instance
+func GetConfig() ConfigT {
+	return defaultInstance.GetConfig()
 }
 
-func configUpdate(statConfigBackendError stats.RudderStats) {
-
-	sourceJSON, ok := backendConfig.Get()
-	if !ok {
-		statConfigBackendError.Increment()
-	}
-
-	//sorting the sourceJSON.
-	//json unmarshal does not guarantee order. For DeepEqual to work as expected, sorting is necessary
-	sort.Slice(sourceJSON.Sources[:], func(i, j int) bool {
-		return sourceJSON.Sources[i].ID < sourceJSON.Sources[j].ID
-	})
-
-	if ok && !reflect.DeepEqual(curSourceJSON, sourceJSON) {
-		pkgLogger.Info("Workspace Config changed")
-		curSourceJSONLock.Lock()
-		trackConfig(curSourceJSON, sourceJSON)
-		filteredSourcesJSON := filterProcessorEnabledDestinations(sourceJSON)
-		curSourceJSON = sourceJSON
-		curSourceJSONLock.Unlock()
-		initializedLock.Lock()
-		defer initializedLock.Unlock()
-		initialized = true
-		LastSync = time.Now().Format(time.RFC3339)
-		Eb.Publish(string(TopicProcessConfig), filteredSourcesJSON)
-		Eb.Publish(string(TopicBackendConfig), sourceJSON)
-	}
+// GetCurrentRegulations returns the last published regulations, without fetching them
+func GetCurrentRegulations() RegulationsT {
+	return defaultInstance.GetCurrentRegulations()
 }
 
-func pollConfigUpdate() {
-	statConfigBackendError := stats.NewStat("config_backend.errors", stats.CountType)
-	for {
-		configUpdate(statConfigBackendError)
-		time.Sleep(time.Duration(pollInterval))
-	}
+// IsSuppressedUser reports whether a regulation of the default instance suppresses the events of userID in the source
+func IsSuppressedUser(workspaceID string, sourceID string, userID string) bool {
+	return defaultInstance.IsSuppressedUser(workspaceID, sourceID, userID)
 }
 
-func pollRegulations() {
-	statConfigBackendError := stats.NewStat("config_backend.errors", stats.CountType)
-	for {
-		regulationsUpdate(statConfigBackendError)
-		time.Sleep(time.Duration(regulationsPollInterval))
-	}
+// GetSecretRedactor returns the Redactor configured through BackendConfigSetup
+func GetSecretRedactor() *Redactor {
+	return defaultInstance.GetSecretRedactor()
 }
 
-func GetConfig() ConfigT {
-	return curSourceJSON
+// GetConfigVersion returns the content hash of the current config, which changes every time a new config is published
+func GetConfigVersion() string {
+	return defaultInstance.GetConfigVersion()
 }
 
 func GetWorkspaceIDForWriteKey(writeKey string) string {
-	return backendConfig.GetWorkspaceIDForWriteKey(writeKey)
+	return defaultInstance.GetWorkspaceIDForWriteKey(writeKey)
 }
 
 func GetWorkspaceLibrariesForWorkspaceID(workspaceId string) LibrariesT {
-	return backendConfig.GetWorkspaceLibrariesForWorkspaceID(workspaceId)
+	return defaultInstance.GetWorkspaceLibrariesForWorkspaceID(workspaceId)
+}
+
+// GetWorkspaceSettingsForWorkspaceID returns the settings of a workspace of the default instance, with defaults for the settings that aren't set
+func GetWorkspaceSettingsForWorkspaceID(workspaceID string) WorkspaceSettingsT {
+	return defaultInstance.GetWorkspaceSettingsForWorkspaceID(workspaceID)
 }
 
 /*
@@ -410,7 +426,7 @@ Subscribe subscribes a channel to a specific topic of backend config updates.
 Deprecated: Use an instance of BackendConfig instead of static function
 */
 func Subscribe(channel chan utils.DataEvent, topic Topic) {
-	backendConfig.Subscribe(channel, topic)
+	defaultInstance.Subscribe(channel, topic)
 }
 
 /*
@@ -419,22 +435,12 @@ Channel will receive a new utils.DataEvent each time the backend configuration i
 Data of the DataEvent should be a backendconfig.ConfigT struct.
 Available topics are:
 - TopicBackendConfig: Will receive complete backend configuration
-- TopicProcessConfig: Will receive only backend configuration of processor enabled destinations
+- TopicProcessConfig: Will receive only backend configuration of processor enabled destinations, disabled while their schedule pauses them, and again whenever a schedule starts or stops pausing one
 - TopicRegulations: Will receeive all regulations
+- TopicRegulationChanges: Will receive RegulationChangesT with the regulations added and removed since the previous update
 */
 func (bc *CommonBackendConfig) Subscribe(channel chan utils.DataEvent, topic Topic) {
-	Eb.Subscribe(string(topic), channel)
-	curSourceJSONLock.RLock()
-
-	if topic == TopicProcessConfig {
-		filteredSourcesJSON := filterProcessorEnabledDestinations(curSourceJSON)
-		Eb.PublishToChannel(channel, string(topic), filteredSourcesJSON)
-	} else if topic == TopicBackendConfig {
-		Eb.PublishToChannel(channel, string(topic), curSourceJSON)
-	} else if topic == TopicRegulations {
-		Eb.PublishToChannel(channel, string(topic), curRegulationJSON)
-	}
-	curSourceJSONLock.RUnlock()
+	bc.getInstance().Subscribe(channel, topic)
 }
 
 /*
@@ -442,60 +448,37 @@ WaitForConfig waits until backend config has been initialized
 Deprecated: Use an instance of BackendConfig instead of static function
 */
 func WaitForConfig() {
-	backendConfig.WaitForConfig()
+	defaultInstance.WaitForConfig()
 }
 
 /*
 WaitForConfig waits until backend config has been initialized
 */
 func (bc *CommonBackendConfig) WaitForConfig() {
-	for {
-		initializedLock.RLock()
-		if initialized && !waitForRegulations {
-			initializedLock.RUnlock()
-			break
-		}
-		initializedLock.RUnlock()
-		pkgLogger.Info("Waiting for initializing backend config")
-		time.Sleep(time.Duration(pollInterval))
+	bc.getInstance().WaitForConfig()
+}
+
+// getInstance returns the instance of the provider, or the default instance for providers not created by New, e.g. a zero value WorkspaceConfig
+func (bc *CommonBackendConfig) getInstance() *Instance {
+	if bc.instance != nil {
+		return bc.instance
 	}
+	return defaultInstance
 }
 
 // Setup backend config
 
 //Setup ... LoadConfig and Setup or Call Setup and initialise LoadConfig in this
+//The instance set up before, if any, is stopped so that its pollers don't keep publishing on Eb.
 func Setup(pollRegulations bool, configEnvHandler types.ConfigEnvI, configList ...interface{}) BackendConfig {
-	loadConfig(configList...)
+	config := loadConfig(configList...)
 
-	if isMultiWorkspace {
-		backendConfig = new(MultiWorkspaceConfig)
-	} else {
-		backendConfig = new(WorkspaceConfig)
-		backendConfig.(*WorkspaceConfig).CommonBackendConfig.configEnvHandler = configEnvHandler
+	if defaultInstance != nil {
+		defaultInstance.Stop()
 	}
+	defaultInstance = newDefaultInstance(config, configEnvHandler)
+	DefaultBackendConfig = defaultInstance.Provider()
+	defaultInstance.Start(pollRegulations)
 
-	backendConfig.SetUp()
-
-	DefaultBackendConfig = backendConfig
-
-	rruntime.Go(func() {
-		pollConfigUpdate()
-	}, errorFilePath)
-
-	if pollRegulations {
-		startRegulationPolling()
-	}
-
-	//admin.RegisterAdminHandler("BackendConfig", &BackendConfigAdmin{})
-
-	return backendConfig
-}
-
-// startRegulationPolling - starts enterprise backend regulations polling
-func startRegulationPolling() {
-	waitForRegulations = true
-
-	rruntime.Go(func() {
-		pollRegulations()
-	}, errorFilePath)
+	return DefaultBackendConfig
 }
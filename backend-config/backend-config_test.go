@@ -0,0 +1,297 @@
+package backendconfig
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+const testWorkspaceToken = "test-token"
+
+// testConfigBackend serves a single workspace config and its regulations the way the config backend does
+type testConfigBackend struct {
+	*httptest.Server
+	lock        sync.Mutex
+	config      ConfigT
+	regulations RegulationsT
+	statusCode  int
+	// signingSecret signs responses with an HMAC when set
+	signingSecret string
+	// hostedBody replaces the hosted workspace config served when set
+	hostedBody []byte
+	// code holds the transformation and library versions served by version ID, codeStatusCode fails their requests when set
+	code           map[string][]byte
+	codeStatusCode int
+	codeRequests   int
+	// statusReports are the data plane statuses posted
+	statusReports []DataPlaneStatusT
+}
+
+func newTestConfigBackend(t *testing.T, config ConfigT) *testConfigBackend {
+	backend := &testConfigBackend{config: config, statusCode: http.StatusOK, code: make(map[string][]byte)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/workspaceConfig", func(w http.ResponseWriter, r *http.Request) {
+		backend.lock.Lock()
+		defer backend.lock.Unlock()
+		backend.write(w, backend.config)
+	})
+	mux.HandleFunc("/workspaces/regulations", func(w http.ResponseWriter, r *http.Request) {
+		backend.lock.Lock()
+		defer backend.lock.Unlock()
+		backend.write(w, WRegulationsT{WorkspaceRegulations: backend.regulations.WorkspaceRegulations, End: true})
+	})
+	mux.HandleFunc("/workspaces/sources/regulations", func(w http.ResponseWriter, r *http.Request) {
+		backend.lock.Lock()
+		defer backend.lock.Unlock()
+		backend.write(w, SRegulationsT{SourceRegulations: backend.regulations.SourceRegulations, End: true})
+	})
+	mux.HandleFunc("/hostedWorkspaceConfig", func(w http.ResponseWriter, r *http.Request) {
+		backend.lock.Lock()
+		defer backend.lock.Unlock()
+		if backend.hostedBody != nil {
+			backend.writeBody(w, backend.hostedBody)
+			return
+		}
+		backend.write(w, map[string]ConfigT{backend.config.WorkspaceID: backend.config})
+	})
+	serveCode := func(w http.ResponseWriter, r *http.Request) {
+		backend.lock.Lock()
+		defer backend.lock.Unlock()
+		backend.codeRequests++
+		if backend.codeStatusCode != 0 {
+			http.Error(w, http.StatusText(backend.codeStatusCode), backend.codeStatusCode)
+			return
+		}
+		code, ok := backend.code[r.URL.Query().Get("versionId")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(code)
+	}
+	mux.HandleFunc("/dataPlaneStatus", func(w http.ResponseWriter, r *http.Request) {
+		var status DataPlaneStatusT
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		backend.lock.Lock()
+		defer backend.lock.Unlock()
+		backend.statusReports = append(backend.statusReports, status)
+	})
+	mux.HandleFunc("/transformation/getByVersionId", serveCode)
+	mux.HandleFunc("/transformationLibrary/getByVersionId", serveCode)
+	backend.Server = httptest.NewServer(mux)
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+// write must be called with backend.lock held
+func (backend *testConfigBackend) write(w http.ResponseWriter, data interface{}) {
+	body, _ := json.Marshal(data)
+	backend.writeBody(w, body)
+}
+
+// writeBody writes body, or a plain error like the config backend does when statusCode is set to an error
+func (backend *testConfigBackend) writeBody(w http.ResponseWriter, body []byte) {
+	if backend.statusCode != http.StatusOK {
+		http.Error(w, http.StatusText(backend.statusCode), backend.statusCode)
+		return
+	}
+	if backend.signingSecret != "" {
+		w.Header().Set(configSignatureHeader, base64.StdEncoding.EncodeToString(computeHMAC([]byte(backend.signingSecret), body)))
+	}
+	w.WriteHeader(backend.statusCode)
+	w.Write(body)
+}
+
+func (backend *testConfigBackend) setConfig(config ConfigT) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	backend.config = config
+}
+
+func (backend *testConfigBackend) setRegulations(regulations RegulationsT) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	backend.regulations = regulations
+}
+
+func (backend *testConfigBackend) setSigningSecret(secret string) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	backend.signingSecret = secret
+}
+
+func (backend *testConfigBackend) setCode(versionID string, code string) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	backend.code[versionID] = []byte(code)
+}
+
+func (backend *testConfigBackend) setCodeStatusCode(statusCode int) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	backend.codeStatusCode = statusCode
+}
+
+func (backend *testConfigBackend) getCodeRequests() int {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	return backend.codeRequests
+}
+
+func (backend *testConfigBackend) getStatusReports() []DataPlaneStatusT {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	return append([]DataPlaneStatusT(nil), backend.statusReports...)
+}
+
+func (backend *testConfigBackend) setStatusCode(statusCode int) {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	backend.statusCode = statusCode
+}
+
+// newTestInstance returns an instance fetching from backend, not started, with setup modified by configure
+func newTestInstance(t *testing.T, backend *testConfigBackend, configure func(setup *BackendConfigSetup)) *Instance {
+	setup := DefaultBackendConfigSetup
+	setup.ConfigBackendUrl = backend.URL
+	setup.WorkSpaceToken = testWorkspaceToken
+	setup.MultiWorkspaceSecret = testWorkspaceToken
+	setup.PollInterval = 10 * time.Millisecond
+	setup.MaxPollInterval = 10 * time.Millisecond
+	setup.ErrorFilePath = ""
+	if configure != nil {
+		configure(&setup)
+	}
+	instance := New(setup, nil)
+	t.Cleanup(instance.Stop)
+	return instance
+}
+
+// testSourcesConfig returns a config of one workspace with a source per destination ID, each with that destination
+func testSourcesConfig(destinationIDs ...string) ConfigT {
+	config := ConfigT{WorkspaceID: "workspace-1"}
+	for _, destinationID := range destinationIDs {
+		config.Sources = append(config.Sources, SourceT{
+			ID:          "source-" + destinationID,
+			Name:        "source " + destinationID,
+			WriteKey:    "write-key-" + destinationID,
+			WorkspaceID: "workspace-1",
+			Enabled:     true,
+			Destinations: []DestinationT{{
+				ID:                    destinationID,
+				Name:                  "destination " + destinationID,
+				DestinationDefinition: DestinationDefinitionT{ID: "definition-1", Name: "WEBHOOK"},
+				Config:                map[string]interface{}{"webhookUrl": "https://example.com/" + destinationID},
+				Enabled:               true,
+				IsProcessorEnabled:    true,
+			}},
+		})
+	}
+	return config
+}
+
+var testStatConfigBackendError = stats.NewStat("config_backend.errors", stats.CountType)
+
+func TestRegulationsPage(t *testing.T) {
+	regulations := make([]WorkspaceRegulationT, 25)
+	tests := []struct {
+		name     string
+		start    int
+		limit    int
+		wantSize int
+		wantEnd  bool
+		wantNext int
+	}{
+		{name: "first page", start: 0, limit: 10, wantSize: 10, wantEnd: false, wantNext: 10},
+		{name: "last page", start: 20, limit: 10, wantSize: 5, wantEnd: true, wantNext: 25},
+		{name: "page ending on the last regulation", start: 15, limit: 10, wantSize: 10, wantEnd: true, wantNext: 25},
+		{name: "past the end", start: 30, limit: 10, wantSize: 0, wantEnd: true, wantNext: 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := WorkspaceRegulationsPage(regulations, tt.start, tt.limit)
+			if page.Size != tt.wantSize || len(page.WorkspaceRegulations) != tt.wantSize || page.End != tt.wantEnd || page.Next != tt.wantNext {
+				t.Errorf("got size %d, end %t, next %d, want %d, %t, %d", page.Size, page.End, page.Next, tt.wantSize, tt.wantEnd, tt.wantNext)
+			}
+			sourcePage := SourceRegulationsPage(make([]SourceRegulationT, len(regulations)), tt.start, tt.limit)
+			if sourcePage.Size != page.Size || sourcePage.End != page.End || sourcePage.Next != page.Next {
+				t.Errorf("source regulations paged differently: %+v", sourcePage)
+			}
+		})
+	}
+}
+
+// useDefaultInstance makes instance the default instance until the test ends
+func useDefaultInstance(t *testing.T, instance *Instance) {
+	previous, previousBackendConfig := defaultInstance, DefaultBackendConfig
+	defaultInstance, DefaultBackendConfig = instance, instance.Provider()
+	t.Cleanup(func() {
+		defaultInstance, DefaultBackendConfig = previous, previousBackendConfig
+	})
+}
+
+func TestZeroValueProvidersUseDefaultInstance(t *testing.T) {
+	tests := []struct {
+		name             string
+		provider         BackendConfig
+		isMultiWorkspace bool
+	}{
+		{name: "workspace config", provider: &WorkspaceConfig{}},
+		{name: "multi workspace config", provider: &MultiWorkspaceConfig{}, isMultiWorkspace: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestConfigBackend(t, testSourcesConfig("destination-1"))
+			useDefaultInstance(t, newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+				setup.IsMultiWorkspace = tt.isMultiWorkspace
+			}))
+
+			config, ok := tt.provider.Get()
+			if !ok || len(config.Sources) != 1 || config.Sources[0].ID != "source-destination-1" {
+				t.Fatalf("got %+v, %t", config, ok)
+			}
+			// the test backend serves the regulations of a single workspace only
+			if _, ok := tt.provider.GetRegulations(); !ok && !tt.isMultiWorkspace {
+				t.Error("failed to get regulations")
+			}
+			// the provider isn't the one of the instance, which publishes what its own provider fetched
+			tt.provider.(fetchedWorkspacesPublisher).publishFetchedWorkspaces()
+			if got := tt.provider.GetWorkspaceIDForWriteKey("write-key-destination-1"); got != "workspace-1" {
+				t.Errorf("got workspace %q for the write key", got)
+			}
+		})
+	}
+}
+
+func TestSetupStopsPreviousInstance(t *testing.T) {
+	backend := newTestConfigBackend(t, testSourcesConfig("destination-1"))
+	previous := newTestInstance(t, backend, nil)
+	useDefaultInstance(t, previous)
+
+	setup := previous.setup
+	Setup(false, nil, setup)
+	t.Cleanup(defaultInstance.Stop)
+
+	select {
+	case <-previous.stopCh:
+	default:
+		t.Error("previous instance wasn't stopped")
+	}
+	if defaultInstance == previous {
+		t.Fatal("default instance wasn't replaced")
+	}
+	select {
+	case <-defaultInstance.stopCh:
+		t.Error("new instance is stopped")
+	default:
+	}
+}
@@ -0,0 +1,267 @@
+/*
+Package backendconfigtest provides an in-process fake of the config backend, for tests of code depending on backendconfig.
+The fake serves the same endpoints and paging as the config backend, so the real fetch, pagination and regulation paths run.
+
+	server := backendconfigtest.NewServer()
+	defer server.Close()
+	server.PushConfig(backendconfig.ConfigT{WorkspaceID: "workspace-1", Sources: sources})
+
+	instance := backendconfig.New(server.BackendConfigSetup(false), nil)
+	instance.Start(true)
+	defer instance.Stop()
+	instance.WaitForConfig()
+*/
+package backendconfigtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	backendconfig "github.com/rudderlabs/rudder-platform/backend-config"
+)
+
+const (
+	// WorkspaceToken is the basic auth user expected on single workspace endpoints
+	WorkspaceToken = "test-workspace-token"
+	// MultiWorkspaceSecret is the basic auth user expected on hosted workspace endpoints
+	MultiWorkspaceSecret = "test-multi-workspace-secret"
+)
+
+// Server is a fake config backend. Its methods are safe to call while backendconfig is polling it.
+type Server struct {
+	*httptest.Server
+
+	lock                 sync.Mutex
+	workspaceConfigs     map[string]backendconfig.ConfigT
+	workspaceOrder       []string
+	workspaceRegulations []backendconfig.WorkspaceRegulationT
+	sourceRegulations    []backendconfig.SourceRegulationT
+	failures             map[string][]int
+	latency              time.Duration
+	requestCounts        map[string]int
+}
+
+// NewServer starts a fake config backend without any workspace
+func NewServer() *Server {
+	server := &Server{
+		workspaceConfigs: make(map[string]backendconfig.ConfigT),
+		failures:         make(map[string][]int),
+		requestCounts:    make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/workspaceConfig", server.handle(WorkspaceToken, server.workspaceConfigHandler))
+	mux.HandleFunc("/workspaces/regulations", server.handle(WorkspaceToken, server.workspaceRegulationsHandler))
+	mux.HandleFunc("/workspaces/sources/regulations", server.handle(WorkspaceToken, server.sourceRegulationsHandler))
+	mux.HandleFunc("/hostedWorkspaceConfig", server.handle(MultiWorkspaceSecret, server.hostedWorkspaceConfigHandler))
+	mux.HandleFunc("/hostedWorkspaces", server.handle(MultiWorkspaceSecret, server.hostedWorkspacesHandler))
+	mux.HandleFunc("/hostedWorkspaceRegulations", server.handle(MultiWorkspaceSecret, server.workspaceRegulationsHandler))
+	mux.HandleFunc("/hostedSourceRegulations", server.handle(MultiWorkspaceSecret, server.sourceRegulationsHandler))
+	server.Server = httptest.NewServer(mux)
+	return server
+}
+
+// BackendConfigSetup returns the default setup pointed at the fake, with short poll intervals so that pushed configs are picked up quickly
+func (server *Server) BackendConfigSetup(multiWorkspace bool) backendconfig.BackendConfigSetup {
+	setup := backendconfig.DefaultBackendConfigSetup
+	setup.IsMultiWorkspace = multiWorkspace
+	setup.ConfigBackendUrl = server.URL
+	setup.WorkSpaceToken = WorkspaceToken
+	setup.MultiWorkspaceSecret = MultiWorkspaceSecret
+	setup.PollInterval = 100 * time.Millisecond
+	setup.MaxPollInterval = 100 * time.Millisecond
+	setup.RegulationsPollInterval = 100 * time.Millisecond
+	setup.MaxRegulationsPollInterval = 100 * time.Millisecond
+	setup.MaxRegulationsPerRequest = 10
+	return setup
+}
+
+// PushConfig replaces the config of config.WorkspaceID, which is the config served on /workspaceConfig if it is the first workspace pushed
+func (server *Server) PushConfig(config backendconfig.ConfigT) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if _, ok := server.workspaceConfigs[config.WorkspaceID]; !ok {
+		server.workspaceOrder = append(server.workspaceOrder, config.WorkspaceID)
+	}
+	server.workspaceConfigs[config.WorkspaceID] = config
+}
+
+// RemoveWorkspace stops serving the config of a workspace
+func (server *Server) RemoveWorkspace(workspaceID string) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	delete(server.workspaceConfigs, workspaceID)
+	for i, id := range server.workspaceOrder {
+		if id == workspaceID {
+			server.workspaceOrder = append(server.workspaceOrder[:i], server.workspaceOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// PushRegulations replaces the regulations of all workspaces
+func (server *Server) PushRegulations(regulations backendconfig.RegulationsT) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.workspaceRegulations = append([]backendconfig.WorkspaceRegulationT{}, regulations.WorkspaceRegulations...)
+	server.sourceRegulations = append([]backendconfig.SourceRegulationT{}, regulations.SourceRegulations...)
+}
+
+// FailNext makes the next count requests to path, e.g. "/workspaceConfig", fail with statusCode
+func (server *Server) FailNext(path string, statusCode int, count int) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	for i := 0; i < count; i++ {
+		server.failures[path] = append(server.failures[path], statusCode)
+	}
+}
+
+// SetLatency delays every response by latency
+func (server *Server) SetLatency(latency time.Duration) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.latency = latency
+}
+
+// RequestCount returns the number of requests made to path, including failed ones
+func (server *Server) RequestCount(path string) int {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return server.requestCounts[path]
+}
+
+// WaitForRequests waits until count requests were made to path in total, reporting false on timeout
+func (server *Server) WaitForRequests(path string, count int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for server.RequestCount(path) < count {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// handle counts requests, injects latency and failures and checks the basic auth user before calling handler
+func (server *Server) handle(user string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		server.lock.Lock()
+		server.requestCounts[r.URL.Path]++
+		latency := server.latency
+		failStatus := 0
+		if failures := server.failures[r.URL.Path]; len(failures) > 0 {
+			failStatus, server.failures[r.URL.Path] = failures[0], failures[1:]
+		}
+		server.lock.Unlock()
+
+		time.Sleep(latency)
+		if failStatus != 0 {
+			http.Error(w, http.StatusText(failStatus), failStatus)
+			return
+		}
+		if requestUser, _, _ := r.BasicAuth(); requestUser != user {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func (server *Server) workspaceConfigHandler(w http.ResponseWriter, r *http.Request) {
+	server.lock.Lock()
+	config := backendconfig.ConfigT{}
+	if len(server.workspaceOrder) > 0 {
+		config = server.workspaceConfigs[server.workspaceOrder[0]]
+	}
+	server.lock.Unlock()
+	writeJSON(w, config)
+}
+
+func (server *Server) hostedWorkspaceConfigHandler(w http.ResponseWriter, r *http.Request) {
+	server.lock.Lock()
+	configs := make(map[string]backendconfig.ConfigT, len(server.workspaceConfigs))
+	for workspaceID, config := range server.workspaceConfigs {
+		configs[workspaceID] = config
+	}
+	server.lock.Unlock()
+	writeJSON(w, configs)
+}
+
+func (server *Server) hostedWorkspacesHandler(w http.ResponseWriter, r *http.Request) {
+	server.lock.Lock()
+	workspaceIDs := make(map[string]bool)
+	for workspaceID := range server.workspaceConfigs {
+		workspaceIDs[workspaceID] = true
+	}
+	server.lock.Unlock()
+
+	hostedWorkspaces := backendconfig.HostedWorkspacesT{HostedWorkspaces: make([]backendconfig.WorkspaceT, 0, len(workspaceIDs))}
+	for workspaceID := range workspaceIDs {
+		hostedWorkspaces.HostedWorkspaces = append(hostedWorkspaces.HostedWorkspaces, backendconfig.WorkspaceT{WorkspaceID: workspaceID})
+	}
+	sort.Slice(hostedWorkspaces.HostedWorkspaces, func(i, j int) bool {
+		return hostedWorkspaces.HostedWorkspaces[i].WorkspaceID < hostedWorkspaces.HostedWorkspaces[j].WorkspaceID
+	})
+	writeJSON(w, hostedWorkspaces)
+}
+
+// workspaceRegulationsHandler serves a page of workspace regulations, filtered by the workspaceId query parameter on the hosted endpoint
+func (server *Server) workspaceRegulationsHandler(w http.ResponseWriter, r *http.Request) {
+	start, limit := pageParams(r)
+	workspaceID := r.URL.Query().Get("workspaceId")
+
+	server.lock.Lock()
+	regulations := make([]backendconfig.WorkspaceRegulationT, 0)
+	for _, regulation := range server.workspaceRegulations {
+		if workspaceID == "" || regulation.WorkspaceID == workspaceID {
+			regulations = append(regulations, regulation)
+		}
+	}
+	server.lock.Unlock()
+
+	writeJSON(w, backendconfig.WorkspaceRegulationsPage(regulations, start, limit))
+}
+
+// sourceRegulationsHandler serves a page of source regulations, filtered by the workspaceId query parameter on the hosted endpoint
+func (server *Server) sourceRegulationsHandler(w http.ResponseWriter, r *http.Request) {
+	start, limit := pageParams(r)
+	workspaceID := r.URL.Query().Get("workspaceId")
+
+	server.lock.Lock()
+	regulations := make([]backendconfig.SourceRegulationT, 0)
+	for _, regulation := range server.sourceRegulations {
+		if workspaceID == "" || regulation.WorkspaceID == workspaceID {
+			regulations = append(regulations, regulation)
+		}
+	}
+	server.lock.Unlock()
+
+	writeJSON(w, backendconfig.SourceRegulationsPage(regulations, start, limit))
+}
+
+func pageParams(r *http.Request) (start int, limit int) {
+	start, _ = strconv.Atoi(r.URL.Query().Get("start"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = backendconfig.DefaultBackendConfigSetup.MaxRegulationsPerRequest
+	}
+	if start < 0 {
+		start = 0
+	}
+	return start, limit
+}
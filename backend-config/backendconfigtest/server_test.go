@@ -0,0 +1,153 @@
+package backendconfigtest_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	backendconfig "github.com/rudderlabs/rudder-platform/backend-config"
+	"github.com/rudderlabs/rudder-platform/backend-config/backendconfigtest"
+)
+
+func testConfig(workspaceID string, writeKey string) backendconfig.ConfigT {
+	return backendconfig.ConfigT{WorkspaceID: workspaceID, Sources: []backendconfig.SourceT{{
+		ID:          "source-" + writeKey,
+		WriteKey:    writeKey,
+		WorkspaceID: workspaceID,
+		Enabled:     true,
+	}}}
+}
+
+func testRegulations(workspaceID string, count int) backendconfig.RegulationsT {
+	regulations := backendconfig.RegulationsT{}
+	for i := 0; i < count; i++ {
+		regulations.WorkspaceRegulations = append(regulations.WorkspaceRegulations, backendconfig.WorkspaceRegulationT{ID: fmt.Sprintf("workspace-regulation-%d", i), RegulationType: "suppress", WorkspaceID: workspaceID, UserID: fmt.Sprintf("user-%d", i)})
+		regulations.SourceRegulations = append(regulations.SourceRegulations, backendconfig.SourceRegulationT{ID: fmt.Sprintf("source-regulation-%d", i), RegulationType: "suppress", WorkspaceID: workspaceID, SourceID: "source-1", UserID: fmt.Sprintf("user-%d", i)})
+	}
+	return regulations
+}
+
+// startInstance starts an instance polling server and waits for its first config and regulations
+func startInstance(t *testing.T, server *backendconfigtest.Server, multiWorkspace bool) *backendconfig.Instance {
+	t.Helper()
+	instance := backendconfig.New(server.BackendConfigSetup(multiWorkspace), nil)
+	instance.Start(true)
+	t.Cleanup(instance.Stop)
+
+	initialized := make(chan struct{})
+	go func() {
+		instance.WaitForConfig()
+		close(initialized)
+	}()
+	select {
+	case <-initialized:
+	case <-time.After(10 * time.Second):
+		t.Fatal("config not published in time")
+	}
+	return instance
+}
+
+func TestServerPagesRegulations(t *testing.T) {
+	tests := []struct {
+		name             string
+		multiWorkspace   bool
+		regulationsPath  string
+		regulationsCount int
+		wantRequests     int
+	}{
+		{name: "single workspace", regulationsPath: "/workspaces/regulations", regulationsCount: 25, wantRequests: 3},
+		{name: "single workspace on a page boundary", regulationsPath: "/workspaces/regulations", regulationsCount: 20, wantRequests: 2},
+		{name: "multi workspace", multiWorkspace: true, regulationsPath: "/hostedWorkspaceRegulations", regulationsCount: 25, wantRequests: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := backendconfigtest.NewServer()
+			defer server.Close()
+			server.PushConfig(testConfig("workspace-1", "write-key-1"))
+			server.PushRegulations(testRegulations("workspace-1", tt.regulationsCount))
+
+			instance := startInstance(t, server, tt.multiWorkspace)
+			regulations := instance.GetCurrentRegulations()
+			if len(regulations.WorkspaceRegulations) != tt.regulationsCount || len(regulations.SourceRegulations) != tt.regulationsCount {
+				t.Errorf("got %d workspace and %d source regulations, want %d", len(regulations.WorkspaceRegulations), len(regulations.SourceRegulations), tt.regulationsCount)
+			}
+			// every poll fetches all pages, the first poll at least wantRequests
+			if got := server.RequestCount(tt.regulationsPath); got < tt.wantRequests {
+				t.Errorf("got %d requests to %s, want at least %d", got, tt.regulationsPath, tt.wantRequests)
+			}
+			if !instance.IsSuppressedUser("workspace-1", "source-1", "user-24") && tt.regulationsCount == 25 {
+				t.Error("user of the last page isn't suppressed")
+			}
+		})
+	}
+}
+
+func TestServerHostedWorkspaces(t *testing.T) {
+	server := backendconfigtest.NewServer()
+	defer server.Close()
+	server.PushConfig(testConfig("workspace-1", "write-key-1"))
+	server.PushConfig(testConfig("workspace-2", "write-key-2"))
+
+	instance := startInstance(t, server, true)
+	for writeKey, workspaceID := range map[string]string{"write-key-1": "workspace-1", "write-key-2": "workspace-2"} {
+		if got := instance.GetWorkspaceIDForWriteKey(writeKey); got != workspaceID {
+			t.Errorf("got workspace %q for %s, want %q", got, writeKey, workspaceID)
+		}
+	}
+
+	server.RemoveWorkspace("workspace-2")
+	deadline := time.Now().Add(10 * time.Second)
+	for len(instance.GetConfig().Sources) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("removed workspace still published: %+v", instance.GetConfig().Sources)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerFailNext(t *testing.T) {
+	server := backendconfigtest.NewServer()
+	defer server.Close()
+	server.PushConfig(testConfig("workspace-1", "write-key-1"))
+	server.FailNext("/workspaceConfig", http.StatusServiceUnavailable, 2)
+
+	instance := startInstance(t, server, false)
+	if got := instance.GetConfig().WorkspaceID; got != "workspace-1" {
+		t.Errorf("got workspace %q", got)
+	}
+	if got := server.RequestCount("/workspaceConfig"); got < 3 {
+		t.Errorf("got %d requests, want the 2 failed ones and a successful one", got)
+	}
+}
+
+func TestServerChecksBasicAuth(t *testing.T) {
+	server := backendconfigtest.NewServer()
+	defer server.Close()
+
+	tests := []struct {
+		path       string
+		user       string
+		wantStatus int
+	}{
+		{path: "/workspaceConfig", user: backendconfigtest.WorkspaceToken, wantStatus: http.StatusOK},
+		{path: "/workspaceConfig", user: backendconfigtest.MultiWorkspaceSecret, wantStatus: http.StatusUnauthorized},
+		{path: "/hostedWorkspaceConfig", user: backendconfigtest.MultiWorkspaceSecret, wantStatus: http.StatusOK},
+		{path: "/hostedWorkspaceConfig", user: "", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		request, err := http.NewRequest(http.MethodGet, server.URL+tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.SetBasicAuth(tt.user, "")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != tt.wantStatus {
+			t.Errorf("%s as %q: got %d, want %d", tt.path, tt.user, response.StatusCode, tt.wantStatus)
+		}
+	}
+}
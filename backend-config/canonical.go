@@ -0,0 +1,50 @@
+package backendconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+/*
+canonicalizeConfig sorts the sources, destinations and libraries of config,
+so that configs differing only in the order returned by the config backend marshal to the same JSON.
+The slices are copied before they are sorted, the fetched ones may be shared with the provider, e.g. the libraries of a workspace.
+Transformations run in the order they are listed and slices inside the free-form Config maps may be meaningful to integrations,
+so both are left untouched. Sorting is stable, so that entries sharing an ID keep the order they were fetched in.
+*/
+func canonicalizeConfig(config *ConfigT) {
+	if config.Sources != nil {
+		config.Sources = append([]SourceT(nil), config.Sources...)
+	}
+	sort.SliceStable(config.Sources, func(i, j int) bool {
+		return config.Sources[i].ID < config.Sources[j].ID
+	})
+	for i := range config.Sources {
+		if config.Sources[i].Destinations == nil {
+			continue
+		}
+		destinations := append([]DestinationT(nil), config.Sources[i].Destinations...)
+		sort.SliceStable(destinations, func(i, j int) bool {
+			return destinations[i].ID < destinations[j].ID
+		})
+		config.Sources[i].Destinations = destinations
+	}
+	if config.Libraries != nil {
+		config.Libraries = append(LibrariesT(nil), config.Libraries...)
+	}
+	sort.SliceStable(config.Libraries, func(i, j int) bool {
+		return config.Libraries[i].VersionID < config.Libraries[j].VersionID
+	})
+}
+
+// computeConfigHash returns a stable content hash of a canonicalized config. Map keys are marshalled in sorted order, so the hash only changes with the content.
+func computeConfigHash(config ConfigT) (string, error) {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(configJSON)
+	return hex.EncodeToString(hash[:]), nil
+}
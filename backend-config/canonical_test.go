@@ -0,0 +1,98 @@
+package backendconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func canonicalHash(t *testing.T, config ConfigT) string {
+	canonicalizeConfig(&config)
+	hash, err := computeConfigHash(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestCanonicalizeConfigOrder(t *testing.T) {
+	config := testSourcesConfig("d1", "d2", "d3")
+	config.Sources[0].Destinations = append(config.Sources[0].Destinations, DestinationT{ID: "d0"})
+	config.Libraries = LibrariesT{{VersionID: "l2"}, {VersionID: "l1"}}
+
+	reordered := copyConfigSources(config)
+	reordered.Sources[0], reordered.Sources[2] = reordered.Sources[2], reordered.Sources[0]
+	destinations := reordered.Sources[2].Destinations
+	destinations[0], destinations[1] = destinations[1], destinations[0]
+	reordered.Libraries = LibrariesT{{VersionID: "l1"}, {VersionID: "l2"}}
+
+	if canonicalHash(t, config) != canonicalHash(t, reordered) {
+		t.Error("configs differing only in the order of sources, destinations and libraries hash differently")
+	}
+
+	canonicalizeConfig(&config)
+	ids := make([]string, 0)
+	for _, source := range config.Sources {
+		ids = append(ids, source.ID)
+	}
+	if want := []string{"source-d1", "source-d2", "source-d3"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("sources sorted as %v, want %v", ids, want)
+	}
+}
+
+func TestCanonicalizeConfigKeepsTransformationOrder(t *testing.T) {
+	config := testSourcesConfig("d1")
+	config.Sources[0].Destinations[0].Transformations = []TransformationT{{VersionID: "v2"}, {VersionID: "v1"}}
+	swapped := testSourcesConfig("d1")
+	swapped.Sources[0].Destinations[0].Transformations = []TransformationT{{VersionID: "v1"}, {VersionID: "v2"}}
+
+	if canonicalHash(t, config) == canonicalHash(t, swapped) {
+		t.Error("reordering transformations must change the config, they run in order")
+	}
+	canonicalizeConfig(&config)
+	if got := config.Sources[0].Destinations[0].Transformations; got[0].VersionID != "v2" || got[1].VersionID != "v1" {
+		t.Errorf("transformations reordered to %v", got)
+	}
+}
+
+func TestCanonicalizeConfigDuplicateIDs(t *testing.T) {
+	config := testSourcesConfig("d1")
+	config.Sources[0].Destinations = []DestinationT{{ID: "d", Name: "first"}, {ID: "c"}, {ID: "d", Name: "second"}, {ID: "d", Name: "third"}}
+
+	canonicalizeConfig(&config)
+	names := make([]string, 0)
+	for _, destination := range config.Sources[0].Destinations {
+		names = append(names, destination.Name)
+	}
+	if want := []string{"", "first", "second", "third"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("destinations sorted as %v, want duplicates in fetched order %v", names, want)
+	}
+}
+
+func TestComputeConfigHashChangesWithContent(t *testing.T) {
+	config := testSourcesConfig("d1")
+	changed := testSourcesConfig("d1")
+	changed.Sources[0].Destinations[0].Config["webhookUrl"] = "https://example.com/other"
+	if canonicalHash(t, config) == canonicalHash(t, changed) {
+		t.Error("a changed destination config must change the hash")
+	}
+	if canonicalHash(t, config) != canonicalHash(t, testSourcesConfig("d1")) {
+		t.Error("equal configs must hash the same")
+	}
+}
+
+func TestCanonicalizeConfigLeavesFetchedSlices(t *testing.T) {
+	config := testSourcesConfig("d2", "d1")
+	config.Sources[0].Destinations = append(config.Sources[0].Destinations, DestinationT{ID: "d0"})
+	// the provider keeps the fetched libraries of each workspace, readers of them must not see them reordered
+	libraries := LibrariesT{{VersionID: "l2"}, {VersionID: "l1"}}
+	config.Libraries = libraries
+	sources, destinations := config.Sources, config.Sources[0].Destinations
+
+	canonicalizeConfig(&config)
+	if config.Libraries[0].VersionID != "l1" || config.Sources[0].ID != "source-d1" || config.Sources[1].Destinations[0].ID != "d0" {
+		t.Fatalf("config not sorted: %+v", config)
+	}
+	if libraries[0].VersionID != "l2" || sources[0].ID != "source-d2" || destinations[0].ID != "d2" {
+		t.Errorf("fetched slices sorted in place: libraries %v, sources %v, destinations %v", libraries, sources[0].ID, destinations[0].ID)
+	}
+}
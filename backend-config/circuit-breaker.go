@@ -0,0 +1,157 @@
+package backendconfig
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+type circuitBreakerState int
+
+const (
+	circuitClosed circuitBreakerState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (state circuitBreakerState) String() string {
+	switch state {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+/*
+circuitBreaker stops requests to the config backend after failureThreshold consecutive failures.
+Once openTimeout has elapsed a single probe request is let through (half-open). Its outcome either closes the circuit again or reopens it.
+*/
+type circuitBreaker struct {
+	name                string
+	failureThreshold    int
+	openTimeout         time.Duration
+	lock                sync.Mutex
+	state               circuitBreakerState
+	consecutiveFailures int
+	openedAt            time.Time
+	stateStat           stats.RudderStats
+}
+
+// CircuitBreakerStatus is a snapshot of a circuit breaker, reported through admin
+type CircuitBreakerStatus struct {
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	OpenedAt            time.Time `json:"openedAt,omitempty"`
+}
+
+func newCircuitBreaker(name string, failureThreshold int, openTimeout time.Duration) *circuitBreaker {
+	cb := &circuitBreaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		stateStat:        stats.NewTaggedStat("config_backend.circuit_breaker_state", stats.GaugeType, map[string]string{"breaker": name}),
+	}
+	cb.stateStat.Gauge(int(circuitClosed))
+	return cb
+}
+
+// allow reports whether a request may be sent to the config backend
+func (cb *circuitBreaker) allow() bool {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if time.Since(cb.openedAt) < cb.openTimeout {
+			return false
+		}
+		cb.setState(circuitHalfOpen)
+		return true
+	default:
+		return true
+	}
+}
+
+func (cb *circuitBreaker) recordSuccess() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.consecutiveFailures = 0
+	if cb.state != circuitClosed {
+		pkgLogger.Infof("[[ Circuit-breaker ]] %s circuit closed", cb.name)
+		cb.setState(circuitClosed)
+	}
+}
+
+func (cb *circuitBreaker) recordFailure() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	cb.consecutiveFailures++
+	if cb.state == circuitHalfOpen || (cb.state == circuitClosed && cb.failureThreshold > 0 && cb.consecutiveFailures >= cb.failureThreshold) {
+		pkgLogger.Errorf("[[ Circuit-breaker ]] %s circuit opened after %d consecutive failures, pausing requests for %v", cb.name, cb.consecutiveFailures, cb.openTimeout)
+		cb.openedAt = time.Now()
+		cb.setState(circuitOpen)
+	}
+}
+
+func (cb *circuitBreaker) status() CircuitBreakerStatus {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	return CircuitBreakerStatus{State: cb.state.String(), ConsecutiveFailures: cb.consecutiveFailures, OpenedAt: cb.openedAt}
+}
+
+// setState must be called with cb.lock held
+func (cb *circuitBreaker) setState(state circuitBreakerState) {
+	cb.state = state
+	cb.stateStat.Gauge(int(state))
+}
+
+/*
+adaptivePollInterval computes the time to wait before the next poll.
+The interval grows towards max while the config stays unchanged or the backend is failing and falls back to base after a change.
+A random jitter is applied so that replicas don't poll the backend in lockstep.
+*/
+type adaptivePollInterval struct {
+	base    time.Duration
+	max     time.Duration
+	jitter  float64
+	current time.Duration
+}
+
+const (
+	unchangedPollGrowthFactor = 1.5
+	failedPollGrowthFactor    = 2
+)
+
+func newAdaptivePollInterval(base time.Duration, max time.Duration, jitter float64) *adaptivePollInterval {
+	if max < base {
+		max = base
+	}
+	return &adaptivePollInterval{base: base, max: max, jitter: jitter, current: base}
+}
+
+func (interval *adaptivePollInterval) next(changed bool, failed bool) time.Duration {
+	switch {
+	case changed:
+		interval.current = interval.base
+	case failed:
+		interval.current = time.Duration(float64(interval.current) * failedPollGrowthFactor)
+	default:
+		interval.current = time.Duration(float64(interval.current) * unchangedPollGrowthFactor)
+	}
+	if interval.current > interval.max {
+		interval.current = interval.max
+	}
+
+	if interval.jitter <= 0 {
+		return interval.current
+	}
+	return time.Duration(float64(interval.current) * (1 + interval.jitter*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,131 @@
+package backendconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cb := newCircuitBreaker("test", 3, 20*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		cb.recordFailure()
+		if !cb.allow() {
+			t.Fatalf("circuit opened after %d failures, threshold is 3", i+1)
+		}
+	}
+	cb.recordSuccess()
+	if status := cb.status(); status.ConsecutiveFailures != 0 || status.State != "closed" {
+		t.Fatalf("status after success = %+v, want closed without failures", status)
+	}
+
+	for i := 0; i < 3; i++ {
+		cb.recordFailure()
+	}
+	if cb.allow() {
+		t.Fatal("circuit still allows requests after 3 consecutive failures")
+	}
+	if state := cb.status().State; state != "open" {
+		t.Fatalf("state = %s, want open", state)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if !cb.allow() {
+		t.Fatal("circuit doesn't let a probe through after its open timeout")
+	}
+	if state := cb.status().State; state != "half-open" {
+		t.Fatalf("state = %s, want half-open", state)
+	}
+	// a failed probe reopens the circuit at once
+	cb.recordFailure()
+	if cb.allow() {
+		t.Fatal("circuit allows requests after a failed probe")
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	cb.allow()
+	cb.recordSuccess()
+	if !cb.allow() || cb.status().State != "closed" {
+		t.Fatalf("circuit isn't closed after a successful probe: %+v", cb.status())
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	cb := newCircuitBreaker("test", 0, time.Minute)
+	for i := 0; i < 100; i++ {
+		cb.recordFailure()
+	}
+	if !cb.allow() {
+		t.Fatal("circuit with a zero threshold must never open")
+	}
+}
+
+func TestAdaptivePollInterval(t *testing.T) {
+	tests := []struct {
+		name  string
+		polls []struct{ changed, failed bool }
+		want  time.Duration
+	}{
+		{name: "unchanged grows by half", polls: []struct{ changed, failed bool }{{false, false}}, want: 1500 * time.Millisecond},
+		{name: "failures double", polls: []struct{ changed, failed bool }{{false, true}, {false, true}}, want: 4 * time.Second},
+		{name: "bounded by max", polls: []struct{ changed, failed bool }{{false, true}, {false, true}, {false, true}, {false, true}}, want: 10 * time.Second},
+		{name: "change resets to base", polls: []struct{ changed, failed bool }{{false, true}, {false, true}, {true, false}}, want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interval := newAdaptivePollInterval(time.Second, 10*time.Second, 0)
+			var got time.Duration
+			for _, poll := range tt.polls {
+				got = interval.next(poll.changed, poll.failed)
+			}
+			if got != tt.want {
+				t.Errorf("interval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptivePollIntervalJitter(t *testing.T) {
+	interval := newAdaptivePollInterval(time.Second, time.Second, 0.1)
+	for i := 0; i < 1000; i++ {
+		if got := interval.next(false, false); got < 900*time.Millisecond || got > 1100*time.Millisecond {
+			t.Fatalf("interval = %v, want within 10%% of 1s", got)
+		}
+	}
+}
+
+func TestAdaptivePollIntervalMaxBelowBase(t *testing.T) {
+	interval := newAdaptivePollInterval(5*time.Second, time.Second, 0)
+	if got := interval.next(false, true); got != 5*time.Second {
+		t.Errorf("interval = %v, a max below base must poll at base", got)
+	}
+}
+
+func TestPartialSetupDefaulted(t *testing.T) {
+	instance := New(BackendConfigSetup{ConfigBackendUrl: "http://localhost", WorkSpaceToken: testWorkspaceToken, PollInterval: time.Second}, nil)
+	setup := instance.setup
+	if setup.PollInterval != time.Second || setup.RegulationsPollInterval != DefaultBackendConfigSetup.RegulationsPollInterval {
+		t.Errorf("got poll intervals %v and %v, want 1s and the default", setup.PollInterval, setup.RegulationsPollInterval)
+	}
+	if setup.MaxPollInterval != DefaultBackendConfigSetup.MaxPollInterval || setup.MaxRegulationsPollInterval != DefaultBackendConfigSetup.MaxRegulationsPollInterval {
+		t.Errorf("got max poll intervals %v and %v, want the defaults", setup.MaxPollInterval, setup.MaxRegulationsPollInterval)
+	}
+	// the poll interval backs off above the set poll interval
+	interval := newAdaptivePollInterval(setup.PollInterval, setup.MaxPollInterval, 0)
+	if next := interval.next(false, true); next <= setup.PollInterval {
+		t.Errorf("got interval %v after a failure, want more than %v", next, setup.PollInterval)
+	}
+
+	for i := 1; i < DefaultBackendConfigSetup.CircuitBreakerFailureThreshold; i++ {
+		instance.configCircuitBreaker.recordFailure()
+		if !instance.configCircuitBreaker.allow() {
+			t.Fatalf("circuit opened after %d failures, want the default threshold", i)
+		}
+	}
+	if status := instance.configCircuitBreaker.status(); status.State != "closed" {
+		t.Errorf("got state %s, want closed", status.State)
+	}
+	if setup.CircuitBreakerOpenTimeout != DefaultBackendConfigSetup.CircuitBreakerOpenTimeout || setup.ConfigBackendEndpointCooldown != DefaultBackendConfigSetup.ConfigBackendEndpointCooldown {
+		t.Errorf("got open timeout %v and endpoint cooldown %v, want the defaults", setup.CircuitBreakerOpenTimeout, setup.ConfigBackendEndpointCooldown)
+	}
+}
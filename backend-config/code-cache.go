@@ -0,0 +1,313 @@
+package backendconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rudderlabs/rudder-utils/rruntime"
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+const (
+	transformationCodeKind = "transformation"
+	libraryCodeKind        = "library"
+)
+
+// codeCachePaths are the config backend paths serving the code of a version, by kind
+var codeCachePaths = map[string]string{
+	transformationCodeKind: "/transformation/getByVersionId?versionId=",
+	libraryCodeKind:        "/transformationLibrary/getByVersionId?versionId=",
+}
+
+/*
+codeCache keeps the transformation and library versions fetched from the config backend on disk.
+Documents are stored once per content under blobs/<sha256>, and versions/<kind>/<versionID> holds the hash of the document of a version.
+Versions are immutable, so a cached version is never fetched again.
+*/
+type codeCache struct {
+	dir      string
+	instance *Instance
+	lock     sync.Mutex
+	// versionLocks holds a lock per version being fetched, so that concurrent requests fetch it once
+	versionLocks map[string]*sync.Mutex
+	// heldBackVersion is the config version held back since heldBackSince, for at most maxHoldBack
+	maxHoldBack     time.Duration
+	heldBackVersion string
+	heldBackSince   time.Time
+}
+
+var (
+	// errCodeVersionNotFound is returned when the config backend doesn't know a version, which retrying won't fix
+	errCodeVersionNotFound = errors.New("version not found")
+	// errCodeVersionRejected is returned when the config backend refuses to serve a version, which retrying won't fix either
+	errCodeVersionRejected = errors.New("version rejected")
+)
+
+// newCodeCache returns a cache in dir fetching versions through instance, nil if dir is empty
+func newCodeCache(dir string, maxHoldBack time.Duration, instance *Instance) *codeCache {
+	if dir == "" {
+		return nil
+	}
+	return &codeCache{dir: dir, instance: instance, maxHoldBack: maxHoldBack, versionLocks: make(map[string]*sync.Mutex)}
+}
+
+// GetTransformationCode returns the transformation version as served by the config backend, fetching it if it isn't cached yet
+func GetTransformationCode(versionID string) ([]byte, error) {
+	return defaultInstance.GetTransformationCode(versionID)
+}
+
+// GetLibraryCode returns the library version as served by the config backend, fetching it if it isn't cached yet
+func GetLibraryCode(versionID string) ([]byte, error) {
+	return defaultInstance.GetLibraryCode(versionID)
+}
+
+// GetTransformationCode returns the transformation version from the cache of the instance
+func (instance *Instance) GetTransformationCode(versionID string) ([]byte, error) {
+	return instance.codeCache.get(transformationCodeKind, versionID)
+}
+
+// GetLibraryCode returns the library version from the cache of the instance
+func (instance *Instance) GetLibraryCode(versionID string) ([]byte, error) {
+	return instance.codeCache.get(libraryCodeKind, versionID)
+}
+
+// get returns a version from the cache, fetching it if needed. A version fetched but not cached is still returned.
+func (cache *codeCache) get(kind string, versionID string) ([]byte, error) {
+	code, _, err := cache.fetch(kind, versionID)
+	return code, err
+}
+
+// fetch returns a version from the cache, fetching it if needed, and reports whether it is cached
+func (cache *codeCache) fetch(kind string, versionID string) ([]byte, bool, error) {
+	if cache == nil {
+		return nil, false, fmt.Errorf("transformation cache is disabled, set TransformationCacheDir to enable it")
+	}
+	if versionID == "" {
+		return nil, false, fmt.Errorf("empty %s version id", kind)
+	}
+
+	if code, ok := cache.read(kind, versionID); ok {
+		stats.NewTaggedStat("config_backend.code_cache", stats.CountType, map[string]string{"kind": kind, "result": "hit"}).Increment()
+		return code, true, nil
+	}
+
+	// a version referenced by several destinations is fetched once
+	versionLock := cache.lockVersion(kind, versionID)
+	defer cache.unlockVersion(kind, versionID, versionLock)
+	if code, ok := cache.read(kind, versionID); ok {
+		return code, true, nil
+	}
+
+	code, err := cache.instance.fetchCodeVersion(kind, versionID)
+	if err != nil {
+		stats.NewTaggedStat("config_backend.code_cache", stats.CountType, map[string]string{"kind": kind, "result": "error"}).Increment()
+		return nil, false, err
+	}
+	stats.NewTaggedStat("config_backend.code_cache", stats.CountType, map[string]string{"kind": kind, "result": "miss"}).Increment()
+	if err = cache.write(kind, versionID, code); err != nil {
+		pkgLogger.Errorf("Unable to cache %s version %s: %s", kind, versionID, err.Error())
+		return code, false, nil
+	}
+	return code, true, nil
+}
+
+// lockVersion locks the fetching of a version
+func (cache *codeCache) lockVersion(kind string, versionID string) *sync.Mutex {
+	cache.lock.Lock()
+	key := kind + "/" + versionID
+	versionLock, ok := cache.versionLocks[key]
+	if !ok {
+		versionLock = &sync.Mutex{}
+		cache.versionLocks[key] = versionLock
+	}
+	cache.lock.Unlock()
+	versionLock.Lock()
+	return versionLock
+}
+
+// unlockVersion unlocks the fetching of a version, dropping its lock once the fetch is done so that versionLocks doesn't grow with every version ever fetched
+func (cache *codeCache) unlockVersion(kind string, versionID string, versionLock *sync.Mutex) {
+	cache.lock.Lock()
+	key := kind + "/" + versionID
+	if cache.versionLocks[key] == versionLock {
+		delete(cache.versionLocks, key)
+	}
+	cache.lock.Unlock()
+	versionLock.Unlock()
+}
+
+// read returns the cached document of a version, ignoring blobs that don't match their hash
+func (cache *codeCache) read(kind string, versionID string) ([]byte, bool) {
+	hash, err := IoUtil.ReadFile(cache.versionPath(kind, versionID))
+	if err != nil {
+		return nil, false
+	}
+	code, err := IoUtil.ReadFile(filepath.Join(cache.dir, "blobs", string(hash)))
+	if err != nil || codeHash(code) != string(hash) {
+		return nil, false
+	}
+	return code, true
+}
+
+func (cache *codeCache) write(kind string, versionID string, code []byte) error {
+	hash := codeHash(code)
+	if err := writeFileAtomically(filepath.Join(cache.dir, "blobs"), hash, code); err != nil {
+		return err
+	}
+	return writeFileAtomically(filepath.Dir(cache.versionPath(kind, versionID)), filepath.Base(cache.versionPath(kind, versionID)), []byte(hash))
+}
+
+func (cache *codeCache) versionPath(kind string, versionID string) string {
+	// version ids are generated by the control plane, escape them anyway as they become file names
+	return filepath.Join(cache.dir, "versions", kind, url.PathEscape(versionID))
+}
+
+func codeHash(code []byte) string {
+	hash := sha256.Sum256(code)
+	return hex.EncodeToString(hash[:])
+}
+
+func (instance *Instance) fetchCodeVersion(kind string, versionID string) ([]byte, error) {
+	requester, ok := instance.provider.(interface {
+		makeHTTPRequest(url string) ([]byte, int, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("backend config can't fetch %s versions", kind)
+	}
+
+	respBody, statusCode, err := instance.endpoints.get(codeCachePaths[kind]+url.QueryEscape(versionID), requester.makeHTTPRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s version %s: %w", kind, versionID, err)
+	}
+	if statusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("failed to fetch %s version %s: %w", kind, versionID, errCodeVersionNotFound)
+	}
+	if statusCode >= 400 && statusCode < 500 && statusCode != http.StatusTooManyRequests {
+		return nil, fmt.Errorf("failed to fetch %s version %s. statusCode: %v: %w", kind, versionID, statusCode, errCodeVersionRejected)
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		return nil, fmt.Errorf("failed to fetch %s version %s. statusCode: %v", kind, versionID, statusCode)
+	}
+	return respBody, nil
+}
+
+/*
+prefetchCode fetches the transformation and library versions referenced by config that aren't cached yet, so that they are
+ready before config is published. It fails if any version couldn't be fetched or cached, except for versions the config backend
+doesn't know or refuses to serve, which retrying won't fix.
+*/
+func (cache *codeCache) prefetchCode(config ConfigT) error {
+	if cache == nil {
+		return nil
+	}
+
+	versions := make(map[string]string)
+	for _, source := range config.Sources {
+		for _, destination := range source.Destinations {
+			for _, transformation := range destination.Transformations {
+				versions[transformationCodeKind+"/"+transformation.VersionID] = transformation.VersionID
+			}
+		}
+		for _, library := range cache.instance.GetWorkspaceLibrariesForWorkspaceID(source.WorkspaceID) {
+			versions[libraryCodeKind+"/"+library.VersionID] = library.VersionID
+		}
+	}
+	for _, library := range config.Libraries {
+		versions[libraryCodeKind+"/"+library.VersionID] = library.VersionID
+	}
+
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	failed := make([]string, 0)
+	// bound the number of concurrent requests to the config backend
+	semaphore := make(chan struct{}, 8)
+	for key, versionID := range versions {
+		if versionID == "" {
+			continue
+		}
+		kind := strings.SplitN(key, "/", 2)[0]
+		if _, ok := cache.read(kind, versionID); ok {
+			continue
+		}
+
+		kind, versionID := kind, versionID
+		wg.Add(1)
+		semaphore <- struct{}{}
+		rruntime.Go(func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			_, cached, err := cache.fetch(kind, versionID)
+			if err == nil && cached {
+				return
+			}
+			retryable := !errors.Is(err, errCodeVersionNotFound) && !errors.Is(err, errCodeVersionRejected)
+			if err != nil {
+				pkgLogger.Errorf("Unable to prefetch %s version %s: %s", kind, versionID, err.Error())
+			}
+			stats.NewTaggedStat("config_backend.code_prefetch_errors", stats.CountType, map[string]string{"kind": kind, "retryable": fmt.Sprint(retryable)}).Increment()
+			if retryable {
+				errLock.Lock()
+				failed = append(failed, kind+" "+versionID)
+				errLock.Unlock()
+			}
+		}, cache.instance.setup.ErrorFilePath)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to prefetch %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+/*
+holdBack reports whether a config version must be held back after prefetching its code failed with err. A version is held back for
+at most maxHoldBack since its first failure, so that a config backend failing to serve code doesn't freeze config updates forever.
+A nil err ends the hold back.
+*/
+func (cache *codeCache) holdBack(version string, err error, now time.Time) bool {
+	if cache == nil {
+		return false
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if err == nil {
+		cache.heldBackVersion = ""
+		return false
+	}
+	if cache.heldBackVersion != version {
+		cache.heldBackVersion, cache.heldBackSince = version, now
+	}
+	return now.Sub(cache.heldBackSince) < cache.maxHoldBack
+}
+
+// writeFileAtomically writes data to dir/name through a temporary file, so that readers never see a partially written file
+func writeFileAtomically(dir string, name string, data []byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filepath.Join(dir, name))
+}
@@ -0,0 +1,134 @@
+package backendconfig
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCodeCacheHoldBack(t *testing.T) {
+	start := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	errPrefetch := errors.New("failed to prefetch transformation 1")
+	steps := []struct {
+		name    string
+		version string
+		err     error
+		at      time.Duration
+		want    bool
+	}{
+		{name: "first failure", version: "v1", err: errPrefetch, at: 0, want: true},
+		{name: "still failing", version: "v1", err: errPrefetch, at: 9 * time.Minute, want: true},
+		{name: "hold back expired", version: "v1", err: errPrefetch, at: 10 * time.Minute, want: false},
+		{name: "new version held back again", version: "v2", err: errPrefetch, at: 11 * time.Minute, want: true},
+		{name: "success", version: "v2", err: nil, at: 12 * time.Minute, want: false},
+		{name: "earlier version held back from scratch", version: "v1", err: errPrefetch, at: 30 * time.Minute, want: true},
+	}
+	cache := newCodeCache(t.TempDir(), 10*time.Minute, nil)
+	for _, step := range steps {
+		if got := cache.holdBack(step.version, step.err, start.Add(step.at)); got != step.want {
+			t.Errorf("%s: held back %t, want %t", step.name, got, step.want)
+		}
+	}
+
+	var disabled *codeCache
+	if disabled.holdBack("v1", errPrefetch, start) {
+		t.Error("disabled cache held back a config")
+	}
+}
+
+func TestPrefetchCode(t *testing.T) {
+	tests := []struct {
+		name           string
+		code           string
+		codeStatusCode int
+		wantErr        bool
+		wantCached     bool
+	}{
+		{name: "fetched", code: `{"code": "1"}`, wantCached: true},
+		{name: "not found", wantErr: false},
+		{name: "rejected", codeStatusCode: http.StatusForbidden, wantErr: false},
+		{name: "throttled", codeStatusCode: http.StatusTooManyRequests, wantErr: true},
+		{name: "unavailable", codeStatusCode: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestConfigBackend(t, testSourcesConfig("destination-1"))
+			if tt.code != "" {
+				backend.setCode("transformation-1", tt.code)
+			}
+			backend.setCodeStatusCode(tt.codeStatusCode)
+			instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+				setup.TransformationCacheDir = t.TempDir()
+			})
+			config := testSourcesConfig("destination-1")
+			config.Sources[0].Destinations[0].Transformations = []TransformationT{{VersionID: "transformation-1"}}
+
+			if err := instance.codeCache.prefetchCode(config); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if _, cached := instance.codeCache.read(transformationCodeKind, "transformation-1"); cached != tt.wantCached {
+				t.Errorf("cached %t, want %t", cached, tt.wantCached)
+			}
+			if len(instance.codeCache.versionLocks) != 0 {
+				t.Errorf("%d version locks left after prefetching", len(instance.codeCache.versionLocks))
+			}
+		})
+	}
+}
+
+func TestPrefetchCodeWriteFailure(t *testing.T) {
+	backend := newTestConfigBackend(t, testSourcesConfig("destination-1"))
+	backend.setCode("transformation-1", `{"code": "1"}`)
+	// a directory can't be created below a file, whatever the permissions of the user running the test
+	file := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(file, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+		setup.TransformationCacheDir = filepath.Join(file, "cache")
+	})
+	config := testSourcesConfig("destination-1")
+	config.Sources[0].Destinations[0].Transformations = []TransformationT{{VersionID: "transformation-1"}}
+
+	if err := instance.codeCache.prefetchCode(config); err == nil {
+		t.Error("prefetch succeeded although the version couldn't be cached")
+	}
+	// the version is still served to processors asking for it
+	if code, err := instance.GetTransformationCode("transformation-1"); err != nil || string(code) != `{"code": "1"}` {
+		t.Errorf("got %q, %v", code, err)
+	}
+}
+
+func TestConfigHeldBackWhileCodeUnavailable(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxHoldBack   time.Duration
+		wantPublished bool
+	}{
+		{name: "held back", maxHoldBack: time.Hour, wantPublished: false},
+		{name: "hold back expired", maxHoldBack: 0, wantPublished: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testSourcesConfig("destination-1")
+			config.Sources[0].Destinations[0].Transformations = []TransformationT{{VersionID: "transformation-1"}}
+			backend := newTestConfigBackend(t, config)
+			backend.setCodeStatusCode(http.StatusInternalServerError)
+			instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+				setup.TransformationCacheDir = t.TempDir()
+				setup.TransformationPrefetchMaxHoldBack = tt.maxHoldBack
+			})
+
+			changed, ok := instance.configUpdate(testStatConfigBackendError)
+			if changed != tt.wantPublished || ok != tt.wantPublished {
+				t.Errorf("changed %t, ok %t, want %t", changed, ok, tt.wantPublished)
+			}
+			if published := len(instance.GetConfig().Sources) > 0; published != tt.wantPublished {
+				t.Errorf("published %t, want %t", published, tt.wantPublished)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package backendconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// configSchemaKey is the key of the definition config declaring the config keys of its sources or destinations
+	configSchemaKey = "configSchema"
+	// configSchemaDefaultKey is the key of a config schema property holding the value used when the key isn't configured
+	configSchemaDefaultKey = "default"
+	// configSchemaTypeKey is the key of a config schema property holding its JSON schema type
+	configSchemaTypeKey = "type"
+)
+
+// ErrConfigKeyMissing is wrapped by ConfigKeyError when a key is neither configured nor has a default
+var ErrConfigKeyMissing = errors.New("key is not configured")
+
+// ConfigKeyError is returned by the config accessors of sources and destinations
+type ConfigKeyError struct {
+	// Kind is "source" or "destination"
+	Kind string
+	ID   string
+	// Key is empty for errors of DecodeConfig
+	Key string
+	Err error
+}
+
+func (err *ConfigKeyError) Error() string {
+	if err.Key == "" {
+		return fmt.Sprintf("%s %s: config: %s", err.Kind, err.ID, err.Err.Error())
+	}
+	return fmt.Sprintf("%s %s: config key %q: %s", err.Kind, err.ID, err.Key, err.Err.Error())
+}
+
+func (err *ConfigKeyError) Unwrap() error {
+	return err.Err
+}
+
+/*
+configAccessor reads typed values from a source or destination config.
+A key that isn't configured, or is configured as null, falls back to the default declared by the definition config:
+
+	"configSchema": {"properties": {"timeout": {"type": "string", "default": "10s"}}}
+
+Values are converted leniently where the control plane is known to be inconsistent, e.g. booleans sent as "true", by a single
+policy, coerceConfigValue. It converts a value to the type declared by the schema of its key, if any, then to the type asked for.
+*/
+type configAccessor struct {
+	kind             string
+	id               string
+	config           map[string]interface{}
+	definitionConfig map[string]interface{}
+}
+
+// GetString returns the string configured for key
+func (destination DestinationT) GetString(key string) (string, error) {
+	return destination.configAccessor().getString(key)
+}
+
+// GetBool returns the boolean configured for key, which may also be configured as a string like "true"
+func (destination DestinationT) GetBool(key string) (bool, error) {
+	return destination.configAccessor().getBool(key)
+}
+
+// GetFloat64 returns the number configured for key, which may also be configured as a numeric string
+func (destination DestinationT) GetFloat64(key string) (float64, error) {
+	return destination.configAccessor().getFloat64(key)
+}
+
+// GetStringSlice returns the array of strings configured for key
+func (destination DestinationT) GetStringSlice(key string) ([]string, error) {
+	return destination.configAccessor().getStringSlice(key)
+}
+
+// GetDuration returns the duration configured for key, either a string like "1m30s" or a number of seconds
+func (destination DestinationT) GetDuration(key string) (time.Duration, error) {
+	return destination.configAccessor().getDuration(key)
+}
+
+// DecodeConfig decodes the destination config, with the defaults of its definition applied, into the struct pointed to by v
+func (destination DestinationT) DecodeConfig(v interface{}) error {
+	return destination.configAccessor().decode(v)
+}
+
+func (destination DestinationT) configAccessor() configAccessor {
+	return configAccessor{kind: "destination", id: destination.ID, config: destination.Config, definitionConfig: destination.DestinationDefinition.Config}
+}
+
+// GetString returns the string configured for key
+func (source SourceT) GetString(key string) (string, error) {
+	return source.configAccessor().getString(key)
+}
+
+// GetBool returns the boolean configured for key, which may also be configured as a string like "true"
+func (source SourceT) GetBool(key string) (bool, error) {
+	return source.configAccessor().getBool(key)
+}
+
+// GetFloat64 returns the number configured for key, which may also be configured as a numeric string
+func (source SourceT) GetFloat64(key string) (float64, error) {
+	return source.configAccessor().getFloat64(key)
+}
+
+// GetStringSlice returns the array of strings configured for key
+func (source SourceT) GetStringSlice(key string) ([]string, error) {
+	return source.configAccessor().getStringSlice(key)
+}
+
+// GetDuration returns the duration configured for key, either a string like "1m30s" or a number of seconds
+func (source SourceT) GetDuration(key string) (time.Duration, error) {
+	return source.configAccessor().getDuration(key)
+}
+
+// DecodeConfig decodes the source config, with the defaults of its definition applied, into the struct pointed to by v
+func (source SourceT) DecodeConfig(v interface{}) error {
+	return source.configAccessor().decode(v)
+}
+
+func (source SourceT) configAccessor() configAccessor {
+	return configAccessor{kind: "source", id: source.ID, config: source.Config, definitionConfig: source.SourceDefinition.Config}
+}
+
+func (accessor configAccessor) getString(key string) (string, error) {
+	value, err := accessor.lookup(key)
+	if err != nil {
+		return "", err
+	}
+	s, ok := coerceConfigValue(value, "string")
+	if !ok {
+		return "", accessor.typeError(key, "a string", value)
+	}
+	return s.(string), nil
+}
+
+func (accessor configAccessor) getBool(key string) (bool, error) {
+	value, err := accessor.lookup(key)
+	if err != nil {
+		return false, err
+	}
+	b, ok := coerceConfigValue(value, "boolean")
+	if !ok {
+		return false, accessor.typeError(key, "a boolean", value)
+	}
+	return b.(bool), nil
+}
+
+func (accessor configAccessor) getFloat64(key string) (float64, error) {
+	value, err := accessor.lookup(key)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := coerceConfigValue(value, "number")
+	if !ok {
+		return 0, accessor.typeError(key, "a number", value)
+	}
+	return f.(float64), nil
+}
+
+func (accessor configAccessor) getStringSlice(key string) ([]string, error) {
+	value, err := accessor.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case []string:
+		return append([]string{}, v...), nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for i, element := range v {
+			s, ok := element.(string)
+			if !ok {
+				return nil, accessor.typeError(fmt.Sprintf("%s[%d]", key, i), "a string", element)
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	return nil, accessor.typeError(key, "an array of strings", value)
+}
+
+func (accessor configAccessor) getDuration(key string) (time.Duration, error) {
+	value, err := accessor.lookup(key)
+	if err != nil {
+		return 0, err
+	}
+	if s, ok := value.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d, nil
+		}
+	}
+	// a number of seconds, which may be a numeric string like any other number
+	seconds, ok := coerceConfigValue(value, "number")
+	if !ok {
+		return 0, accessor.typeError(key, `a duration like "1m30s" or a number of seconds`, value)
+	}
+	return time.Duration(seconds.(float64) * float64(time.Second)), nil
+}
+
+// decode round trips the config through JSON, so that v is decoded with the usual json tags and case-insensitive matching
+func (accessor configAccessor) decode(v interface{}) error {
+	config := make(map[string]interface{})
+	for key, property := range accessor.schemaProperties() {
+		if defaultValue, ok := property[configSchemaDefaultKey]; ok {
+			config[key] = defaultValue
+		}
+	}
+	for key, value := range accessor.config {
+		if value != nil {
+			config[key] = value
+		}
+	}
+	// values that can't be coerced are left for json.Unmarshal to report
+	for key, property := range accessor.schemaProperties() {
+		if value, ok := config[key]; ok {
+			if coerced, ok := coerceConfigValue(value, schemaType(property)); ok {
+				config[key] = coerced
+			}
+		}
+	}
+
+	data, err := json.Marshal(config)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return &ConfigKeyError{Kind: accessor.kind, ID: accessor.id, Err: err}
+	}
+	return nil
+}
+
+// lookup returns the value configured for key, or the default declared by the definition, coerced to the type declared by the schema
+func (accessor configAccessor) lookup(key string) (interface{}, error) {
+	property := accessor.schemaProperties()[key]
+	value, ok := accessor.config[key]
+	if !ok || value == nil {
+		if value, ok = property[configSchemaDefaultKey]; !ok || value == nil {
+			return nil, &ConfigKeyError{Kind: accessor.kind, ID: accessor.id, Key: key, Err: ErrConfigKeyMissing}
+		}
+	}
+	declaredType := schemaType(property)
+	coerced, ok := coerceConfigValue(value, declaredType)
+	if !ok {
+		return nil, accessor.typeError(key, "a "+declaredType+" as declared by the config schema", value)
+	}
+	return coerced, nil
+}
+
+// schemaType returns the JSON schema type declared by a config schema property, empty if none
+func schemaType(property map[string]interface{}) string {
+	declaredType, _ := property[configSchemaTypeKey].(string)
+	return declaredType
+}
+
+/*
+coerceConfigValue converts a config value to a JSON schema type: "string", "boolean", "number" or "integer". Numeric strings are
+numbers, "true" and "false" are booleans, and numbers and booleans are strings. Other types, like arrays, and values without a
+type are returned as they are. It reports whether value could be converted.
+*/
+func coerceConfigValue(value interface{}, schemaType string) (interface{}, bool) {
+	switch schemaType {
+	case "string":
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case bool:
+			return strconv.FormatBool(v), true
+		case json.Number:
+			return v.String(), true
+		}
+		if f, ok := configNumber(value); ok {
+			return strconv.FormatFloat(f, 'f', -1, 64), true
+		}
+		return nil, false
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, true
+			}
+		}
+		return nil, false
+	case "number", "integer":
+		f, ok := configNumber(value)
+		if !ok || (schemaType == "integer" && f != math.Trunc(f)) {
+			return nil, false
+		}
+		return f, true
+	}
+	return value, true
+}
+
+// configNumber returns value as a float64 if it is a number or a numeric string
+func configNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// schemaProperties returns the properties of the configSchema of the definition, ignoring malformed entries
+func (accessor configAccessor) schemaProperties() map[string]map[string]interface{} {
+	properties := make(map[string]map[string]interface{})
+	schema, _ := accessor.definitionConfig[configSchemaKey].(map[string]interface{})
+	declared, _ := schema["properties"].(map[string]interface{})
+	for key, value := range declared {
+		if property, ok := value.(map[string]interface{}); ok {
+			properties[key] = property
+		}
+	}
+	return properties
+}
+
+func (accessor configAccessor) typeError(key string, expected string, value interface{}) error {
+	return &ConfigKeyError{Kind: accessor.kind, ID: accessor.id, Key: key, Err: fmt.Errorf("expected %s, got %T %v", expected, value, value)}
+}
@@ -0,0 +1,116 @@
+package backendconfig
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testAccessorDestination(config map[string]interface{}) DestinationT {
+	return DestinationT{
+		ID:     "destination-1",
+		Config: config,
+		DestinationDefinition: DestinationDefinitionT{Config: map[string]interface{}{
+			configSchemaKey: map[string]interface{}{"properties": map[string]interface{}{
+				"timeout":  map[string]interface{}{"type": "string", "default": "10s"},
+				"retries":  map[string]interface{}{"type": "integer", "default": 3.0},
+				"ratio":    map[string]interface{}{"type": "number"},
+				"enabled":  map[string]interface{}{"type": "boolean", "default": "true"},
+				"port":     map[string]interface{}{"type": "string"},
+				"untyped":  map[string]interface{}{"default": 1.5},
+				"interval": map[string]interface{}{"type": "number", "default": "30"},
+			}},
+		}},
+	}
+}
+
+func TestConfigAccessorCoercion(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		get     func(destination DestinationT) (interface{}, error)
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "string", config: map[string]interface{}{"timeout": "5s"}, get: getString("timeout"), want: "5s"},
+		{name: "string default", get: getString("timeout"), want: "10s"},
+		{name: "number declared as string", config: map[string]interface{}{"port": 5432.0}, get: getString("port"), want: "5432"},
+		{name: "boolean as string", config: map[string]interface{}{"untyped": true}, get: getString("untyped"), want: "true"},
+		{name: "array as string", config: map[string]interface{}{"untyped": []interface{}{"a"}}, get: getString("untyped"), wantErr: true},
+
+		{name: "boolean", config: map[string]interface{}{"enabled": false}, get: getBool("enabled"), want: false},
+		{name: "boolean string default", get: getBool("enabled"), want: true},
+		{name: "invalid boolean", config: map[string]interface{}{"enabled": "maybe"}, get: getBool("enabled"), wantErr: true},
+
+		{name: "number", config: map[string]interface{}{"ratio": 0.5}, get: getFloat64("ratio"), want: 0.5},
+		{name: "numeric string", config: map[string]interface{}{"ratio": "0.5"}, get: getFloat64("ratio"), want: 0.5},
+		{name: "integer", config: map[string]interface{}{"retries": "5"}, get: getFloat64("retries"), want: 5.0},
+		{name: "fractional integer", config: map[string]interface{}{"retries": 2.5}, get: getFloat64("retries"), wantErr: true},
+		{name: "number declared as string read as number", config: map[string]interface{}{"port": 5432.0}, get: getFloat64("port"), want: 5432.0},
+		{name: "untyped default", get: getFloat64("untyped"), want: 1.5},
+		{name: "not a number", config: map[string]interface{}{"ratio": "half"}, get: getFloat64("ratio"), wantErr: true},
+
+		{name: "duration", config: map[string]interface{}{"timeout": "1m30s"}, get: getDuration("timeout"), want: 90 * time.Second},
+		{name: "duration default", get: getDuration("timeout"), want: 10 * time.Second},
+		{name: "duration in seconds", config: map[string]interface{}{"ratio": 1.5}, get: getDuration("ratio"), want: 1500 * time.Millisecond},
+		{name: "duration as numeric string", config: map[string]interface{}{"timeout": "30"}, get: getDuration("timeout"), want: 30 * time.Second},
+		{name: "duration as numeric string default", get: getDuration("interval"), want: 30 * time.Second},
+		{name: "invalid duration", config: map[string]interface{}{"timeout": "soon"}, get: getDuration("timeout"), wantErr: true},
+
+		{name: "missing", get: getString("missing"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get(testAccessorDestination(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigAccessorMissingKey(t *testing.T) {
+	_, err := testAccessorDestination(nil).GetString("missing")
+	var keyErr *ConfigKeyError
+	if !errors.As(err, &keyErr) || keyErr.Key != "missing" || !errors.Is(err, ErrConfigKeyMissing) {
+		t.Errorf("got %v, want a missing key error", err)
+	}
+}
+
+func TestDecodeConfigCoercion(t *testing.T) {
+	var decoded struct {
+		Timeout  string  `json:"timeout"`
+		Retries  int     `json:"retries"`
+		Ratio    float64 `json:"ratio"`
+		Enabled  bool    `json:"enabled"`
+		Port     string  `json:"port"`
+		Interval float64 `json:"interval"`
+	}
+	destination := testAccessorDestination(map[string]interface{}{"retries": "5", "ratio": "0.25", "port": 5432.0})
+	if err := destination.DecodeConfig(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Timeout != "10s" || decoded.Retries != 5 || decoded.Ratio != 0.25 || !decoded.Enabled || decoded.Port != "5432" || decoded.Interval != 30 {
+		t.Errorf("unexpected decoded config %+v", decoded)
+	}
+}
+
+func getString(key string) func(destination DestinationT) (interface{}, error) {
+	return func(destination DestinationT) (interface{}, error) { return destination.GetString(key) }
+}
+
+func getBool(key string) func(destination DestinationT) (interface{}, error) {
+	return func(destination DestinationT) (interface{}, error) { return destination.GetBool(key) }
+}
+
+func getFloat64(key string) func(destination DestinationT) (interface{}, error) {
+	return func(destination DestinationT) (interface{}, error) { return destination.GetFloat64(key) }
+}
+
+func getDuration(key string) func(destination DestinationT) (interface{}, error) {
+	return func(destination DestinationT) (interface{}, error) { return destination.GetDuration(key) }
+}
@@ -0,0 +1,277 @@
+package backendconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+const (
+	// IssueSchedule is a destination schedule with an invalid window, clock time, day or timezone
+	IssueSchedule = "schedule"
+
+	// clockLayout is the layout of the start and end of recurring blackouts
+	clockLayout = "15:04"
+
+	// maxScheduleWait bounds how long pollDestinationSchedules sleeps when no schedule is due to change, in case the wall clock jumps
+	maxScheduleWait = time.Hour
+)
+
+/*
+DestinationScheduleT pauses the delivery of a destination at given times. It is set in the config of the destination or in the
+config overlay, which replaces it. While a schedule pauses a destination, the destination is published on TopicProcessConfig
+with Enabled set to false, and is published again as configured when the pause ends. TopicBackendConfig is left as configured.
+
+	"schedule": {
+		"enableAt": "2021-06-01T08:00:00Z",
+		"pauses": [{"start": "2021-05-20T22:00:00Z", "end": "2021-05-21T02:00:00Z"}],
+		"blackouts": [{"days": ["sat", "sun"], "start": "22:00", "end": "06:00", "timezone": "Europe/Berlin"}]
+	}
+
+A schedule never enables a destination that isn't enabled.
+*/
+type DestinationScheduleT struct {
+	// EnableAt pauses the destination until then
+	EnableAt *time.Time `json:"enableAt,omitempty"`
+	// DisableAt pauses the destination from then on
+	DisableAt *time.Time `json:"disableAt,omitempty"`
+	// Pauses are one-off maintenance windows
+	Pauses []MaintenanceWindowT `json:"pauses"`
+	// Blackouts are maintenance windows recurring every day, or on the given days of the week
+	Blackouts []RecurringBlackoutT `json:"blackouts"`
+}
+
+// MaintenanceWindowT pauses a destination from Start until End
+type MaintenanceWindowT struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+/*
+RecurringBlackoutT pauses a destination from Start until End, given as "15:04" in Timezone, UTC if empty. Days are week days,
+e.g. "monday" or "mon", on which the blackout starts, every day if empty. An End that isn't after Start is on the next day.
+*/
+type RecurringBlackoutT struct {
+	Days     []string `json:"days"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Timezone string   `json:"timezone"`
+}
+
+// blackoutWindowT is an occurrence of a RecurringBlackoutT
+type blackoutWindowT struct {
+	start time.Time
+	end   time.Time
+}
+
+/*
+pausedAt reports whether schedule pauses its destination at now, and the next time after now at which that may change,
+zero if it never does. Invalid blackouts are ignored, they are reported by reportDestinationScheduleIssues.
+*/
+func (schedule *DestinationScheduleT) pausedAt(now time.Time, locations scheduleLocations) (paused bool, next time.Time) {
+	if schedule == nil {
+		return false, time.Time{}
+	}
+	transition := func(t time.Time) {
+		if t.After(now) && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+
+	if schedule.EnableAt != nil {
+		paused = paused || now.Before(*schedule.EnableAt)
+		transition(*schedule.EnableAt)
+	}
+	if schedule.DisableAt != nil {
+		paused = paused || !now.Before(*schedule.DisableAt)
+		transition(*schedule.DisableAt)
+	}
+	for _, pause := range schedule.Pauses {
+		paused = paused || (!now.Before(pause.Start) && now.Before(pause.End))
+		transition(pause.Start)
+		transition(pause.End)
+	}
+	for _, blackout := range schedule.Blackouts {
+		windows, err := blackout.windows(now, locations)
+		if err != nil {
+			continue
+		}
+		for _, window := range windows {
+			paused = paused || (!now.Before(window.start) && now.Before(window.end))
+			transition(window.start)
+			transition(window.end)
+		}
+	}
+	return paused, next
+}
+
+// windows returns the occurrences of blackout starting from the day before now up to a week after it, in the timezone of the blackout
+func (blackout RecurringBlackoutT) windows(now time.Time, locations scheduleLocations) ([]blackoutWindowT, error) {
+	location, err := locations.load(blackout.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	start, err := time.Parse(clockLayout, blackout.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse(clockLayout, blackout.End)
+	if err != nil {
+		return nil, err
+	}
+	days := make(map[time.Weekday]bool)
+	for _, day := range blackout.Days {
+		weekday, ok := parseWeekday(day)
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", day)
+		}
+		days[weekday] = true
+	}
+
+	local := now.In(location)
+	windows := make([]blackoutWindowT, 0)
+	for offset := -1; offset <= 7; offset++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, location)
+		if len(days) > 0 && !days[day.Weekday()] {
+			continue
+		}
+		window := blackoutWindowT{
+			start: time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, location),
+			end:   time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, location),
+		}
+		if !window.end.After(window.start) {
+			window.end = time.Date(day.Year(), day.Month(), day.Day()+1, end.Hour(), end.Minute(), 0, 0, location)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// scheduleLocations holds the timezones of the blackouts of a published config, so that they are loaded once per config instead of on every evaluation
+type scheduleLocations map[string]*time.Location
+
+// loadScheduleLocations loads the timezones of the blackouts of config. Invalid timezones are held as nil.
+func loadScheduleLocations(config ConfigT) scheduleLocations {
+	locations := make(scheduleLocations)
+	for _, source := range config.Sources {
+		for _, destination := range source.Destinations {
+			if destination.Schedule == nil {
+				continue
+			}
+			for _, blackout := range destination.Schedule.Blackouts {
+				if _, ok := locations[blackout.Timezone]; !ok {
+					// invalid timezones are reported by reportDestinationScheduleIssues
+					locations[blackout.Timezone], _ = time.LoadLocation(blackout.Timezone)
+				}
+			}
+		}
+	}
+	return locations
+}
+
+// load returns the location of timezone, loading it if locations don't hold it
+func (locations scheduleLocations) load(timezone string) (*time.Location, error) {
+	location, ok := locations[timezone]
+	if !ok {
+		return time.LoadLocation(timezone)
+	}
+	if location == nil {
+		return nil, fmt.Errorf("unknown time zone %s", timezone)
+	}
+	return location, nil
+}
+
+// parseWeekday parses the name of a week day regardless of case, in full or as its first three letters
+func parseWeekday(value string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := weekday.String()
+		if strings.EqualFold(name, value) || strings.EqualFold(name[:3], value) {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
+
+/*
+applyDestinationSchedules disables the destinations of config paused by their schedule at now. It returns the IDs of the paused
+destinations and the next time a schedule may change, zero if none. config is modified in place, so its destination slices
+must not be shared with the current config, e.g. config is returned by filterProcessorEnabledDestinations.
+*/
+func applyDestinationSchedules(config ConfigT, locations scheduleLocations, now time.Time) (pausedIDs []string, next time.Time) {
+	pausedIDs = make([]string, 0)
+	for i := range config.Sources {
+		for j := range config.Sources[i].Destinations {
+			destination := &config.Sources[i].Destinations[j]
+			paused, destinationNext := destination.Schedule.pausedAt(now, locations)
+			if paused && destination.Enabled {
+				destination.Enabled = false
+				pausedIDs = append(pausedIDs, destination.ID)
+			}
+			if !destinationNext.IsZero() && (next.IsZero() || destinationNext.Before(next)) {
+				next = destinationNext
+			}
+		}
+	}
+	sort.Strings(pausedIDs)
+	return pausedIDs, next
+}
+
+// ValidateDestinationSchedule checks the windows of a destination schedule, prefixing the paths of the issues with path
+func ValidateDestinationSchedule(schedule DestinationScheduleT, path string) []ValidationIssueT {
+	issues := make([]ValidationIssueT, 0)
+	if schedule.EnableAt != nil && schedule.DisableAt != nil && !schedule.DisableAt.After(*schedule.EnableAt) {
+		issues = append(issues, ValidationIssueT{Kind: IssueSchedule, Path: path + ".disableAt", Message: "destination is disabled before it is enabled, it is never delivered to"})
+	}
+	for i, pause := range schedule.Pauses {
+		if !pause.End.After(pause.Start) {
+			issues = append(issues, ValidationIssueT{Kind: IssueSchedule, Path: fmt.Sprintf("%s.pauses[%d]", path, i), Message: "pause doesn't end after it starts"})
+		}
+	}
+	for i, blackout := range schedule.Blackouts {
+		blackoutPath := fmt.Sprintf("%s.blackouts[%d]", path, i)
+		if _, err := time.LoadLocation(blackout.Timezone); err != nil {
+			issues = append(issues, ValidationIssueT{Kind: IssueSchedule, Path: blackoutPath + ".timezone", Message: fmt.Sprintf("unknown timezone %q", blackout.Timezone)})
+		}
+		if _, err := time.Parse(clockLayout, blackout.Start); err != nil {
+			issues = append(issues, ValidationIssueT{Kind: IssueSchedule, Path: blackoutPath + ".start", Message: fmt.Sprintf("invalid time %q, use %s", blackout.Start, clockLayout)})
+		}
+		if _, err := time.Parse(clockLayout, blackout.End); err != nil {
+			issues = append(issues, ValidationIssueT{Kind: IssueSchedule, Path: blackoutPath + ".end", Message: fmt.Sprintf("invalid time %q, use %s", blackout.End, clockLayout)})
+		}
+		for j, day := range blackout.Days {
+			if _, ok := parseWeekday(day); !ok {
+				issues = append(issues, ValidationIssueT{Kind: IssueSchedule, Path: fmt.Sprintf("%s.days[%d]", blackoutPath, j), Message: fmt.Sprintf("unknown day %q", day)})
+			}
+		}
+	}
+	return issues
+}
+
+// reportDestinationScheduleIssues logs the invalid schedules of a published config and counts them. Invalid blackouts never pause their destination.
+func reportDestinationScheduleIssues(config ConfigT) {
+	for _, source := range config.Sources {
+		for _, destination := range source.Destinations {
+			if destination.Schedule == nil {
+				continue
+			}
+			issues := ValidateDestinationSchedule(*destination.Schedule, "schedule")
+			for _, issue := range issues {
+				pkgLogger.Warnf("Invalid schedule of destination %s: %s", destination.ID, issue.String())
+			}
+			if len(issues) > 0 {
+				stats.NewTaggedStat("config_backend.schedule_errors", stats.CountType, map[string]string{"destinationId": destination.ID}).Count(len(issues))
+			}
+		}
+	}
+}
+
+// reportPausedDestinations logs the destinations paused by their schedule when they change and gauges their number
+func reportPausedDestinations(previous []string, current []string) {
+	if strings.Join(previous, ",") != strings.Join(current, ",") {
+		pkgLogger.Infof("Destinations paused by their schedule: %v", current)
+	}
+	stats.NewStat("config_backend.scheduled_paused_destinations", stats.GaugeType).Gauge(len(current))
+}
@@ -0,0 +1,144 @@
+package backendconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPausedAt(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	timePtr := func(value string) *time.Time {
+		parsed := at(value)
+		return &parsed
+	}
+	overnight := RecurringBlackoutT{Start: "22:00", End: "06:00", Timezone: "Europe/Berlin"}
+	weekend := RecurringBlackoutT{Days: []string{"sat"}, Start: "22:00", End: "02:00"}
+	tests := []struct {
+		name       string
+		schedule   *DestinationScheduleT
+		now        string
+		wantPaused bool
+		wantNext   string
+	}{
+		{name: "no schedule", now: "2021-06-01T12:00:00Z"},
+		{name: "before enableAt", schedule: &DestinationScheduleT{EnableAt: timePtr("2021-06-01T13:00:00Z")}, now: "2021-06-01T12:00:00Z", wantPaused: true, wantNext: "2021-06-01T13:00:00Z"},
+		{name: "at enableAt", schedule: &DestinationScheduleT{EnableAt: timePtr("2021-06-01T13:00:00Z")}, now: "2021-06-01T13:00:00Z"},
+		{name: "after disableAt", schedule: &DestinationScheduleT{DisableAt: timePtr("2021-06-01T11:00:00Z")}, now: "2021-06-01T12:00:00Z", wantPaused: true},
+		{name: "in a pause", schedule: &DestinationScheduleT{Pauses: []MaintenanceWindowT{{Start: at("2021-06-01T11:00:00Z"), End: at("2021-06-01T13:00:00Z")}}}, now: "2021-06-01T12:00:00Z", wantPaused: true, wantNext: "2021-06-01T13:00:00Z"},
+		{name: "at the end of a pause", schedule: &DestinationScheduleT{Pauses: []MaintenanceWindowT{{Start: at("2021-06-01T11:00:00Z"), End: at("2021-06-01T13:00:00Z")}}}, now: "2021-06-01T13:00:00Z"},
+
+		// 22:00 in Berlin is 20:00 UTC in summer and 21:00 UTC in winter
+		{name: "overnight blackout before it starts in summer", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{overnight}}, now: "2021-07-01T19:30:00Z", wantNext: "2021-07-01T20:00:00Z"},
+		{name: "overnight blackout started in summer", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{overnight}}, now: "2021-07-01T20:30:00Z", wantPaused: true, wantNext: "2021-07-02T04:00:00Z"},
+		{name: "overnight blackout not started in winter", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{overnight}}, now: "2021-01-01T20:30:00Z", wantNext: "2021-01-01T21:00:00Z"},
+		{name: "overnight blackout after midnight", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{overnight}}, now: "2021-01-02T03:00:00Z", wantPaused: true, wantNext: "2021-01-02T05:00:00Z"},
+		{name: "overnight blackout ended", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{overnight}}, now: "2021-01-02T05:00:00Z", wantNext: "2021-01-02T21:00:00Z"},
+		// clocks go forward from 02:00 to 03:00 in Berlin on 2021-03-28 and back from 03:00 to 02:00 on 2021-10-31
+		{name: "blackout shortened by DST", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{overnight}}, now: "2021-03-28T03:30:00Z", wantPaused: true, wantNext: "2021-03-28T04:00:00Z"},
+		{name: "blackout lengthened by DST", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{overnight}}, now: "2021-10-31T04:30:00Z", wantPaused: true, wantNext: "2021-10-31T05:00:00Z"},
+
+		// 2021-06-05 is a saturday
+		{name: "day filtered blackout on its day", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{weekend}}, now: "2021-06-05T23:00:00Z", wantPaused: true, wantNext: "2021-06-06T02:00:00Z"},
+		{name: "day filtered blackout continuing on the next day", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{weekend}}, now: "2021-06-06T01:00:00Z", wantPaused: true, wantNext: "2021-06-06T02:00:00Z"},
+		{name: "day filtered blackout on another day", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{weekend}}, now: "2021-06-06T23:00:00Z", wantNext: "2021-06-12T22:00:00Z"},
+		{name: "invalid timezone", schedule: &DestinationScheduleT{Blackouts: []RecurringBlackoutT{{Start: "00:00", End: "23:59", Timezone: "Mars/Olympus"}}}, now: "2021-06-01T12:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testSourcesConfig("destination-1")
+			config.Sources[0].Destinations[0].Schedule = tt.schedule
+			// locations loaded with the config give the same result as locations loaded on every evaluation
+			for _, locations := range []scheduleLocations{nil, loadScheduleLocations(config)} {
+				paused, next := tt.schedule.pausedAt(at(tt.now), locations)
+				if paused != tt.wantPaused {
+					t.Errorf("paused %t, want %t", paused, tt.wantPaused)
+				}
+				if tt.wantNext == "" && !next.IsZero() {
+					t.Errorf("got next change at %v, want none", next)
+				}
+				if tt.wantNext != "" && !next.Equal(at(tt.wantNext)) {
+					t.Errorf("got next change at %v, want %s", next.UTC(), tt.wantNext)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadScheduleLocations(t *testing.T) {
+	config := testSourcesConfig("destination-1", "destination-2")
+	config.Sources[0].Destinations[0].Schedule = &DestinationScheduleT{Blackouts: []RecurringBlackoutT{{Timezone: "Europe/Berlin"}, {Timezone: "Mars/Olympus"}}}
+	config.Sources[1].Destinations[0].Schedule = &DestinationScheduleT{Blackouts: []RecurringBlackoutT{{Timezone: "Europe/Berlin"}, {Timezone: ""}}}
+
+	locations := loadScheduleLocations(config)
+	if len(locations) != 3 {
+		t.Errorf("got %d locations, want 3", len(locations))
+	}
+	tests := []struct {
+		timezone string
+		want     string
+		wantErr  bool
+	}{
+		{timezone: "Europe/Berlin", want: "Europe/Berlin"},
+		{timezone: "", want: "UTC"},
+		{timezone: "Mars/Olympus", wantErr: true},
+		{timezone: "Asia/Tokyo", want: "Asia/Tokyo"},
+	}
+	for _, tt := range tests {
+		location, err := locations.load(tt.timezone)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tt.timezone, err, tt.wantErr)
+			continue
+		}
+		if err == nil && location.String() != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.timezone, location, tt.want)
+		}
+	}
+}
+
+func TestDestinationSchedulesUpdate(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	config := testSourcesConfig("destination-1", "destination-2")
+	// back to back pauses keep destination-1 paused when the first one ends
+	config.Sources[0].Destinations[0].Schedule = &DestinationScheduleT{Pauses: []MaintenanceWindowT{
+		{Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+		{Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)},
+	}}
+	// a disabled destination is never paused by its schedule
+	config.Sources[1].Destinations[0].Enabled = false
+	config.Sources[1].Destinations[0].Schedule = &DestinationScheduleT{Pauses: []MaintenanceWindowT{{Start: now.Add(3 * time.Hour), End: now.Add(4 * time.Hour)}}}
+	backend := newTestConfigBackend(t, config)
+	instance := newTestInstance(t, backend, nil)
+	if changed, ok := instance.configUpdate(testStatConfigBackendError); !changed || !ok {
+		t.Fatalf("config not published: changed %t, ok %t", changed, ok)
+	}
+
+	steps := []struct {
+		name          string
+		at            time.Duration
+		wantPublished bool
+		wantPaused    []string
+	}{
+		{name: "no change due", at: 30 * time.Minute, wantPublished: false, wantPaused: []string{"destination-1"}},
+		{name: "first pause ends as the second starts", at: time.Hour, wantPublished: false, wantPaused: []string{"destination-1"}},
+		{name: "second pause ends", at: 2 * time.Hour, wantPublished: true, wantPaused: []string{}},
+		{name: "pause of a disabled destination starts", at: 3 * time.Hour, wantPublished: false, wantPaused: []string{}},
+		{name: "pause of a disabled destination ends", at: 4 * time.Hour, wantPublished: false, wantPaused: []string{}},
+	}
+	for _, step := range steps {
+		if published := instance.destinationSchedulesUpdate(now.Add(step.at)); published != step.wantPublished {
+			t.Errorf("%s: published %t, want %t", step.name, published, step.wantPublished)
+		}
+		instance.curSourceJSONLock.RLock()
+		paused := instance.curPausedDestinations
+		instance.curSourceJSONLock.RUnlock()
+		if len(paused) != len(step.wantPaused) || (len(paused) > 0 && paused[0] != step.wantPaused[0]) {
+			t.Errorf("%s: got paused %v, want %v", step.name, paused, step.wantPaused)
+		}
+	}
+}
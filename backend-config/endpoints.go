@@ -0,0 +1,175 @@
+package backendconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+// backendEndpoint is a config backend URL along with its health
+type backendEndpoint struct {
+	url                 string
+	consecutiveFailures int
+	lastFailure         time.Time
+	healthyStat         stats.RudderStats
+	servedStat          stats.RudderStats
+	failedStat          stats.RudderStats
+}
+
+/*
+backendEndpoints holds the ordered list of config backend URLs, the primary first followed by its mirrors.
+Requests are sent to healthy endpoints in order and fail over to the next one when an endpoint is unreachable or responds with 5xx.
+An endpoint that failed is tried again, ahead of later endpoints, once unhealthyCooldown has elapsed.
+*/
+type backendEndpoints struct {
+	endpoints         []*backendEndpoint
+	unhealthyCooldown time.Duration
+	lock              sync.Mutex
+}
+
+// BackendEndpointStatus is a snapshot of a config backend endpoint's health, reported through admin
+type BackendEndpointStatus struct {
+	URL                 string    `json:"url"`
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastFailure         time.Time `json:"lastFailure,omitempty"`
+}
+
+func newBackendEndpoints(urls []string, unhealthyCooldown time.Duration) *backendEndpoints {
+	pool := &backendEndpoints{unhealthyCooldown: unhealthyCooldown}
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		tags := map[string]string{"endpoint": url}
+		endpoint := &backendEndpoint{
+			url:         url,
+			healthyStat: stats.NewTaggedStat("config_backend.endpoint_healthy", stats.GaugeType, tags),
+			servedStat:  stats.NewTaggedStat("config_backend.endpoint_served", stats.CountType, tags),
+			failedStat:  stats.NewTaggedStat("config_backend.endpoint_failed", stats.CountType, tags),
+		}
+		endpoint.healthyStat.Gauge(1)
+		pool.endpoints = append(pool.endpoints, endpoint)
+	}
+	return pool
+}
+
+// isHealthy must be called with pool.lock held
+func (pool *backendEndpoints) isHealthy(endpoint *backendEndpoint) bool {
+	return endpoint.consecutiveFailures == 0 || time.Since(endpoint.lastFailure) >= pool.unhealthyCooldown
+}
+
+// ordered returns the endpoints to try, healthy ones first, each group in configured order
+func (pool *backendEndpoints) ordered() []*backendEndpoint {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	healthy := make([]*backendEndpoint, 0, len(pool.endpoints))
+	unhealthy := make([]*backendEndpoint, 0)
+	for _, endpoint := range pool.endpoints {
+		if pool.isHealthy(endpoint) {
+			healthy = append(healthy, endpoint)
+		} else {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (pool *backendEndpoints) recordSuccess(endpoint *backendEndpoint) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if endpoint.consecutiveFailures > 0 {
+		pkgLogger.Infof("[[ Backend-endpoints ]] Config backend %s is healthy again", endpoint.url)
+	}
+	endpoint.consecutiveFailures = 0
+	endpoint.healthyStat.Gauge(1)
+	endpoint.servedStat.Increment()
+}
+
+func (pool *backendEndpoints) recordFailure(endpoint *backendEndpoint) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	endpoint.consecutiveFailures++
+	endpoint.lastFailure = time.Now()
+	endpoint.healthyStat.Gauge(0)
+	endpoint.failedStat.Increment()
+}
+
+/*
+endpointUnavailable reports whether a request failed because of the endpoint rather than the request itself, that is
+the endpoint responded with 5xx, couldn't be reached or cut its response short. Errors handling a complete response, like a
+failed decoding or verification, don't count.
+*/
+func endpointUnavailable(statusCode int, err error) bool {
+	if statusCode >= 500 {
+		return true
+	}
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	// a body cut short is a connection dropped by the endpoint
+	return errors.As(err, &urlErr) || errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+/*
+request calls do with the base URL of each endpoint until one of them is available and returns its result.
+If every endpoint is unavailable, the result of the last attempt is returned.
+*/
+func (pool *backendEndpoints) request(do func(baseURL string) ([]byte, int, error)) ([]byte, int, error) {
+	endpoints := pool.ordered()
+	if len(endpoints) == 0 {
+		return []byte{}, 0, fmt.Errorf("no config backend URL configured")
+	}
+
+	var respBody []byte
+	var statusCode int
+	var err error
+	for i, endpoint := range endpoints {
+		respBody, statusCode, err = do(endpoint.url)
+		if !endpointUnavailable(statusCode, err) {
+			pool.recordSuccess(endpoint)
+			return respBody, statusCode, err
+		}
+
+		pool.recordFailure(endpoint)
+		if i < len(endpoints)-1 {
+			pkgLogger.Warnf("[[ Backend-endpoints ]] Config backend %s unavailable (statusCode: %d, error: %v), failing over to %s", endpoint.url, statusCode, err, endpoints[i+1].url)
+		}
+	}
+	return respBody, statusCode, err
+}
+
+// get sends a GET request for path using makeHTTPRequest, failing over between endpoints
+func (pool *backendEndpoints) get(path string, makeHTTPRequest func(url string) ([]byte, int, error)) ([]byte, int, error) {
+	return pool.request(func(baseURL string) ([]byte, int, error) {
+		return makeHTTPRequest(baseURL + path)
+	})
+}
+
+func (pool *backendEndpoints) status() []BackendEndpointStatus {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	endpointsStatus := make([]BackendEndpointStatus, 0, len(pool.endpoints))
+	for _, endpoint := range pool.endpoints {
+		endpointsStatus = append(endpointsStatus, BackendEndpointStatus{
+			URL:                 endpoint.url,
+			Healthy:             pool.isHealthy(endpoint),
+			ConsecutiveFailures: endpoint.consecutiveFailures,
+			LastFailure:         endpoint.lastFailure,
+		})
+	}
+	return endpointsStatus
+}
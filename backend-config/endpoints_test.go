@@ -0,0 +1,118 @@
+package backendconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackendEndpointsFailover(t *testing.T) {
+	tests := []struct {
+		name        string
+		responses   map[string]int
+		wantTried   []string
+		wantStatus  int
+		wantHealthy []bool
+	}{
+		{name: "primary up", responses: map[string]int{"a": 200, "b": 200}, wantTried: []string{"a"}, wantStatus: 200, wantHealthy: []bool{true, true}},
+		{name: "primary 5xx fails over", responses: map[string]int{"a": 503, "b": 200}, wantTried: []string{"a", "b"}, wantStatus: 200, wantHealthy: []bool{false, true}},
+		{name: "primary unreachable fails over", responses: map[string]int{"a": 0, "b": 200}, wantTried: []string{"a", "b"}, wantStatus: 200, wantHealthy: []bool{false, true}},
+		{name: "client errors don't fail over", responses: map[string]int{"a": 404, "b": 200}, wantTried: []string{"a"}, wantStatus: 404, wantHealthy: []bool{true, true}},
+		{name: "all down returns the last attempt", responses: map[string]int{"a": 500, "b": 502}, wantTried: []string{"a", "b"}, wantStatus: 502, wantHealthy: []bool{false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newBackendEndpoints([]string{"a", "", "b"}, time.Minute)
+			tried := make([]string, 0)
+			_, statusCode, _ := pool.get("/path", func(requestURL string) ([]byte, int, error) {
+				baseURL := requestURL[:len(requestURL)-len("/path")]
+				tried = append(tried, baseURL)
+				statusCode := tt.responses[baseURL]
+				switch {
+				case statusCode == 0:
+					return nil, 0, &url.Error{Op: "Get", URL: requestURL, Err: errors.New("connection refused")}
+				case statusCode >= 300:
+					return nil, statusCode, &PostRequestError{URL: requestURL, StatusCode: statusCode}
+				}
+				return []byte("{}"), statusCode, nil
+			})
+			if !reflect.DeepEqual(tried, tt.wantTried) {
+				t.Errorf("tried %v, want %v", tried, tt.wantTried)
+			}
+			if statusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", statusCode, tt.wantStatus)
+			}
+			healthy := make([]bool, 0)
+			for _, status := range pool.status() {
+				healthy = append(healthy, status.Healthy)
+			}
+			if !reflect.DeepEqual(healthy, tt.wantHealthy) {
+				t.Errorf("healthy = %v, want %v", healthy, tt.wantHealthy)
+			}
+		})
+	}
+}
+
+func TestBackendEndpointsCooldown(t *testing.T) {
+	pool := newBackendEndpoints([]string{"a", "b"}, 20*time.Millisecond)
+	failA := true
+	do := func(baseURL string) ([]byte, int, error) {
+		if baseURL == "a" && failA {
+			return nil, 503, nil
+		}
+		return []byte(baseURL), 200, nil
+	}
+
+	pool.request(do)
+	failA = false
+	// a is skipped until its cooldown elapses, even though it recovered
+	if body, _, _ := pool.request(do); string(body) != "b" {
+		t.Fatalf("served by %s during the cooldown of a, want b", body)
+	}
+	time.Sleep(25 * time.Millisecond)
+	if body, _, _ := pool.request(do); string(body) != "a" {
+		t.Fatalf("served by %s after the cooldown, want the primary a", body)
+	}
+	if status := pool.status()[0]; !status.Healthy || status.ConsecutiveFailures != 0 {
+		t.Errorf("primary status = %+v, want healthy again", status)
+	}
+}
+
+func TestBackendEndpointsEmpty(t *testing.T) {
+	if _, _, err := newBackendEndpoints(nil, time.Minute).request(func(string) ([]byte, int, error) { return nil, 200, nil }); err == nil {
+		t.Fatal("expected an error without any URL")
+	}
+}
+
+func TestEndpointUnavailable(t *testing.T) {
+	transportErr := &url.Error{Op: "Get", URL: "a/path", Err: errors.New("connection refused")}
+	tests := []struct {
+		name       string
+		statusCode int
+		err        error
+		want       bool
+	}{
+		{name: "success", statusCode: 200, want: false},
+		{name: "5xx", statusCode: 503, err: &PostRequestError{StatusCode: 503}, want: true},
+		{name: "5xx without an error", statusCode: 500, want: true},
+		{name: "4xx", statusCode: 404, err: &PostRequestError{StatusCode: 404}, want: false},
+		{name: "transport error", statusCode: 400, err: transportErr, want: true},
+		{name: "wrapped transport error", err: fmt.Errorf("failed to execute request: %w", transportErr), want: true},
+		{name: "canceled request", err: &url.Error{Op: "Get", URL: "a/path", Err: context.Canceled}, want: false},
+		{name: "truncated body", statusCode: 200, err: fmt.Errorf("failed to decode hosted workspace config: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "decoding error", statusCode: 200, err: errors.New("invalid character 'x' looking for beginning of value"), want: false},
+		{name: "verification error", statusCode: 200, err: ErrConfigSignatureInvalid, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := endpointUnavailable(tt.statusCode, tt.err); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,572 @@
+package backendconfig
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rudderlabs/rudder-utils/rruntime"
+	"github.com/rudderlabs/rudder-utils/stats"
+	"github.com/rudderlabs/rudder-utils/utils"
+	"github.com/rudderlabs/rudder-utils/utils/types"
+)
+
+/*
+Instance is a self-contained backend config: it holds its own settings, config backend provider, current config and regulations,
+pollers and event bus, so that several of them can run in one process, e.g. against two control planes.
+The package level functions are wrappers around the instance created by Setup.
+*/
+type Instance struct {
+	setup    BackendConfigSetup
+	provider BackendConfig
+	eb       utils.PublishSubscriber
+	// isDefault instances also maintain the package level LastSync and LastRegulationSync
+	isDefault bool
+
+	endpoints                 *backendEndpoints
+	verifier                  *configVerifier
+	fieldChecker              *configFieldChecker
+	redactor                  *Redactor
+	codeCache                 *codeCache
+	responseRules             *responseRuleCache
+	regulationStore           RegulationStore
+	regulationAudit           *regulationAuditLog
+	poller                    *pollerControl
+	configCircuitBreaker      *circuitBreaker
+	regulationsCircuitBreaker *circuitBreaker
+	instanceID                string
+
+	curSourceJSON         ConfigT
+	curFetchedJSON        ConfigT
+	curConfigHash         string
+	curConfigOverlay      appliedOverlay
+	curPausedDestinations []string
+	curScheduleLocations  scheduleLocations
+	nextScheduleChange    time.Time
+	curSourceJSONLock     sync.RWMutex
+	curRegulations        retainedRegulations
+	curRegulationJSONLock sync.RWMutex
+	initializedLock       sync.RWMutex
+	initialized           bool
+	waitForRegulations    bool
+	lastSync              string
+	lastRegulationSync    string
+
+	// scheduleRefreshCh wakes pollDestinationSchedules up when a new config is published
+	scheduleRefreshCh chan struct{}
+	stopOnce          sync.Once
+	stopCh            chan struct{}
+}
+
+/*
+New returns an instance for setup, with its own event bus. It doesn't fetch anything until Start is called.
+Settings of setup that are zero but must not be are replaced by their defaults, and status reporting is disabled without a StatusReportEndpoint.
+configEnvHandler replaces env variables in configs fetched in single workspace mode and may be nil.
+*/
+func New(setup BackendConfigSetup, configEnvHandler types.ConfigEnvI) *Instance {
+	setup = setup.withDefaults()
+	if setup.StatusReportInterval > 0 && setup.StatusReportEndpoint == "" {
+		pkgLogger.Errorf("Not reporting data plane status every %v: no StatusReportEndpoint to post it to", setup.StatusReportInterval)
+		setup.StatusReportInterval = 0
+	}
+	instance := &Instance{
+		setup:             setup,
+		eb:                new(utils.EventBus),
+		endpoints:         newBackendEndpoints(append([]string{setup.ConfigBackendUrl}, setup.ConfigBackendMirrorUrls...), setup.ConfigBackendEndpointCooldown),
+		verifier:          newConfigVerifier(setup.ConfigVerificationMode, setup.ConfigHMACSecrets, setup.ConfigPublicKeys),
+		fieldChecker:      newConfigFieldChecker(setup.StrictConfigDecoding),
+		redactor:          NewRedactor(setup.SecretRedactionPolicy, setup.MaskWriteKeys),
+		responseRules:     newResponseRuleCache(),
+		regulationStore:   NewRegulationStore(setup),
+		curRegulations:    newRetainedRegulations(setup),
+		regulationAudit:   newRegulationAuditLog(setup.RegulationAuditLogPath),
+		poller:            newPollerControl(),
+		instanceID:        setup.InstanceID,
+		scheduleRefreshCh: make(chan struct{}, 1),
+		stopCh:            make(chan struct{}),
+	}
+	if instance.instanceID == "" {
+		instance.instanceID = defaultInstanceID()
+	}
+	instance.codeCache = newCodeCache(setup.TransformationCacheDir, setup.TransformationPrefetchMaxHoldBack, instance)
+	instance.configCircuitBreaker = newCircuitBreaker("config", setup.CircuitBreakerFailureThreshold, setup.CircuitBreakerOpenTimeout)
+	instance.regulationsCircuitBreaker = newCircuitBreaker("regulations", setup.CircuitBreakerFailureThreshold, setup.CircuitBreakerOpenTimeout)
+
+	common := CommonBackendConfig{configEnvHandler: configEnvHandler, instance: instance}
+	if setup.IsMultiWorkspace {
+		instance.provider = &MultiWorkspaceConfig{CommonBackendConfig: common}
+	} else {
+		instance.provider = &WorkspaceConfig{CommonBackendConfig: common}
+	}
+	instance.provider.SetUp()
+	return instance
+}
+
+// Start starts polling config, and regulations if pollRegulations is set, along with the proxy, spool and status reporter if enabled
+func (instance *Instance) Start(pollRegulations bool) {
+	errorFilePath := instance.setup.ErrorFilePath
+
+	rruntime.Go(func() {
+		instance.pollConfigUpdate()
+	}, errorFilePath)
+
+	rruntime.Go(func() {
+		instance.pollDestinationSchedules()
+	}, errorFilePath)
+
+	if pollRegulations {
+		instance.startRegulationPolling()
+	}
+
+	if instance.setup.ConfigProxyEnabled {
+		rruntime.Go(func() {
+			instance.startConfigProxy(instance.setup.ConfigProxyAddress)
+		}, errorFilePath)
+	}
+
+	if instance.setup.PostSpoolDir != "" {
+		rruntime.Go(func() {
+			instance.pollSpooledRequests()
+		}, errorFilePath)
+	}
+
+	if instance.setup.StatusReportInterval > 0 {
+		rruntime.Go(func() {
+			instance.pollStatusReports()
+		}, errorFilePath)
+	}
+
+	if instance.setup.RegisterAdminHandler != nil {
+		instance.setup.RegisterAdminHandler("BackendConfig", instance.Admin())
+	}
+}
+
+// Stop stops the pollers and the proxy of the instance. The current config and regulations remain available.
+func (instance *Instance) Stop() {
+	instance.stopOnce.Do(func() {
+		close(instance.stopCh)
+	})
+}
+
+// Provider returns the WorkspaceConfig or MultiWorkspaceConfig fetching config for the instance
+func (instance *Instance) Provider() BackendConfig {
+	return instance.provider
+}
+
+// Admin returns the admin functions of the instance, to be registered with the admin server
+func (instance *Instance) Admin() *BackendConfigAdmin {
+	return &BackendConfigAdmin{instance: instance}
+}
+
+// startRegulationPolling - starts enterprise backend regulations polling
+func (instance *Instance) startRegulationPolling() {
+	instance.initializedLock.Lock()
+	instance.waitForRegulations = true
+	instance.initializedLock.Unlock()
+
+	rruntime.Go(func() {
+		instance.pollRegulations()
+	}, instance.setup.ErrorFilePath)
+}
+
+// regulationsUpdate fetches regulations and publishes them if they changed. It reports whether regulations changed and whether the fetch succeeded.
+func (instance *Instance) regulationsUpdate(statConfigBackendError stats.RudderStats) (changed bool, ok bool) {
+	if !instance.regulationsCircuitBreaker.allow() {
+		pkgLogger.Debug("Regulations circuit is open, skipping fetch")
+		instance.poller.recordSyncError(&instance.poller.lastRegulationsError, "regulations circuit breaker is open")
+		return false, false
+	}
+
+	regulationJSON, ok := instance.provider.GetRegulations()
+	if !ok {
+		statConfigBackendError.Increment()
+		instance.poller.recordSyncError(&instance.poller.lastRegulationsError, "failed to fetch regulations")
+		instance.regulationsCircuitBreaker.recordFailure()
+	} else {
+		instance.regulationsCircuitBreaker.recordSuccess()
+	}
+
+	//sorting the regulationJSON.
+	//json unmarshal does not guarantee order. For DeepEqual to work as expected, sorting is necessary
+	sort.Slice(regulationJSON.WorkspaceRegulations[:], func(i, j int) bool {
+		return regulationJSON.WorkspaceRegulations[i].ID < regulationJSON.WorkspaceRegulations[j].ID
+	})
+	sort.Slice(regulationJSON.SourceRegulations[:], func(i, j int) bool {
+		return regulationJSON.SourceRegulations[i].ID < regulationJSON.SourceRegulations[j].ID
+	})
+
+	// compact regulations are expanded by get, do it once for both the comparison and the diff
+	instance.curRegulationJSONLock.RLock()
+	curRegulations, loaded := instance.curRegulations.get(), instance.curRegulations.loaded
+	instance.curRegulationJSONLock.RUnlock()
+	// the first regulations fetched are published even if there are none, to mark them as loaded
+	regulationsChanged := !loaded || !reflect.DeepEqual(curRegulations, regulationJSON)
+
+	if ok && regulationsChanged {
+		// regulationsUpdate is only called by the regulations poller, nothing else changes them between the diff and the update
+		changes := diffRegulations(curRegulations, regulationJSON)
+		// regulations are held back until their events are logged, the next poll logs them again
+		if err := instance.regulationAudit.record(changes, time.Now()); err != nil {
+			instance.poller.recordSyncError(&instance.poller.lastRegulationsError, "failed to write regulation audit log")
+			return false, false
+		}
+		instance.curRegulationJSONLock.Lock()
+		instance.curRegulations.set(regulationJSON)
+		instance.curRegulationJSONLock.Unlock()
+		reportRegulationChanges(changes)
+		reportUnknownRegulations(changes)
+		instance.regulationStore.Apply(changes)
+		instance.initializedLock.Lock() //Using initializedLock for waitForRegulations too.
+		defer instance.initializedLock.Unlock()
+		instance.waitForRegulations = false
+		instance.lastRegulationSync = time.Now().Format(time.RFC3339)
+		if instance.isDefault {
+			LastRegulationSync = instance.lastRegulationSync
+		}
+		instance.eb.Publish(string(TopicRegulations), regulationJSON)
+		instance.eb.Publish(string(TopicRegulationChanges), changes)
+		instance.poller.clearSyncError(&instance.poller.lastRegulationsError)
+		return true, ok
+	}
+	if ok {
+		instance.poller.clearSyncError(&instance.poller.lastRegulationsError)
+	}
+	return false, ok
+}
+
+// fetchedWorkspacesPublisher is implemented by providers keeping the write keys, libraries and settings of a fetched config until it is published
+type fetchedWorkspacesPublisher interface {
+	publishFetchedWorkspaces()
+}
+
+// publishFetchedWorkspaces makes the write keys, libraries and settings fetched with the config that was just published or found unchanged available
+func (instance *Instance) publishFetchedWorkspaces() {
+	if publisher, ok := instance.provider.(fetchedWorkspacesPublisher); ok {
+		publisher.publishFetchedWorkspaces()
+	}
+}
+
+// configUpdate fetches the workspace config and publishes it if it changed. It reports whether the config changed and whether the fetch succeeded.
+func (instance *Instance) configUpdate(statConfigBackendError stats.RudderStats) (changed bool, ok bool) {
+	if !instance.configCircuitBreaker.allow() {
+		pkgLogger.Debug("Workspace config circuit is open, skipping fetch")
+		instance.poller.recordSyncError(&instance.poller.lastConfigError, "config circuit breaker is open")
+		return false, false
+	}
+
+	sourceJSON, ok := instance.provider.Get()
+	if !ok {
+		statConfigBackendError.Increment()
+		instance.poller.recordSyncError(&instance.poller.lastConfigError, "failed to fetch workspace config")
+		instance.configCircuitBreaker.recordFailure()
+	} else {
+		instance.configCircuitBreaker.recordSuccess()
+	}
+
+	// peers of the proxy get the config as fetched, never the local overrides and debug destinations
+	fetchedJSON := copyConfigSources(sourceJSON)
+	appliedOverrides := make(appliedOverlay)
+	if ok && instance.setup.ConfigOverlayPath != "" {
+		overlay, overlayErr := loadConfigOverlay(instance.setup.ConfigOverlayPath)
+		if overlayErr != nil {
+			// publishing without the overlay could silently undo a kill switch, keep the current config instead
+			pkgLogger.Errorf("Holding back workspace config: %s", overlayErr.Error())
+			instance.poller.recordSyncError(&instance.poller.lastConfigError, overlayErr.Error())
+			return false, false
+		}
+		appliedOverrides = applyConfigOverlay(&sourceJSON, overlay)
+	}
+
+	//canonicalizing the sourceJSON.
+	//json unmarshal does not guarantee order. For the hash to only change with the content, sorting is necessary
+	canonicalizeConfig(&sourceJSON)
+	configHash, err := computeConfigHash(sourceJSON)
+	if err != nil {
+		pkgLogger.Errorf("Unable to compute hash of workspace config: %s", err.Error())
+		instance.poller.recordSyncError(&instance.poller.lastConfigError, err.Error())
+		return false, false
+	}
+	sourceJSON.Version = configHash
+
+	instance.curSourceJSONLock.RLock()
+	configChanged := configHash != instance.curConfigHash
+	instance.curSourceJSONLock.RUnlock()
+
+	if ok && configChanged {
+		pkgLogger.Infof("Workspace Config changed, version: %s", configHash)
+		canonicalizeConfig(&fetchedJSON)
+		if fetchedJSON.Version, err = computeConfigHash(fetchedJSON); err != nil {
+			pkgLogger.Errorf("Unable to compute hash of fetched workspace config: %s", err.Error())
+			instance.poller.recordSyncError(&instance.poller.lastConfigError, err.Error())
+			return false, false
+		}
+		// processors must never see a transformation version that isn't cached, retry with the next poll instead, for a while
+		err = instance.codeCache.prefetchCode(sourceJSON)
+		if instance.codeCache.holdBack(configHash, err, time.Now()) {
+			pkgLogger.Errorf("Holding back workspace config version %s: %s", configHash, err.Error())
+			instance.poller.recordSyncError(&instance.poller.lastConfigError, err.Error())
+			return false, false
+		}
+		if err != nil {
+			pkgLogger.Errorf("Publishing workspace config version %s after holding it back for %v: %s", configHash, instance.setup.TransformationPrefetchMaxHoldBack, err.Error())
+			stats.NewStat("config_backend.code_prefetch_hold_back_expired", stats.CountType).Increment()
+		}
+		// compiled only once the config is sure to be published, so that the rules always match the current config
+		instance.responseRules.compile(sourceJSON)
+		instance.curSourceJSONLock.Lock()
+		trackConfig(instance.curSourceJSON, sourceJSON)
+		locations := loadScheduleLocations(sourceJSON)
+		filteredSourcesJSON, pausedDestinations, nextScheduleChange := processConfig(sourceJSON, locations, time.Now())
+		previousPausedDestinations := instance.curPausedDestinations
+		instance.curSourceJSON = sourceJSON
+		instance.curFetchedJSON = fetchedJSON
+		instance.curConfigHash = configHash
+		instance.curConfigOverlay = appliedOverrides
+		instance.curPausedDestinations = pausedDestinations
+		instance.curScheduleLocations = locations
+		instance.nextScheduleChange = nextScheduleChange
+		instance.curSourceJSONLock.Unlock()
+		instance.publishFetchedWorkspaces()
+		reportConfigOverlay(appliedOverrides)
+		reportDestinationScheduleIssues(sourceJSON)
+		reportPausedDestinations(previousPausedDestinations, pausedDestinations)
+		instance.initializedLock.Lock()
+		defer instance.initializedLock.Unlock()
+		instance.initialized = true
+		instance.lastSync = time.Now().Format(time.RFC3339)
+		if instance.isDefault {
+			LastSync = instance.lastSync
+		}
+		instance.eb.Publish(string(TopicProcessConfig), filteredSourcesJSON)
+		instance.eb.Publish(string(TopicBackendConfig), sourceJSON)
+		select {
+		case instance.scheduleRefreshCh <- struct{}{}:
+		default:
+		}
+		instance.poller.clearSyncError(&instance.poller.lastConfigError)
+		return true, ok
+	}
+	if ok {
+		// in multi workspace mode, libraries and settings aren't part of the config its hash covers
+		instance.publishFetchedWorkspaces()
+		instance.poller.clearSyncError(&instance.poller.lastConfigError)
+	}
+	return false, ok
+}
+
+func (instance *Instance) pollConfigUpdate() {
+	statConfigBackendError := stats.NewStat("config_backend.errors", stats.CountType)
+	interval := newAdaptivePollInterval(instance.setup.PollInterval, instance.setup.MaxPollInterval, instance.setup.PollIntervalJitter)
+	changed, ok, forced := false, true, true
+	for {
+		if forced || instance.poller.isPollingEnabled() {
+			changed, ok = instance.configUpdate(statConfigBackendError)
+		}
+		var stopped bool
+		forced, stopped = instance.waitForNextPoll(interval.next(changed, !ok), instance.poller.configRefreshCh)
+		if stopped {
+			return
+		}
+	}
+}
+
+func (instance *Instance) pollRegulations() {
+	statConfigBackendError := stats.NewStat("config_backend.errors", stats.CountType)
+	interval := newAdaptivePollInterval(instance.setup.RegulationsPollInterval, instance.setup.MaxRegulationsPollInterval, instance.setup.PollIntervalJitter)
+	changed, ok, forced := false, true, true
+	for {
+		if forced || instance.poller.isPollingEnabled() {
+			changed, ok = instance.regulationsUpdate(statConfigBackendError)
+		}
+		var stopped bool
+		forced, stopped = instance.waitForNextPoll(interval.next(changed, !ok), instance.poller.regulationsRefreshCh)
+		if stopped {
+			return
+		}
+	}
+}
+
+/*
+pollDestinationSchedules republishes TopicProcessConfig every time a destination schedule starts or stops pausing a destination,
+between the configs published by pollConfigUpdate.
+*/
+func (instance *Instance) pollDestinationSchedules() {
+	for {
+		instance.curSourceJSONLock.RLock()
+		nextScheduleChange := instance.nextScheduleChange
+		instance.curSourceJSONLock.RUnlock()
+
+		interval := maxScheduleWait
+		if !nextScheduleChange.IsZero() && time.Until(nextScheduleChange) < interval {
+			interval = time.Until(nextScheduleChange)
+		}
+		if _, stopped := instance.waitForNextPoll(interval, instance.scheduleRefreshCh); stopped {
+			return
+		}
+		instance.destinationSchedulesUpdate(time.Now())
+	}
+}
+
+/*
+destinationSchedulesUpdate republishes TopicProcessConfig if the destinations paused by their schedule changed since the last publish.
+A schedule change may leave them as they are, e.g. a pause starting while the destination is disabled, or windows ending as others start.
+It reports whether TopicProcessConfig was republished.
+*/
+func (instance *Instance) destinationSchedulesUpdate(now time.Time) bool {
+	instance.curSourceJSONLock.Lock()
+	defer instance.curSourceJSONLock.Unlock()
+	if instance.nextScheduleChange.IsZero() || now.Before(instance.nextScheduleChange) {
+		return false
+	}
+
+	filteredSourcesJSON, pausedDestinations, nextScheduleChange := processConfig(instance.curSourceJSON, instance.curScheduleLocations, now)
+	instance.nextScheduleChange = nextScheduleChange
+	if reflect.DeepEqual(instance.curPausedDestinations, pausedDestinations) {
+		return false
+	}
+	reportPausedDestinations(instance.curPausedDestinations, pausedDestinations)
+	instance.curPausedDestinations = pausedDestinations
+	// published under the lock, so that the view is always computed from the current config
+	instance.eb.Publish(string(TopicProcessConfig), filteredSourcesJSON)
+	return true
+}
+
+// waitForNextPoll sleeps for interval, until a refresh is requested or until the instance is stopped
+func (instance *Instance) waitForNextPoll(interval time.Duration, refreshCh chan struct{}) (forced bool, stopped bool) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return false, false
+	case <-refreshCh:
+		return true, false
+	case <-instance.stopCh:
+		return false, true
+	}
+}
+
+// sleep sleeps for interval, reporting false if the instance was stopped meanwhile
+func (instance *Instance) sleep(interval time.Duration) bool {
+	_, stopped := instance.waitForNextPoll(interval, nil)
+	return !stopped
+}
+
+// GetConfig returns the last published config
+func (instance *Instance) GetConfig() ConfigT {
+	instance.curSourceJSONLock.RLock()
+	defer instance.curSourceJSONLock.RUnlock()
+	return instance.curSourceJSON
+}
+
+// getFetchedConfig returns the last published config as it was fetched, before the overlay was merged into it
+func (instance *Instance) getFetchedConfig() ConfigT {
+	instance.curSourceJSONLock.RLock()
+	defer instance.curSourceJSONLock.RUnlock()
+	return instance.curFetchedJSON
+}
+
+// GetCurrentRegulations returns the last published regulations, without fetching them.
+// With RegulationStoreCompact, they are expanded from their compact copy on every call.
+func (instance *Instance) GetCurrentRegulations() RegulationsT {
+	instance.curRegulationJSONLock.RLock()
+	defer instance.curRegulationJSONLock.RUnlock()
+	return instance.curRegulations.get()
+}
+
+// workspaceRegulationsPage returns a page of the current workspace regulations of workspaceID, or of every workspace if it is empty. It isn't ok until regulations have been loaded.
+func (instance *Instance) workspaceRegulationsPage(workspaceID string, start int, limit int) (page WRegulationsT, ok bool) {
+	instance.curRegulationJSONLock.RLock()
+	defer instance.curRegulationJSONLock.RUnlock()
+	return instance.curRegulations.workspaceRegulationsPage(workspaceID, start, limit), instance.curRegulations.loaded
+}
+
+// sourceRegulationsPage is workspaceRegulationsPage for source regulations
+func (instance *Instance) sourceRegulationsPage(workspaceID string, start int, limit int) (page SRegulationsT, ok bool) {
+	instance.curRegulationJSONLock.RLock()
+	defer instance.curRegulationJSONLock.RUnlock()
+	return instance.curRegulations.sourceRegulationsPage(workspaceID, start, limit), instance.curRegulations.loaded
+}
+
+// IsSuppressedUser reports whether a workspace or source regulation suppresses the events of userID, looked up in the RegulationStore of the instance
+func (instance *Instance) IsSuppressedUser(workspaceID string, sourceID string, userID string) bool {
+	return instance.regulationStore.IsSuppressedUser(workspaceID, sourceID, userID)
+}
+
+// GetConfigVersion returns the content hash of the current config, which changes every time a new config is published
+func (instance *Instance) GetConfigVersion() string {
+	instance.curSourceJSONLock.RLock()
+	defer instance.curSourceJSONLock.RUnlock()
+	return instance.curConfigHash
+}
+
+// GetLastSync returns when config and regulations were last published, in RFC 3339, empty if never
+func (instance *Instance) GetLastSync() (lastSync string, lastRegulationSync string) {
+	instance.initializedLock.RLock()
+	defer instance.initializedLock.RUnlock()
+	return instance.lastSync, instance.lastRegulationSync
+}
+
+// GetSecretRedactor returns the Redactor configured through BackendConfigSetup
+func (instance *Instance) GetSecretRedactor() *Redactor {
+	return instance.redactor
+}
+
+func (instance *Instance) GetWorkspaceIDForWriteKey(writeKey string) string {
+	return instance.provider.GetWorkspaceIDForWriteKey(writeKey)
+}
+
+func (instance *Instance) GetWorkspaceLibrariesForWorkspaceID(workspaceID string) LibrariesT {
+	return instance.provider.GetWorkspaceLibrariesForWorkspaceID(workspaceID)
+}
+
+func (instance *Instance) GetWorkspaceSettingsForWorkspaceID(workspaceID string) WorkspaceSettingsT {
+	return instance.provider.GetWorkspaceSettingsForWorkspaceID(workspaceID)
+}
+
+/*
+Subscribe subscribes a channel to a specific topic of the config updates of this instance.
+The channel immediately receives the current value of the topic. For TopicRegulationChanges, that is every current regulation as added.
+*/
+func (instance *Instance) Subscribe(channel chan utils.DataEvent, topic Topic) {
+	instance.eb.Subscribe(string(topic), channel)
+	instance.poller.recordSubscription(topic)
+	instance.curSourceJSONLock.RLock()
+
+	if topic == TopicProcessConfig {
+		filteredSourcesJSON, _, _ := processConfig(instance.curSourceJSON, instance.curScheduleLocations, time.Now())
+		instance.eb.PublishToChannel(channel, string(topic), filteredSourcesJSON)
+	} else if topic == TopicBackendConfig {
+		instance.eb.PublishToChannel(channel, string(topic), instance.curSourceJSON)
+	} else if topic == TopicRegulations {
+		instance.curRegulationJSONLock.RLock()
+		instance.eb.PublishToChannel(channel, string(topic), instance.curRegulations.get())
+		instance.curRegulationJSONLock.RUnlock()
+	} else if topic == TopicRegulationChanges {
+		instance.curRegulationJSONLock.RLock()
+		instance.eb.PublishToChannel(channel, string(topic), diffRegulations(RegulationsT{}, instance.curRegulations.get()))
+		instance.curRegulationJSONLock.RUnlock()
+	}
+	instance.curSourceJSONLock.RUnlock()
+}
+
+// WaitForConfig waits until config, and regulations if they are polled, have been published once
+func (instance *Instance) WaitForConfig() {
+	for {
+		instance.initializedLock.RLock()
+		if instance.initialized && !instance.waitForRegulations {
+			instance.initializedLock.RUnlock()
+			break
+		}
+		instance.initializedLock.RUnlock()
+		pkgLogger.Info("Waiting for initializing backend config")
+		time.Sleep(instance.setup.PollInterval)
+	}
+}
+
+func (instance *Instance) isInitialized() bool {
+	instance.initializedLock.RLock()
+	defer instance.initializedLock.RUnlock()
+	return instance.initialized
+}
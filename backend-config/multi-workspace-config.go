@@ -2,7 +2,10 @@ package backendconfig
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"sync"
 	"time"
@@ -16,6 +19,9 @@ type MultiWorkspaceConfig struct {
 	CommonBackendConfig
 	writeKeyToWorkspaceIDMap  map[string]string
 	workspaceIDToLibrariesMap map[string]LibrariesT
+	workspaceIDToSettingsMap  map[string]WorkspaceSettingsT
+	// fetched holds the write keys, libraries and settings of the workspaces last fetched until the instance publishes their config
+	fetched                   *hostedWorkspacesIndex
 	workspaceWriteKeysMapLock sync.RWMutex
 }
 
@@ -64,16 +70,43 @@ func (multiWorkspaceConfig *MultiWorkspaceConfig) GetWorkspaceLibrariesForWorksp
 	return LibrariesT{}
 }
 
+//GetWorkspaceSettingsForWorkspaceID returns the settings of a hosted workspace published last, with defaults for the settings that aren't set or are invalid
+func (multiWorkspaceConfig *MultiWorkspaceConfig) GetWorkspaceSettingsForWorkspaceID(workspaceID string) WorkspaceSettingsT {
+	multiWorkspaceConfig.workspaceWriteKeysMapLock.RLock()
+	defer multiWorkspaceConfig.workspaceWriteKeysMapLock.RUnlock()
+	return multiWorkspaceConfig.workspaceIDToSettingsMap[workspaceID].withDefaults()
+}
+
 //Get returns sources from all hosted workspaces
 func (multiWorkspaceConfig *MultiWorkspaceConfig) Get() (ConfigT, bool) {
-	url := fmt.Sprintf("%s/hostedWorkspaceConfig?fetchAll=true", configBackendURL)
+	path := "/hostedWorkspaceConfig?fetchAll=true"
 
-	var respBody []byte
+	var workspaces *hostedWorkspacesIndex
 	var statusCode int
 
 	operation := func() error {
 		var fetchError error
-		respBody, statusCode, fetchError = multiWorkspaceConfig.makeHTTPRequest(url)
+		_, statusCode, fetchError = multiWorkspaceConfig.getInstance().endpoints.get(path, func(url string) ([]byte, int, error) {
+			return multiWorkspaceConfig.makeHTTPStreamRequest(url, func(body io.Reader, header http.Header) error {
+				verifier := multiWorkspaceConfig.getInstance().verifier
+				payloadVerifier := verifier.newPayloadVerifier(header.Get(configSignatureHeader))
+				verifiedBody := io.TeeReader(body, payloadVerifier)
+				var err error
+				// a truncated or malformed body fails the fetch so that it is retried, a truncated one on a mirror
+				if workspaces, err = decodeHostedWorkspaceConfig(verifiedBody, multiWorkspaceConfig.getInstance().fieldChecker); err != nil {
+					return fmt.Errorf("failed to decode hosted workspace config: %w", err)
+				}
+				// the signature covers the whole body, including anything the decoder stopped short of
+				if _, err = io.Copy(ioutil.Discard, verifiedBody); err != nil {
+					return err
+				}
+				return verifier.verifyStreamedPayload("hosted", payloadVerifier)
+			})
+		})
+		if errors.Is(fetchError, ErrConfigSignatureMissing) || errors.Is(fetchError, ErrConfigSignatureInvalid) {
+			// every endpoint has been tried, fetching the same payload again won't change its signature
+			return backoff.Permanent(fetchError)
+		}
 		return fetchError
 	}
 
@@ -86,43 +119,117 @@ func (multiWorkspaceConfig *MultiWorkspaceConfig) Get() (ConfigT, bool) {
 		pkgLogger.Error("Error sending request to the server", err)
 		return ConfigT{}, false
 	}
-	var workspaces WorkspacesT
-	err = json.Unmarshal(respBody, &workspaces.WorkspaceSourcesMap)
-	if err != nil {
-		pkgLogger.Error("Error while parsing request", err, string(respBody), statusCode)
+	if statusCode < 200 || statusCode >= 300 {
+		pkgLogger.Errorf("[[ Multi-workspace-config ]] Failed to fetch multi workspace config. statusCode: %v", statusCode)
 		return ConfigT{}, false
 	}
 
-	writeKeyToWorkspaceIDMap := make(map[string]string)
-	workspaceIDToLibrariesMap := make(map[string]LibrariesT)
-	sourcesJSON := ConfigT{}
-	sourcesJSON.Sources = make([]SourceT, 0)
-	for workspaceID, workspaceConfig := range workspaces.WorkspaceSourcesMap {
+	sources := workspaces.sources
+	// the staged index doesn't keep the sources, they are returned with the config
+	workspaces.sources = nil
+	multiWorkspaceConfig.workspaceWriteKeysMapLock.Lock()
+	multiWorkspaceConfig.fetched = workspaces
+	multiWorkspaceConfig.workspaceWriteKeysMapLock.Unlock()
+
+	return ConfigT{Sources: sources}, true
+}
+
+// publishFetchedWorkspaces replaces the write keys, libraries and settings of the hosted workspaces with the fetched ones, reporting the settings that changed
+func (multiWorkspaceConfig *MultiWorkspaceConfig) publishFetchedWorkspaces() {
+	multiWorkspaceConfig.workspaceWriteKeysMapLock.Lock()
+	defer multiWorkspaceConfig.workspaceWriteKeysMapLock.Unlock()
+	fetched := multiWorkspaceConfig.fetched
+	if fetched == nil {
+		return
+	}
+	reportChangedWorkspaceSettings(multiWorkspaceConfig.workspaceIDToSettingsMap, fetched.workspaceIDToSettingsMap)
+	multiWorkspaceConfig.writeKeyToWorkspaceIDMap = fetched.writeKeyToWorkspaceIDMap
+	multiWorkspaceConfig.workspaceIDToLibrariesMap = fetched.workspaceIDToLibrariesMap
+	multiWorkspaceConfig.workspaceIDToSettingsMap = fetched.workspaceIDToSettingsMap
+	multiWorkspaceConfig.fetched = nil
+}
+
+// hostedWorkspacesIndex is built while decoding the hosted workspace config
+type hostedWorkspacesIndex struct {
+	sources                   []SourceT
+	writeKeyToWorkspaceIDMap  map[string]string
+	workspaceIDToLibrariesMap map[string]LibrariesT
+	workspaceIDToSettingsMap  map[string]WorkspaceSettingsT
+}
+
+/*
+decodeHostedWorkspaceConfig decodes a map of workspace ID to ConfigT one workspace at a time,
+so that neither the whole response nor the whole map is held in memory while indexing. A non-nil checker reports unknown and missing fields.
+*/
+func decodeHostedWorkspaceConfig(body io.Reader, checker *configFieldChecker) (*hostedWorkspacesIndex, error) {
+	workspaces := &hostedWorkspacesIndex{
+		sources:                   make([]SourceT, 0),
+		writeKeyToWorkspaceIDMap:  make(map[string]string),
+		workspaceIDToLibrariesMap: make(map[string]LibrariesT),
+		workspaceIDToSettingsMap:  make(map[string]WorkspaceSettingsT),
+	}
+
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return workspaces, nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected hosted workspace config to be an object, got %v", token)
+	}
+
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		workspaceID, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected workspace id, got %v", token)
+		}
+
+		var workspaceConfig ConfigT
+		if checker != nil {
+			// keep the raw config of one workspace at a time to check its fields
+			var raw json.RawMessage
+			if err = decoder.Decode(&raw); err != nil {
+				return nil, fmt.Errorf("failed to decode config of workspace %s: %w", workspaceID, err)
+			}
+			checker.check("hosted", "workspace "+workspaceID, raw)
+			err = json.Unmarshal(raw, &workspaceConfig)
+		} else {
+			err = decoder.Decode(&workspaceConfig)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode config of workspace %s: %w", workspaceID, err)
+		}
 		for _, source := range workspaceConfig.Sources {
-			writeKeyToWorkspaceIDMap[source.WriteKey] = workspaceID
-			workspaceIDToLibrariesMap[workspaceID] = workspaceConfig.Libraries
+			workspaces.writeKeyToWorkspaceIDMap[source.WriteKey] = workspaceID
+			workspaces.workspaceIDToLibrariesMap[workspaceID] = workspaceConfig.Libraries
 		}
-		sourcesJSON.Sources = append(sourcesJSON.Sources, workspaceConfig.Sources...)
+		workspaces.workspaceIDToSettingsMap[workspaceID] = workspaceConfig.Settings
+		workspaces.sources = append(workspaces.sources, workspaceConfig.Sources...)
 	}
 
-	multiWorkspaceConfig.workspaceWriteKeysMapLock.Lock()
-	multiWorkspaceConfig.writeKeyToWorkspaceIDMap = writeKeyToWorkspaceIDMap
-	multiWorkspaceConfig.workspaceIDToLibrariesMap = workspaceIDToLibrariesMap
-	multiWorkspaceConfig.workspaceWriteKeysMapLock.Unlock()
-
-	return sourcesJSON, true
+	if _, err = decoder.Token(); err != nil {
+		return nil, err
+	}
+	return workspaces, nil
 }
 
 //GetRegulations returns regulations from all hosted workspaces
 func (multiWorkspaceConfig *MultiWorkspaceConfig) GetRegulations() (RegulationsT, bool) {
-	url := fmt.Sprintf("%s/hostedWorkspaces", configBackendURL)
+	path := "/hostedWorkspaces"
 
 	var respBody []byte
 	var statusCode int
 
 	operation := func() error {
 		var fetchError error
-		respBody, statusCode, fetchError = multiWorkspaceConfig.makeHTTPRequest(url)
+		respBody, statusCode, fetchError = multiWorkspaceConfig.getInstance().endpoints.get(path, multiWorkspaceConfig.makeHTTPRequest)
 		return fetchError
 	}
 
@@ -149,6 +256,7 @@ func (multiWorkspaceConfig *MultiWorkspaceConfig) GetRegulations() (RegulationsT
 	for _, workspace := range hostedWorkspaces.HostedWorkspaces {
 		wregulations, status := multiWorkspaceConfig.getWorkspaceRegulations(workspace.WorkspaceID)
 		if !status {
+			multiWorkspaceConfig.getInstance().poller.recordWorkspaceSyncError(workspace.WorkspaceID, "failed to fetch workspace regulations")
 			return RegulationsT{}, false
 		}
 		regulationsJSON.WorkspaceRegulations = append(regulationsJSON.WorkspaceRegulations, wregulations...)
@@ -156,8 +264,10 @@ func (multiWorkspaceConfig *MultiWorkspaceConfig) GetRegulations() (RegulationsT
 		var sregulations []SourceRegulationT
 		sregulations, status = multiWorkspaceConfig.getSourceRegulations(workspace.WorkspaceID)
 		if !status {
+			multiWorkspaceConfig.getInstance().poller.recordWorkspaceSyncError(workspace.WorkspaceID, "failed to fetch source regulations")
 			return RegulationsT{}, false
 		}
+		multiWorkspaceConfig.getInstance().poller.recordWorkspaceSyncError(workspace.WorkspaceID, "")
 		regulationsJSON.SourceRegulations = append(regulationsJSON.SourceRegulations, sregulations...)
 	}
 
@@ -169,14 +279,14 @@ func (multiWorkspaceConfig *MultiWorkspaceConfig) getWorkspaceRegulations(worksp
 
 	totalWorkspaceRegulations := []WorkspaceRegulationT{}
 	for {
-		url := fmt.Sprintf("%s/hostedWorkspaceRegulations?workspaceId=%s&start=%d&limit=%d", configBackendURL, workspaceID, start, maxRegulationsPerRequest)
+		path := fmt.Sprintf("/hostedWorkspaceRegulations?workspaceId=%s&start=%d&limit=%d", workspaceID, start, multiWorkspaceConfig.getInstance().setup.MaxRegulationsPerRequest)
 
 		var respBody []byte
 		var statusCode int
 
 		operation := func() error {
 			var fetchError error
-			respBody, statusCode, fetchError = multiWorkspaceConfig.makeHTTPRequest(url)
+			respBody, statusCode, fetchError = multiWorkspaceConfig.getInstance().endpoints.get(path, multiWorkspaceConfig.makeHTTPRequest)
 			return fetchError
 		}
 
@@ -224,14 +334,14 @@ func (multiWorkspaceConfig *MultiWorkspaceConfig) getSourceRegulations(workspace
 
 	totalSourceRegulations := []SourceRegulationT{}
 	for {
-		url := fmt.Sprintf("%s/hostedSourceRegulations?workspaceId=%s&start=%d&limit=%d", configBackendURL, workspaceID, start, maxRegulationsPerRequest)
+		path := fmt.Sprintf("/hostedSourceRegulations?workspaceId=%s&start=%d&limit=%d", workspaceID, start, multiWorkspaceConfig.getInstance().setup.MaxRegulationsPerRequest)
 
 		var respBody []byte
 		var statusCode int
 
 		operation := func() error {
 			var fetchError error
-			respBody, statusCode, fetchError = multiWorkspaceConfig.makeHTTPRequest(url)
+			respBody, statusCode, fetchError = multiWorkspaceConfig.getInstance().endpoints.get(path, multiWorkspaceConfig.makeHTTPRequest)
 			return fetchError
 		}
 
@@ -274,13 +384,41 @@ func (multiWorkspaceConfig *MultiWorkspaceConfig) getSourceRegulations(workspace
 	return totalSourceRegulations, true
 }
 
+/*
+makeHTTPStreamRequest passes the body of a successful response to decode as it is read, instead of buffering it.
+An error returned by decode fails the request.
+*/
+func (multiWorkspaceConfig *MultiWorkspaceConfig) makeHTTPStreamRequest(url string, decode func(body io.Reader, header http.Header) error) ([]byte, int, error) {
+	req, err := Http.NewRequest("GET", url, nil)
+	if err != nil {
+		return []byte{}, 400, err
+	}
+
+	req.SetBasicAuth(multiWorkspaceConfig.getInstance().setup.MultiWorkspaceSecret, "")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return []byte{}, 400, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return []byte{}, resp.StatusCode, decode(resp.Body, resp.Header)
+	}
+
+	respBody, _ := IoUtil.ReadAll(resp.Body)
+	return respBody, resp.StatusCode, nil
+}
+
 func (multiWorkspaceConfig *MultiWorkspaceConfig) makeHTTPRequest(url string) ([]byte, int, error) {
 	req, err := Http.NewRequest("GET", url, nil)
 	if err != nil {
 		return []byte{}, 400, err
 	}
 
-	req.SetBasicAuth(multiWorkspaceSecret, "")
+	req.SetBasicAuth(multiWorkspaceConfig.getInstance().setup.MultiWorkspaceSecret, "")
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{}
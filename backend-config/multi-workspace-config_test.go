@@ -0,0 +1,141 @@
+package backendconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDecodeHostedWorkspaceConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantErr     bool
+		wantSources int
+	}{
+		{name: "two workspaces", body: `{"w1": {"sources": [{"id": "s1", "writeKey": "k1"}]}, "w2": {"sources": [{"id": "s2", "writeKey": "k2"}, {"id": "s3", "writeKey": "k3"}]}}`, wantSources: 3},
+		{name: "no workspace", body: `{}`, wantSources: 0},
+		{name: "null", body: `null`, wantSources: 0},
+		{name: "not an object", body: `[]`, wantErr: true},
+		{name: "truncated", body: `{"w1": {"sources": [{"id": "s1", "wri`, wantErr: true},
+		{name: "truncated after a workspace", body: `{"w1": {"sources": []}`, wantErr: true},
+		{name: "invalid workspace", body: `{"w1": {"sources": 1}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		for _, strict := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s strict=%t", tt.name, strict), func(t *testing.T) {
+				workspaces, err := decodeHostedWorkspaceConfig(strings.NewReader(tt.body), newConfigFieldChecker(strict))
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+				}
+				if err == nil && len(workspaces.sources) != tt.wantSources {
+					t.Errorf("decoded %d sources, want %d", len(workspaces.sources), tt.wantSources)
+				}
+			})
+		}
+	}
+
+	workspaces, _ := decodeHostedWorkspaceConfig(strings.NewReader(`{"w1": {"sources": [{"writeKey": "k1"}], "libraries": [{"versionId": "l1"}]}, "w2": {"sources": [{"writeKey": "k2"}]}}`), nil)
+	if workspaces.writeKeyToWorkspaceIDMap["k1"] != "w1" || workspaces.writeKeyToWorkspaceIDMap["k2"] != "w2" {
+		t.Errorf("write keys indexed as %v", workspaces.writeKeyToWorkspaceIDMap)
+	}
+	if libraries := workspaces.workspaceIDToLibrariesMap["w1"]; len(libraries) != 1 || libraries[0].VersionID != "l1" {
+		t.Errorf("libraries of w1 indexed as %v", libraries)
+	}
+}
+
+func TestMultiWorkspaceConfigTruncatedResponse(t *testing.T) {
+	config := testSourcesConfig("d1")
+	body, _ := json.Marshal(map[string]ConfigT{config.WorkspaceID: config})
+
+	primary := newTestConfigBackend(t, config)
+	primary.hostedBody = body[:len(body)/2]
+	mirror := newTestConfigBackend(t, config)
+	instance := newTestInstance(t, primary, func(setup *BackendConfigSetup) {
+		setup.IsMultiWorkspace = true
+		setup.ConfigBackendMirrorUrls = []string{mirror.URL}
+	})
+
+	fetched, ok := instance.provider.Get()
+	if !ok || len(fetched.Sources) != 1 {
+		t.Fatalf("Get = %d sources, %t, want the config of the mirror", len(fetched.Sources), ok)
+	}
+	if status := instance.endpoints.status(); status[0].Healthy || !status[1].Healthy {
+		t.Errorf("endpoints %+v, want the primary serving a truncated config unhealthy", status)
+	}
+
+	instance = newTestInstance(t, primary, func(setup *BackendConfigSetup) {
+		setup.IsMultiWorkspace = true
+	})
+	if _, ok = instance.provider.Get(); ok {
+		t.Error("Get succeeded with a truncated config")
+	}
+}
+
+// benchmarkHostedWorkspaceConfig is a hosted workspace config of 200 workspaces with 20 sources of 5 destinations each
+func benchmarkHostedWorkspaceConfig() []byte {
+	workspaces := make(map[string]ConfigT)
+	for w := 0; w < 200; w++ {
+		workspaceID := fmt.Sprintf("workspace-%d", w)
+		config := ConfigT{WorkspaceID: workspaceID, Libraries: LibrariesT{{VersionID: workspaceID + "-library"}}}
+		for s := 0; s < 20; s++ {
+			source := SourceT{ID: fmt.Sprintf("%s-source-%d", workspaceID, s), Name: "source", WriteKey: fmt.Sprintf("%s-key-%d", workspaceID, s), WorkspaceID: workspaceID, Enabled: true}
+			for d := 0; d < 5; d++ {
+				source.Destinations = append(source.Destinations, DestinationT{
+					ID:                    fmt.Sprintf("%s-destination-%d", source.ID, d),
+					Name:                  "destination",
+					DestinationDefinition: DestinationDefinitionT{ID: "definition", Name: "WEBHOOK", Config: map[string]interface{}{"secretKeys": []interface{}{"apiKey"}}},
+					Config:                map[string]interface{}{"webhookUrl": "https://example.com/" + source.ID, "apiKey": strings.Repeat("k", 32), "headers": []interface{}{map[string]interface{}{"from": "a", "to": "b"}}},
+					Enabled:               true,
+					IsProcessorEnabled:    true,
+					Transformations:       []TransformationT{{VersionID: "transformation"}},
+				})
+			}
+			config.Sources = append(config.Sources, source)
+		}
+		workspaces[workspaceID] = config
+	}
+	payload, _ := json.Marshal(workspaces)
+	return payload
+}
+
+// BenchmarkDecodeHostedWorkspaceConfig compares decoding the hosted workspace config as it is streamed with buffering and unmarshalling it whole
+func BenchmarkDecodeHostedWorkspaceConfig(b *testing.B) {
+	payload := benchmarkHostedWorkspaceConfig()
+
+	b.Run("streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(payload)))
+		for i := 0; i < b.N; i++ {
+			if _, err := decodeHostedWorkspaceConfig(bytes.NewReader(payload), nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(payload)))
+		for i := 0; i < b.N; i++ {
+			body, err := ioutil.ReadAll(bytes.NewReader(payload))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var workspaces map[string]ConfigT
+			if err = json.Unmarshal(body, &workspaces); err != nil {
+				b.Fatal(err)
+			}
+			sources := make([]SourceT, 0)
+			writeKeyToWorkspaceIDMap := make(map[string]string)
+			for workspaceID, config := range workspaces {
+				for _, source := range config.Sources {
+					writeKeyToWorkspaceIDMap[source.WriteKey] = workspaceID
+				}
+				sources = append(sources, config.Sources...)
+			}
+		}
+	})
+}
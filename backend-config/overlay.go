@@ -0,0 +1,184 @@
+package backendconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+/*
+ConfigOverlayT is read from ConfigOverlayPath and merged into every config fetched from the config backend before it is published.
+
+	{
+		"destinations": {
+			"<destination id>": {"enabled": false, "isProcessorEnabled": true, "config": {"endpoint": "http://localhost:8080"}},
+			"<other destination id>": {"schedule": {"blackouts": [{"start": "01:00", "end": "03:00"}]}}
+		},
+		"debugDestinations": [
+			{"sourceId": "<source id>", "destination": {"id": "debug-webhook", "name": "debug", "enabled": true, ...}}
+		]
+	}
+
+Destination overrides apply to the destination in every source it is attached to. A schedule replaces the schedule of the destination.
+*/
+type ConfigOverlayT struct {
+	Destinations      map[string]DestinationOverrideT `json:"destinations"`
+	DebugDestinations []DebugDestinationT             `json:"debugDestinations"`
+}
+
+// DestinationOverrideT overrides the fields of a destination that are set
+type DestinationOverrideT struct {
+	Enabled            *bool                  `json:"enabled"`
+	IsProcessorEnabled *bool                  `json:"isProcessorEnabled"`
+	Config             map[string]interface{} `json:"config"`
+	Schedule           *DestinationScheduleT  `json:"schedule"`
+}
+
+// DebugDestinationT is a local-only destination added to a source
+type DebugDestinationT struct {
+	SourceID    string       `json:"sourceId"`
+	Destination DestinationT `json:"destination"`
+}
+
+/*
+appliedOverlay lists the overrides applied to each destination, by destination ID. Overrides matching the fetched config are listed
+too, marked as unchanged, so that an override left behind once the config backend caught up is still visible.
+*/
+type appliedOverlay map[string][]string
+
+// overrideUnchangedSuffix marks an override that matches the fetched config
+const overrideUnchangedSuffix = " (unchanged)"
+
+func (applied appliedOverlay) add(destinationID string, override string, changed bool) {
+	if !changed {
+		override += overrideUnchangedSuffix
+	}
+	applied[destinationID] = append(applied[destinationID], override)
+}
+
+// loadConfigOverlay reads the overlay file, which is read again on every poll so that it can be edited without a restart
+func loadConfigOverlay(path string) (*ConfigOverlayT, error) {
+	data, err := IoUtil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read config overlay from file: %s with error : %w", path, err)
+	}
+	var overlay ConfigOverlayT
+	if err = json.Unmarshal(data, &overlay); err != nil {
+		return nil, fmt.Errorf("Unable to parse config overlay from file: %s: %w", path, describeJSONError(data, err))
+	}
+	return &overlay, nil
+}
+
+/*
+applyConfigOverlay merges overlay into config and returns the overrides applied, whether they change config or not. Config values
+overridden are never reported, as they may be secrets. Overrides of destinations that aren't in config are reported as stale, as the overlay has likely been forgotten.
+*/
+func applyConfigOverlay(config *ConfigT, overlay *ConfigOverlayT) appliedOverlay {
+	applied := make(appliedOverlay)
+	if overlay == nil {
+		return applied
+	}
+
+	for i := range config.Sources {
+		source := &config.Sources[i]
+		for j := range source.Destinations {
+			destination := &source.Destinations[j]
+			override, ok := overlay.Destinations[destination.ID]
+			if !ok {
+				continue
+			}
+			if override.Enabled != nil {
+				applied.add(destination.ID, fmt.Sprintf("enabled=%t", *override.Enabled), destination.Enabled != *override.Enabled)
+				destination.Enabled = *override.Enabled
+			}
+			if override.IsProcessorEnabled != nil {
+				applied.add(destination.ID, fmt.Sprintf("isProcessorEnabled=%t", *override.IsProcessorEnabled), destination.IsProcessorEnabled != *override.IsProcessorEnabled)
+				destination.IsProcessorEnabled = *override.IsProcessorEnabled
+			}
+			if len(override.Config) > 0 {
+				destinationConfig, _ := copyConfigValue(destination.Config).(map[string]interface{})
+				if destinationConfig == nil {
+					destinationConfig = make(map[string]interface{})
+				}
+				keys := make([]string, 0, len(override.Config))
+				for key := range override.Config {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					applied.add(destination.ID, "config."+key, !reflect.DeepEqual(destinationConfig[key], override.Config[key]))
+					destinationConfig[key] = copyConfigValue(override.Config[key])
+				}
+				destination.Config = destinationConfig
+			}
+			if override.Schedule != nil {
+				applied.add(destination.ID, "schedule", !reflect.DeepEqual(destination.Schedule, override.Schedule))
+				destination.Schedule = override.Schedule
+			}
+		}
+	}
+
+	for _, debugDestination := range overlay.DebugDestinations {
+		found := false
+		for i := range config.Sources {
+			if config.Sources[i].ID == debugDestination.SourceID {
+				config.Sources[i].Destinations = append(config.Sources[i].Destinations, debugDestination.Destination)
+				found = true
+			}
+		}
+		if found {
+			applied.add(debugDestination.Destination.ID, "debugDestination", true)
+		} else {
+			pkgLogger.Warnf("Config overlay adds debug destination %s to source %s, which isn't in the workspace config", debugDestination.Destination.ID, debugDestination.SourceID)
+		}
+	}
+
+	for destinationID := range overlay.Destinations {
+		if !configHasDestination(*config, destinationID) {
+			pkgLogger.Warnf("Config overlay overrides destination %s, which isn't in the workspace config", destinationID)
+		}
+	}
+	return applied
+}
+
+// copyConfigSources returns config with its own sources and destinations slices, so that applyConfigOverlay can merge into one of them only
+func copyConfigSources(config ConfigT) ConfigT {
+	sources := make([]SourceT, len(config.Sources))
+	for i, source := range config.Sources {
+		source.Destinations = append([]DestinationT(nil), source.Destinations...)
+		sources[i] = source
+	}
+	config.Sources = sources
+	return config
+}
+
+func configHasDestination(config ConfigT, destinationID string) bool {
+	for _, source := range config.Sources {
+		for _, destination := range source.Destinations {
+			if destination.ID == destinationID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reportConfigOverlay logs every applied override and gauges their number, once per published config
+func reportConfigOverlay(applied appliedOverlay) {
+	overrideCount, debugDestinationCount := 0, 0
+	for destinationID, overrides := range applied {
+		for _, override := range overrides {
+			pkgLogger.Warnf("Config overlay applied to destination %s: %s", destinationID, override)
+			if override == "debugDestination" {
+				debugDestinationCount++
+			} else {
+				overrideCount++
+			}
+		}
+	}
+	stats.NewTaggedStat("config_backend.overlay_overrides", stats.GaugeType, map[string]string{"kind": "override"}).Gauge(overrideCount)
+	stats.NewTaggedStat("config_backend.overlay_overrides", stats.GaugeType, map[string]string{"kind": "debugDestination"}).Gauge(debugDestinationCount)
+}
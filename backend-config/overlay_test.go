@@ -0,0 +1,110 @@
+package backendconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyConfigOverlay(t *testing.T) {
+	enabled, disabled := true, false
+	schedule := &DestinationScheduleT{Blackouts: []RecurringBlackoutT{{Start: "01:00", End: "03:00"}}}
+	tests := []struct {
+		name     string
+		override DestinationOverrideT
+		// configure modifies the fetched destination before the overlay is applied
+		configure   func(destination *DestinationT)
+		want        []string
+		wantEnabled bool
+		wantConfig  map[string]interface{}
+	}{
+		{
+			name:        "disabled",
+			override:    DestinationOverrideT{Enabled: &disabled},
+			want:        []string{"enabled=false"},
+			wantEnabled: false,
+		},
+		{
+			name:        "enabled unchanged",
+			override:    DestinationOverrideT{Enabled: &enabled},
+			want:        []string{"enabled=true (unchanged)"},
+			wantEnabled: true,
+		},
+		{
+			name:        "processor disabled unchanged",
+			override:    DestinationOverrideT{IsProcessorEnabled: &disabled},
+			configure:   func(destination *DestinationT) { destination.IsProcessorEnabled = false },
+			want:        []string{"isProcessorEnabled=false (unchanged)"},
+			wantEnabled: true,
+		},
+		{
+			name:        "config",
+			override:    DestinationOverrideT{Config: map[string]interface{}{"webhookUrl": "https://example.com/destination-1", "endpoint": "http://localhost:8080"}},
+			want:        []string{"config.endpoint", "config.webhookUrl (unchanged)"},
+			wantEnabled: true,
+			wantConfig:  map[string]interface{}{"webhookUrl": "https://example.com/destination-1", "endpoint": "http://localhost:8080"},
+		},
+		{
+			name:        "schedule",
+			override:    DestinationOverrideT{Schedule: schedule},
+			want:        []string{"schedule"},
+			wantEnabled: true,
+		},
+		{
+			name:        "schedule unchanged",
+			override:    DestinationOverrideT{Schedule: schedule},
+			configure:   func(destination *DestinationT) { destination.Schedule = schedule },
+			want:        []string{"schedule (unchanged)"},
+			wantEnabled: true,
+		},
+		{
+			name:        "empty",
+			override:    DestinationOverrideT{},
+			want:        nil,
+			wantEnabled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testSourcesConfig("destination-1")
+			if tt.configure != nil {
+				tt.configure(&config.Sources[0].Destinations[0])
+			}
+			fetched := copyConfigSources(config)
+
+			applied := applyConfigOverlay(&config, &ConfigOverlayT{Destinations: map[string]DestinationOverrideT{"destination-1": tt.override}})
+			if !reflect.DeepEqual(applied["destination-1"], tt.want) {
+				t.Errorf("got overrides %v, want %v", applied["destination-1"], tt.want)
+			}
+			destination := config.Sources[0].Destinations[0]
+			if destination.Enabled != tt.wantEnabled {
+				t.Errorf("got enabled %t, want %t", destination.Enabled, tt.wantEnabled)
+			}
+			if tt.wantConfig != nil && !reflect.DeepEqual(destination.Config, tt.wantConfig) {
+				t.Errorf("got config %v, want %v", destination.Config, tt.wantConfig)
+			}
+			if !fetched.Sources[0].Destinations[0].Enabled || len(fetched.Sources[0].Destinations[0].Config) != 1 {
+				t.Errorf("overlay modified the fetched config: %+v", fetched.Sources[0].Destinations[0])
+			}
+		})
+	}
+}
+
+func TestApplyConfigOverlayDebugDestinations(t *testing.T) {
+	config := testSourcesConfig("destination-1", "destination-2")
+	fetched := copyConfigSources(config)
+	overlay := &ConfigOverlayT{DebugDestinations: []DebugDestinationT{
+		{SourceID: "source-destination-1", Destination: DestinationT{ID: "debug-1", Enabled: true}},
+		{SourceID: "unknown-source", Destination: DestinationT{ID: "debug-2", Enabled: true}},
+	}}
+
+	applied := applyConfigOverlay(&config, overlay)
+	if !reflect.DeepEqual(applied, appliedOverlay{"debug-1": {"debugDestination"}}) {
+		t.Errorf("got overrides %v", applied)
+	}
+	if !configHasDestination(config, "debug-1") || configHasDestination(config, "debug-2") {
+		t.Errorf("unexpected destinations %+v", config.Sources)
+	}
+	if configHasDestination(fetched, "debug-1") {
+		t.Error("debug destination added to the fetched config")
+	}
+}
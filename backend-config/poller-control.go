@@ -0,0 +1,88 @@
+package backendconfig
+
+import (
+	"sync"
+	"time"
+)
+
+// SyncErrorT is the last error hit while syncing config or regulations
+type SyncErrorT struct {
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// pollerControl lets admin functions refresh, pause and inspect the pollers of an instance
+type pollerControl struct {
+	configRefreshCh      chan struct{}
+	regulationsRefreshCh chan struct{}
+
+	lock                 sync.RWMutex
+	pollingEnabled       bool
+	lastConfigError      *SyncErrorT
+	lastRegulationsError *SyncErrorT
+	// subscriptionCounts is the cumulative number of Subscribe calls per topic, subscribers are never removed from the event bus
+	subscriptionCounts map[Topic]int
+	// workspaceSyncErrors holds the last error of each hosted workspace that failed to sync, cleared once it syncs again
+	workspaceSyncErrors map[string]*SyncErrorT
+}
+
+func newPollerControl() *pollerControl {
+	return &pollerControl{
+		configRefreshCh:      make(chan struct{}, 1),
+		regulationsRefreshCh: make(chan struct{}, 1),
+		pollingEnabled:       true,
+		subscriptionCounts:   make(map[Topic]int),
+		workspaceSyncErrors:  make(map[string]*SyncErrorT),
+	}
+}
+
+// requestRefresh wakes up a poller waiting on refreshCh. A refresh already pending is not queued twice.
+func requestRefresh(refreshCh chan struct{}) {
+	select {
+	case refreshCh <- struct{}{}:
+	default:
+	}
+}
+
+func (poller *pollerControl) isPollingEnabled() bool {
+	poller.lock.RLock()
+	defer poller.lock.RUnlock()
+	return poller.pollingEnabled
+}
+
+func (poller *pollerControl) setPollingEnabled(enabled bool) {
+	poller.lock.Lock()
+	defer poller.lock.Unlock()
+	poller.pollingEnabled = enabled
+}
+
+// recordSyncError stores err as the last config or regulations error
+func (poller *pollerControl) recordSyncError(lastError **SyncErrorT, err string) {
+	poller.lock.Lock()
+	defer poller.lock.Unlock()
+	*lastError = &SyncErrorT{Error: err, Time: time.Now()}
+}
+
+// clearSyncError clears the last config or regulations error once a sync succeeds again
+func (poller *pollerControl) clearSyncError(lastError **SyncErrorT) {
+	poller.lock.Lock()
+	defer poller.lock.Unlock()
+	*lastError = nil
+}
+
+// recordWorkspaceSyncError stores err as the last error of a hosted workspace, an empty err clears it
+func (poller *pollerControl) recordWorkspaceSyncError(workspaceID string, err string) {
+	poller.lock.Lock()
+	defer poller.lock.Unlock()
+	if err == "" {
+		delete(poller.workspaceSyncErrors, workspaceID)
+		return
+	}
+	poller.workspaceSyncErrors[workspaceID] = &SyncErrorT{Error: err, Time: time.Now()}
+}
+
+func (poller *pollerControl) recordSubscription(topic Topic) {
+	poller.lock.Lock()
+	defer poller.lock.Unlock()
+	poller.subscriptionCounts[topic]++
+}
@@ -0,0 +1,96 @@
+package backendconfig
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/rudderlabs/rudder-utils/utils"
+)
+
+func TestSyncErrorsClearedAfterSuccess(t *testing.T) {
+	tests := []struct {
+		name      string
+		update    func(instance *Instance) (bool, bool)
+		lastError func(poller *pollerControl) *SyncErrorT
+		// change makes the next successful fetch return something new
+		change func(backend *testConfigBackend)
+	}{
+		{
+			name:      "config unchanged",
+			update:    func(instance *Instance) (bool, bool) { return instance.configUpdate(testStatConfigBackendError) },
+			lastError: func(poller *pollerControl) *SyncErrorT { return poller.lastConfigError },
+			change:    func(backend *testConfigBackend) {},
+		},
+		{
+			name:      "config changed",
+			update:    func(instance *Instance) (bool, bool) { return instance.configUpdate(testStatConfigBackendError) },
+			lastError: func(poller *pollerControl) *SyncErrorT { return poller.lastConfigError },
+			change: func(backend *testConfigBackend) {
+				backend.setConfig(testSourcesConfig("destination-1", "destination-2"))
+			},
+		},
+		{
+			name:      "regulations unchanged",
+			update:    func(instance *Instance) (bool, bool) { return instance.regulationsUpdate(testStatConfigBackendError) },
+			lastError: func(poller *pollerControl) *SyncErrorT { return poller.lastRegulationsError },
+			change:    func(backend *testConfigBackend) {},
+		},
+		{
+			name:      "regulations changed",
+			update:    func(instance *Instance) (bool, bool) { return instance.regulationsUpdate(testStatConfigBackendError) },
+			lastError: func(poller *pollerControl) *SyncErrorT { return poller.lastRegulationsError },
+			change: func(backend *testConfigBackend) {
+				backend.setRegulations(RegulationsT{WorkspaceRegulations: []WorkspaceRegulationT{{ID: "regulation-1", RegulationType: "suppress", UserID: "user-1"}}})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestConfigBackend(t, testSourcesConfig("destination-1"))
+			instance := newTestInstance(t, backend, nil)
+			if _, ok := tt.update(instance); !ok {
+				t.Fatal("first update failed")
+			}
+
+			backend.setStatusCode(http.StatusInternalServerError)
+			if _, ok := tt.update(instance); ok {
+				t.Fatal("update succeeded while the backend fails")
+			}
+			if tt.lastError(instance.poller) == nil {
+				t.Fatal("failed update didn't record an error")
+			}
+
+			backend.setStatusCode(http.StatusOK)
+			tt.change(backend)
+			if _, ok := tt.update(instance); !ok {
+				t.Fatal("update failed after the backend recovered")
+			}
+			if err := tt.lastError(instance.poller); err != nil {
+				t.Errorf("error not cleared after a successful update: %+v", err)
+			}
+		})
+	}
+}
+
+func TestSubscriptionCountsAreCumulative(t *testing.T) {
+	backend := newTestConfigBackend(t, testSourcesConfig("destination-1"))
+	instance := newTestInstance(t, backend, nil)
+
+	for i := 0; i < 3; i++ {
+		instance.Subscribe(make(chan utils.DataEvent, 1), TopicBackendConfig)
+	}
+	instance.Subscribe(make(chan utils.DataEvent, 1), TopicProcessConfig)
+
+	var reply string
+	if err := (&BackendConfigAdmin{instance: instance}).Subscribers(struct{}{}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[Topic]int)
+	if err := json.Unmarshal([]byte(reply), &counts); err != nil {
+		t.Fatal(err)
+	}
+	if counts[TopicBackendConfig] != 3 || counts[TopicProcessConfig] != 1 {
+		t.Errorf("got subscription counts %v, want 3 for %s and 1 for %s", counts, TopicBackendConfig, TopicProcessConfig)
+	}
+}
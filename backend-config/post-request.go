@@ -0,0 +1,304 @@
+package backendconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+// PostRetryPolicy controls how failed POST requests to the config backend are retried
+type PostRetryPolicy struct {
+	MaxRetries      uint64
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+// DefaultPostRetryPolicy is used when the PostRetryPolicy of BackendConfigSetup is zero
+var DefaultPostRetryPolicy = PostRetryPolicy{MaxRetries: 3, InitialInterval: 500 * time.Millisecond, MaxInterval: 10 * time.Second, MaxElapsedTime: time.Minute}
+
+// PostRequestOptions controls retries and spooling of a single POST request
+type PostRequestOptions struct {
+	// Idempotent requests are retried according to the configured PostRetryPolicy
+	Idempotent bool
+	// Spool writes the payload to PostSpoolDir if it couldn't be delivered, to be retried later
+	Spool bool
+}
+
+// PostRequestError is returned when the config backend responds with a non 2xx status code
+type PostRequestError struct {
+	URL        string
+	StatusCode int
+	Body       []byte
+}
+
+func (e *PostRequestError) Error() string {
+	return fmt.Sprintf("config backend responded to %s with status %d: %s", e.URL, e.StatusCode, string(e.Body))
+}
+
+// spooledRequest is the on disk format of a payload that couldn't be delivered. An empty URL refers to the config backend endpoints.
+type spooledRequest struct {
+	URL        string          `json:"url,omitempty"`
+	Endpoint   string          `json:"endpoint"`
+	Payload    json.RawMessage `json:"payload"`
+	Idempotent bool            `json:"idempotent"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+/*
+MakePostRequest posts data to url+endpoint.
+
+Deprecated: Use MakePostRequestWithContext which reports marshalling, transport and status code errors
+*/
+func MakePostRequest(url string, endpoint string, data interface{}) (response []byte, ok bool) {
+	body, _, err := MakePostRequestWithContext(context.Background(), url, endpoint, data, PostRequestOptions{})
+	if err != nil {
+		pkgLogger.Errorf("ConfigBackend: %s", err.Error())
+		return body, false
+	}
+	return body, true
+}
+
+/*
+MakeBackendPostRequest posts data to endpoint of the config backend.
+
+Deprecated: Use MakeBackendPostRequestWithContext
+*/
+func MakeBackendPostRequest(endpoint string, data interface{}) (response []byte, ok bool) {
+	body, _, err := MakeBackendPostRequestWithContext(context.Background(), endpoint, data, PostRequestOptions{})
+	if err != nil {
+		pkgLogger.Errorf("ConfigBackend: %s", err.Error())
+		return body, false
+	}
+	return body, true
+}
+
+// MakeBackendPostRequestWithContext posts data to endpoint of the config backend of the instance created by Setup
+func MakeBackendPostRequestWithContext(ctx context.Context, endpoint string, data interface{}, opts PostRequestOptions) ([]byte, int, error) {
+	return defaultInstance.MakeBackendPostRequestWithContext(ctx, endpoint, data, opts)
+}
+
+// MakePostRequestWithContext posts data as JSON to url+endpoint with the settings of the instance created by Setup
+func MakePostRequestWithContext(ctx context.Context, url string, endpoint string, data interface{}, opts PostRequestOptions) ([]byte, int, error) {
+	return defaultInstance.MakePostRequestWithContext(ctx, url, endpoint, data, opts)
+}
+
+// DeliverSpooledRequests sends the requests spooled by the instance created by Setup
+func DeliverSpooledRequests(ctx context.Context) error {
+	return defaultInstance.DeliverSpooledRequests(ctx)
+}
+
+// MakeBackendPostRequestWithContext posts data to endpoint of the config backend, failing over to mirrors if it is unavailable
+func (instance *Instance) MakeBackendPostRequestWithContext(ctx context.Context, endpoint string, data interface{}, opts PostRequestOptions) ([]byte, int, error) {
+	return instance.makePostRequest(ctx, "", endpoint, data, opts)
+}
+
+/*
+MakePostRequestWithContext posts data as JSON to url+endpoint and returns the response body and status code.
+An error is returned if data can't be marshalled, the request fails or the response status is not 2xx.
+Idempotent requests are retried on transport errors, 408, 429 and 5xx responses.
+If the request still fails and opts.Spool is set, the payload is written to PostSpoolDir and delivered later.
+*/
+func (instance *Instance) MakePostRequestWithContext(ctx context.Context, url string, endpoint string, data interface{}, opts PostRequestOptions) ([]byte, int, error) {
+	if url == "" {
+		return []byte{}, 0, fmt.Errorf("no url given for %s", endpoint)
+	}
+	return instance.makePostRequest(ctx, url, endpoint, data, opts)
+}
+
+// makePostRequest posts to url+endpoint, or to the config backend endpoints if url is empty
+func (instance *Instance) makePostRequest(ctx context.Context, url string, endpoint string, data interface{}, opts PostRequestOptions) ([]byte, int, error) {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return []byte{}, 0, fmt.Errorf("failed to marshal payload for %s%s: %w", url, endpoint, err)
+	}
+
+	body, statusCode, err := instance.deliverPost(ctx, url, endpoint, dataJSON, opts.Idempotent)
+	if err != nil && opts.Spool && isRetryablePostFailure(statusCode) {
+		if spoolErr := instance.spoolRequest(url, endpoint, dataJSON, opts.Idempotent); spoolErr != nil {
+			pkgLogger.Errorf("ConfigBackend: Failed to spool request to %s%s, Error: %s", url, endpoint, spoolErr.Error())
+		}
+	}
+	return body, statusCode, err
+}
+
+func (instance *Instance) deliverPost(ctx context.Context, url string, endpoint string, dataJSON []byte, idempotent bool) ([]byte, int, error) {
+	if url != "" {
+		return instance.postWithRetries(ctx, url, endpoint, dataJSON, idempotent)
+	}
+	return instance.endpoints.request(func(baseURL string) ([]byte, int, error) {
+		return instance.postWithRetries(ctx, baseURL, endpoint, dataJSON, idempotent)
+	})
+}
+
+func (instance *Instance) postWithRetries(ctx context.Context, url string, endpoint string, dataJSON []byte, idempotent bool) ([]byte, int, error) {
+	if !idempotent {
+		return instance.post(ctx, url, endpoint, dataJSON)
+	}
+
+	var body []byte
+	var statusCode int
+
+	operation := func() error {
+		var postErr error
+		body, statusCode, postErr = instance.post(ctx, url, endpoint, dataJSON)
+		if postErr != nil && !isRetryablePostFailure(statusCode) {
+			return backoff.Permanent(postErr)
+		}
+		return postErr
+	}
+
+	err := backoff.RetryNotify(operation, instance.newPostBackOff(ctx), func(err error, t time.Duration) {
+		pkgLogger.Errorf("ConfigBackend: Failed to post to %s%s with error: %s, retrying after %v", url, endpoint, err.Error(), t)
+	})
+	return body, statusCode, err
+}
+
+func (instance *Instance) newPostBackOff(ctx context.Context) backoff.BackOff {
+	postRetryPolicy := instance.setup.PostRetryPolicy
+	exponentialBackOff := backoff.NewExponentialBackOff()
+	exponentialBackOff.InitialInterval = postRetryPolicy.InitialInterval
+	exponentialBackOff.MaxInterval = postRetryPolicy.MaxInterval
+	exponentialBackOff.MaxElapsedTime = postRetryPolicy.MaxElapsedTime
+	return backoff.WithContext(backoff.WithMaxRetries(exponentialBackOff, postRetryPolicy.MaxRetries), ctx)
+}
+
+func (instance *Instance) post(ctx context.Context, url string, endpoint string, dataJSON []byte) ([]byte, int, error) {
+	backendURL := fmt.Sprintf("%s%s", url, endpoint)
+	request, err := Http.NewRequest("POST", backendURL, bytes.NewBuffer(dataJSON))
+	if err != nil {
+		return []byte{}, 0, fmt.Errorf("failed to make request to %s: %w", backendURL, err)
+	}
+	request = request.WithContext(ctx)
+
+	request.SetBasicAuth(instance.setup.WorkSpaceToken, "")
+	request.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(request)
+	if err != nil {
+		return []byte{}, 0, fmt.Errorf("failed to execute request to %s: %w", backendURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := IoUtil.ReadAll(resp.Body)
+	if err != nil {
+		return []byte{}, resp.StatusCode, fmt.Errorf("failed to read response from %s: %w", backendURL, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, resp.StatusCode, &PostRequestError{URL: backendURL, StatusCode: resp.StatusCode, Body: body}
+	}
+
+	pkgLogger.Debugf("ConfigBackend: Successful %s", string(body))
+	return body, resp.StatusCode, nil
+}
+
+// isRetryablePostFailure reports whether a failed request may succeed if sent again. A zero statusCode means no response was received.
+func isRetryablePostFailure(statusCode int) bool {
+	return statusCode == 0 || statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+func (instance *Instance) spoolRequest(url string, endpoint string, dataJSON []byte, idempotent bool) error {
+	postSpoolDir := instance.setup.PostSpoolDir
+	if postSpoolDir == "" {
+		return fmt.Errorf("spooling requested but no spool directory is configured")
+	}
+	if err := os.MkdirAll(postSpoolDir, 0700); err != nil {
+		return err
+	}
+
+	spooled, err := json.Marshal(spooledRequest{URL: url, Endpoint: endpoint, Payload: dataJSON, Idempotent: idempotent, CreatedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that a partially written request is never delivered
+	tmpFile, err := ioutil.TempFile(postSpoolDir, ".spool-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmpFile.Write(spooled); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	fileName := fmt.Sprintf("%d-%s.json", time.Now().UnixNano(), strings.TrimPrefix(filepath.Base(tmpFile.Name()), ".spool-"))
+	return os.Rename(tmpFile.Name(), filepath.Join(postSpoolDir, fileName))
+}
+
+/*
+DeliverSpooledRequests sends requests spooled by MakePostRequestWithContext, oldest first.
+Delivered requests and requests rejected by the config backend with a non retryable status are removed from the spool.
+It stops at the first request that fails with a retryable error and returns that error.
+*/
+func (instance *Instance) DeliverSpooledRequests(ctx context.Context) error {
+	postSpoolDir := instance.setup.PostSpoolDir
+	if postSpoolDir == "" {
+		return nil
+	}
+	files, err := ioutil.ReadDir(postSpoolDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		filePath := filepath.Join(postSpoolDir, file.Name())
+		data, err := IoUtil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		var request spooledRequest
+		if err = json.Unmarshal(data, &request); err != nil {
+			pkgLogger.Errorf("ConfigBackend: Dropping unparsable spooled request %s, Error: %s", filePath, err.Error())
+			os.Remove(filePath)
+			continue
+		}
+
+		_, statusCode, err := instance.deliverPost(ctx, request.URL, request.Endpoint, request.Payload, request.Idempotent)
+		if err != nil && isRetryablePostFailure(statusCode) {
+			return err
+		}
+		if err != nil {
+			pkgLogger.Errorf("ConfigBackend: Dropping spooled request %s rejected by config backend, Error: %s", filePath, err.Error())
+		}
+		if err = os.Remove(filePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (instance *Instance) pollSpooledRequests() {
+	for {
+		if err := instance.DeliverSpooledRequests(context.Background()); err != nil {
+			pkgLogger.Errorf("ConfigBackend: Failed to deliver spooled requests, Error: %s", err.Error())
+		}
+		if !instance.sleep(instance.setup.PostSpoolRetryInterval) {
+			return
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package backendconfig
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// postTestServer responds with the next of statusCodes to every request, repeating the last one, and counts the requests
+func postTestServer(t *testing.T, statusCodes ...int) (*httptest.Server, *int32, chan []byte) {
+	var requests int32
+	bodies := make(chan []byte, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		if n > len(statusCodes) {
+			n = len(statusCodes)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+		w.WriteHeader(statusCodes[n-1])
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests, bodies
+}
+
+func newPostTestInstance(spoolDir string) *Instance {
+	setup := DefaultBackendConfigSetup
+	setup.PostRetryPolicy = PostRetryPolicy{MaxRetries: 3, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxElapsedTime: time.Second}
+	setup.PostSpoolDir = spoolDir
+	return New(setup, nil)
+}
+
+func TestMakePostRequestWithContextRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCodes  []int
+		idempotent   bool
+		wantRequests int32
+		wantStatus   int
+		wantErr      bool
+	}{
+		{name: "idempotent retried until success", statusCodes: []int{503, 429, 200}, idempotent: true, wantRequests: 3, wantStatus: 200},
+		{name: "idempotent gives up after max retries", statusCodes: []int{500}, idempotent: true, wantRequests: 4, wantStatus: 500, wantErr: true},
+		{name: "client errors aren't retried", statusCodes: []int{400, 200}, idempotent: true, wantRequests: 1, wantStatus: 400, wantErr: true},
+		{name: "non idempotent requests aren't retried", statusCodes: []int{503, 200}, idempotent: false, wantRequests: 1, wantStatus: 503, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests, _ := postTestServer(t, tt.statusCodes...)
+			instance := newPostTestInstance("")
+
+			_, statusCode, err := instance.MakePostRequestWithContext(context.Background(), server.URL, "/endpoint", map[string]string{"a": "b"}, PostRequestOptions{Idempotent: tt.idempotent})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			var postErr *PostRequestError
+			if tt.wantErr && !errors.As(err, &postErr) {
+				t.Errorf("err = %T, want *PostRequestError", err)
+			}
+			if statusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", statusCode, tt.wantStatus)
+			}
+			if got := atomic.LoadInt32(requests); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestMakePostRequestWithContextMarshalError(t *testing.T) {
+	instance := newPostTestInstance("")
+	if _, _, err := instance.MakePostRequestWithContext(context.Background(), "http://localhost", "/endpoint", make(chan int), PostRequestOptions{}); err == nil {
+		t.Fatal("expected an error for a payload that can't be marshalled")
+	}
+}
+
+func TestSpooledRequests(t *testing.T) {
+	spoolDir := t.TempDir()
+	instance := newPostTestInstance(spoolDir)
+
+	down, _, _ := postTestServer(t, 503)
+	if _, _, err := instance.MakePostRequestWithContext(context.Background(), down.URL, "/endpoint", map[string]string{"id": "1"}, PostRequestOptions{Spool: true}); err == nil {
+		t.Fatal("expected the request to fail")
+	}
+	rejected, _, _ := postTestServer(t, 400)
+	if _, _, err := instance.MakePostRequestWithContext(context.Background(), rejected.URL, "/endpoint", map[string]string{"id": "2"}, PostRequestOptions{Spool: true}); err == nil {
+		t.Fatal("expected the request to fail")
+	}
+	if files, _ := ioutil.ReadDir(spoolDir); len(files) != 1 {
+		t.Fatalf("spooled %d requests, want only the retryable one", len(files))
+	}
+
+	// still unavailable: the request is kept
+	if err := instance.DeliverSpooledRequests(context.Background()); err == nil {
+		t.Fatal("expected delivery to fail while the server is unavailable")
+	}
+	if files, _ := ioutil.ReadDir(spoolDir); len(files) != 1 {
+		t.Fatalf("%d spooled requests left, want 1", len(files))
+	}
+
+	// repoint the spooled request at a server that is up
+	files, _ := ioutil.ReadDir(spoolDir)
+	up, requests, bodies := postTestServer(t, 200)
+	data, _ := ioutil.ReadFile(filepath.Join(spoolDir, files[0].Name()))
+	data = []byte(strings.Replace(string(data), down.URL, up.URL, 1))
+	if err := ioutil.WriteFile(filepath.Join(spoolDir, files[0].Name()), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.DeliverSpooledRequests(context.Background()); err != nil {
+		t.Fatalf("DeliverSpooledRequests: %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Fatalf("delivered %d requests, want 1", got)
+	}
+	if body := <-bodies; string(body) != `{"id":"1"}` {
+		t.Errorf("delivered %s, want the spooled payload", body)
+	}
+	if files, _ := ioutil.ReadDir(spoolDir); len(files) != 0 {
+		t.Errorf("%d spooled requests left after delivery", len(files))
+	}
+}
+
+func TestSpooledRequestRejected(t *testing.T) {
+	spoolDir := t.TempDir()
+	instance := newPostTestInstance(spoolDir)
+	rejected, _, _ := postTestServer(t, 422)
+	if err := instance.spoolRequest(rejected.URL, "/endpoint", []byte(`{}`), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(spoolDir, "0-garbage.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.DeliverSpooledRequests(context.Background()); err != nil {
+		t.Fatalf("DeliverSpooledRequests: %v", err)
+	}
+	if files, _ := ioutil.ReadDir(spoolDir); len(files) != 0 {
+		t.Errorf("%d spooled requests left, rejected and unparsable requests must be dropped", len(files))
+	}
+}
+
+func TestSpoolRequestWithoutSpoolDir(t *testing.T) {
+	if err := newPostTestInstance("").spoolRequest("http://localhost", "/endpoint", []byte(`{}`), false); err == nil {
+		t.Fatal("expected an error without PostSpoolDir")
+	}
+}
+
+func TestPostSettingsDefaulted(t *testing.T) {
+	setup := DefaultBackendConfigSetup
+	setup.PostRetryPolicy = PostRetryPolicy{}
+	setup.PostSpoolRetryInterval = 0
+	instance := New(setup, nil)
+	if instance.setup.PostRetryPolicy != DefaultPostRetryPolicy {
+		t.Errorf("got retry policy %+v, want %+v", instance.setup.PostRetryPolicy, DefaultPostRetryPolicy)
+	}
+	if instance.setup.PostSpoolRetryInterval != DefaultBackendConfigSetup.PostSpoolRetryInterval {
+		t.Errorf("got spool retry interval %v, want %v", instance.setup.PostSpoolRetryInterval, DefaultBackendConfigSetup.PostSpoolRetryInterval)
+	}
+}
@@ -0,0 +1,200 @@
+package backendconfig
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+// configVersionHeader carries the version of the config served by the proxy
+const configVersionHeader = "X-Rudder-Config-Version"
+
+/*
+configProxy serves the config and regulations held by this instance on the same endpoints as the config backend.
+The config is served as it was fetched, without the local overlay of this instance.
+Peer instances can set their ConfigBackendUrl to the proxy address and keep using the regular WorkspaceConfig and MultiWorkspaceConfig providers.
+The proxy keeps serving the last fetched config while the config backend is unavailable.
+*/
+type configProxy struct {
+	instance     *Instance
+	requestsStat stats.RudderStats
+}
+
+// ConfigVersionT is served by the proxy on /version
+type ConfigVersionT struct {
+	Version            string `json:"version"`
+	LastSync           string `json:"lastSync"`
+	LastRegulationSync string `json:"lastRegulationSync"`
+}
+
+/*
+startConfigProxy serves the config of the instance on address until the instance is stopped. The config holds secrets,
+so the proxy refuses to start without the workspace token, or the multi workspace secret, that peers must authenticate with.
+*/
+func (instance *Instance) startConfigProxy(address string) {
+	proxy := &configProxy{instance: instance, requestsStat: stats.NewStat("config_backend.proxy_requests", stats.CountType)}
+	if proxy.secret() == "" {
+		pkgLogger.Errorf("Not starting config proxy on %s: no workspace token or multi workspace secret to authenticate peers with", address)
+		return
+	}
+
+	server := &http.Server{Addr: address, Handler: proxy.handler()}
+	go func() {
+		<-instance.stopCh
+		server.Close()
+	}()
+
+	pkgLogger.Infof("Starting config proxy on %s", address)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		pkgLogger.Errorf("Config proxy stopped with error: %s", err.Error())
+	}
+}
+
+// handler routes the endpoints of the config backend served by the proxy
+func (proxy *configProxy) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version", proxy.authenticated(proxy.versionHandler))
+	mux.HandleFunc("/workspaceConfig", proxy.authenticated(proxy.workspaceConfigHandler))
+	mux.HandleFunc("/workspaces/regulations", proxy.authenticated(proxy.workspaceRegulationsHandler))
+	mux.HandleFunc("/workspaces/sources/regulations", proxy.authenticated(proxy.sourceRegulationsHandler))
+	mux.HandleFunc("/hostedWorkspaceConfig", proxy.authenticated(proxy.hostedWorkspaceConfigHandler))
+	mux.HandleFunc("/hostedWorkspaces", proxy.authenticated(proxy.hostedWorkspacesHandler))
+	mux.HandleFunc("/hostedWorkspaceRegulations", proxy.authenticated(proxy.workspaceRegulationsHandler))
+	mux.HandleFunc("/hostedSourceRegulations", proxy.authenticated(proxy.sourceRegulationsHandler))
+	return mux
+}
+
+// authenticated checks the basic auth user against the credentials this instance uses with the config backend, and rejects requests until config is available
+func (proxy *configProxy) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proxy.requestsStat.Increment()
+
+		secret := proxy.secret()
+		if user, _, _ := r.BasicAuth(); secret == "" || subtle.ConstantTimeCompare([]byte(user), []byte(secret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !proxy.instance.isInitialized() {
+			http.Error(w, "config not yet available", http.StatusServiceUnavailable)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// secret is the basic auth user peers authenticate with, the one this instance uses with the config backend
+func (proxy *configProxy) secret() string {
+	if proxy.instance.setup.IsMultiWorkspace {
+		return proxy.instance.setup.MultiWorkspaceSecret
+	}
+	return proxy.instance.setup.WorkSpaceToken
+}
+
+func (proxy *configProxy) writeJSON(w http.ResponseWriter, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		pkgLogger.Errorf("Config proxy failed to marshal response: %s", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(configVersionHeader, proxy.instance.getFetchedConfig().Version)
+	if signature, ok := proxy.instance.verifier.sign(body); ok {
+		w.Header().Set(configSignatureHeader, signature)
+	}
+	w.Write(body)
+}
+
+func (proxy *configProxy) versionHandler(w http.ResponseWriter, r *http.Request) {
+	version := ConfigVersionT{Version: proxy.instance.getFetchedConfig().Version}
+	version.LastSync, version.LastRegulationSync = proxy.instance.GetLastSync()
+
+	proxy.writeJSON(w, version)
+}
+
+func (proxy *configProxy) workspaceConfigHandler(w http.ResponseWriter, r *http.Request) {
+	proxy.writeJSON(w, proxy.instance.getFetchedConfig())
+}
+
+// hostedWorkspaceConfigHandler serves the config split per workspace, the way the multi workspace config backend does
+func (proxy *configProxy) hostedWorkspaceConfigHandler(w http.ResponseWriter, r *http.Request) {
+	workspaceConfigs := make(map[string]ConfigT)
+	for _, source := range proxy.instance.getFetchedConfig().Sources {
+		workspaceConfig, ok := workspaceConfigs[source.WorkspaceID]
+		if !ok {
+			workspaceConfig = ConfigT{WorkspaceID: source.WorkspaceID, Libraries: proxy.instance.GetWorkspaceLibrariesForWorkspaceID(source.WorkspaceID), Settings: proxy.instance.GetWorkspaceSettingsForWorkspaceID(source.WorkspaceID)}
+		}
+		workspaceConfig.Sources = append(workspaceConfig.Sources, source)
+		workspaceConfigs[source.WorkspaceID] = workspaceConfig
+	}
+
+	proxy.writeJSON(w, workspaceConfigs)
+}
+
+func (proxy *configProxy) hostedWorkspacesHandler(w http.ResponseWriter, r *http.Request) {
+	workspaceIDs := make(map[string]bool)
+	for _, source := range proxy.instance.getFetchedConfig().Sources {
+		workspaceIDs[source.WorkspaceID] = true
+	}
+
+	curRegulationJSON := proxy.instance.GetCurrentRegulations()
+	for _, regulation := range curRegulationJSON.WorkspaceRegulations {
+		workspaceIDs[regulation.WorkspaceID] = true
+	}
+	for _, regulation := range curRegulationJSON.SourceRegulations {
+		workspaceIDs[regulation.WorkspaceID] = true
+	}
+
+	hostedWorkspaces := HostedWorkspacesT{HostedWorkspaces: make([]WorkspaceT, 0, len(workspaceIDs))}
+	for workspaceID := range workspaceIDs {
+		hostedWorkspaces.HostedWorkspaces = append(hostedWorkspaces.HostedWorkspaces, WorkspaceT{WorkspaceID: workspaceID})
+	}
+	sort.Slice(hostedWorkspaces.HostedWorkspaces, func(i, j int) bool {
+		return hostedWorkspaces.HostedWorkspaces[i].WorkspaceID < hostedWorkspaces.HostedWorkspaces[j].WorkspaceID
+	})
+
+	proxy.writeJSON(w, hostedWorkspaces)
+}
+
+// workspaceRegulationsHandler serves a page of workspace regulations, optionally filtered by the workspaceId query parameter
+func (proxy *configProxy) workspaceRegulationsHandler(w http.ResponseWriter, r *http.Request) {
+	start, limit := proxy.pageParams(r)
+	page, ok := proxy.instance.workspaceRegulationsPage(r.URL.Query().Get("workspaceId"), start, limit)
+	if !ok {
+		http.Error(w, "regulations not yet available", http.StatusServiceUnavailable)
+		return
+	}
+
+	proxy.writeJSON(w, page)
+}
+
+// sourceRegulationsHandler serves a page of source regulations, optionally filtered by the workspaceId query parameter
+func (proxy *configProxy) sourceRegulationsHandler(w http.ResponseWriter, r *http.Request) {
+	start, limit := proxy.pageParams(r)
+	page, ok := proxy.instance.sourceRegulationsPage(r.URL.Query().Get("workspaceId"), start, limit)
+	if !ok {
+		http.Error(w, "regulations not yet available", http.StatusServiceUnavailable)
+		return
+	}
+
+	proxy.writeJSON(w, page)
+}
+
+func (proxy *configProxy) pageParams(r *http.Request) (start int, limit int) {
+	start, _ = strconv.Atoi(r.URL.Query().Get("start"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = proxy.instance.setup.MaxRegulationsPerRequest
+	}
+	if start < 0 {
+		start = 0
+	}
+	return start, limit
+}
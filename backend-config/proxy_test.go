@@ -0,0 +1,143 @@
+package backendconfig
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+func newTestProxy(instance *Instance) *httptest.Server {
+	return httptest.NewServer((&configProxy{instance: instance, requestsStat: stats.NewStat("config_backend.proxy_requests", stats.CountType)}).handler())
+}
+
+func proxyGet(t *testing.T, url string, user string) (*http.Response, []byte) {
+	request, _ := http.NewRequest("GET", url, nil)
+	request.SetBasicAuth(user, "")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	return response, body
+}
+
+func TestConfigProxyAuthentication(t *testing.T) {
+	backend := newTestConfigBackend(t, testSourcesConfig("d1"))
+	instance := newTestInstance(t, backend, nil)
+	proxy := newTestProxy(instance)
+	defer proxy.Close()
+
+	if response, _ := proxyGet(t, proxy.URL+"/workspaceConfig", testWorkspaceToken); response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status before the first config = %d, want 503", response.StatusCode)
+	}
+	instance.configUpdate(testStatConfigBackendError)
+
+	tests := []struct {
+		user string
+		want int
+	}{
+		{user: testWorkspaceToken, want: http.StatusOK},
+		{user: "", want: http.StatusUnauthorized},
+		{user: "wrong", want: http.StatusUnauthorized},
+		{user: testWorkspaceToken + "x", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if response, _ := proxyGet(t, proxy.URL+"/workspaceConfig", tt.user); response.StatusCode != tt.want {
+			t.Errorf("status for user %q = %d, want %d", tt.user, response.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestConfigProxyRequiresSecret(t *testing.T) {
+	backend := newTestConfigBackend(t, testSourcesConfig("d1"))
+	instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+		setup.WorkSpaceToken = ""
+	})
+	// returns at once instead of serving
+	instance.startConfigProxy("127.0.0.1:0")
+
+	instance.configUpdate(testStatConfigBackendError)
+	proxy := newTestProxy(instance)
+	defer proxy.Close()
+	if response, _ := proxyGet(t, proxy.URL+"/workspaceConfig", ""); response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status without a secret = %d, want 401", response.StatusCode)
+	}
+}
+
+func TestConfigProxyServesFetchedConfig(t *testing.T) {
+	overlayPath := filepath.Join(t.TempDir(), "overlay.json")
+	overlay := `{"destinations": {"d1": {"enabled": false, "config": {"webhookUrl": "http://localhost"}}},
+		"debugDestinations": [{"sourceId": "source-d1", "destination": {"id": "debug", "enabled": true}}]}`
+	if err := ioutil.WriteFile(overlayPath, []byte(overlay), 0600); err != nil {
+		t.Fatal(err)
+	}
+	backend := newTestConfigBackend(t, testSourcesConfig("d1"))
+	instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+		setup.ConfigOverlayPath = overlayPath
+	})
+	if changed, ok := instance.configUpdate(testStatConfigBackendError); !changed || !ok {
+		t.Fatalf("configUpdate = %t, %t", changed, ok)
+	}
+	if destinations := instance.GetConfig().Sources[0].Destinations; len(destinations) != 2 || destinations[0].Enabled {
+		t.Fatalf("overlay isn't applied to the local config: %+v", destinations)
+	}
+
+	proxy := newTestProxy(instance)
+	defer proxy.Close()
+	response, body := proxyGet(t, proxy.URL+"/workspaceConfig", testWorkspaceToken)
+	var served ConfigT
+	if err := json.Unmarshal(body, &served); err != nil {
+		t.Fatal(err)
+	}
+	destinations := served.Sources[0].Destinations
+	if len(destinations) != 1 || !destinations[0].Enabled || destinations[0].Config["webhookUrl"] != "https://example.com/d1" {
+		t.Errorf("proxy served overlaid destinations %+v, want the fetched one", destinations)
+	}
+	if version, fetchedVersion := response.Header.Get(configVersionHeader), instance.getFetchedConfig().Version; version == "" || version == instance.GetConfigVersion() || version != fetchedVersion {
+		t.Errorf("version header %q, want the version of the fetched config %q", version, fetchedVersion)
+	}
+}
+
+func TestConfigProxyServesRegulations(t *testing.T) {
+	backend := newTestConfigBackend(t, testSourcesConfig("d1"))
+	backend.setRegulations(RegulationsT{
+		WorkspaceRegulations: []WorkspaceRegulationT{
+			{ID: "1", RegulationType: RegulationSuppress, WorkspaceID: "workspace-1", UserID: "user-1"},
+			{ID: "2", RegulationType: RegulationSuppress, WorkspaceID: "workspace-2", UserID: "user-2"},
+			{ID: "3", RegulationType: RegulationSuppress, WorkspaceID: "workspace-1", UserID: "user-3"},
+		},
+		SourceRegulations: []SourceRegulationT{},
+	})
+	instance := newTestInstance(t, backend, nil)
+	instance.configUpdate(testStatConfigBackendError)
+	proxy := newTestProxy(instance)
+	defer proxy.Close()
+
+	// regulations that were never loaded aren't served as an empty last page
+	for _, path := range []string{"/workspaces/regulations", "/workspaces/sources/regulations"} {
+		if response, _ := proxyGet(t, proxy.URL+path, testWorkspaceToken); response.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("%s status before the first regulations = %d, want 503", path, response.StatusCode)
+		}
+	}
+	if changed, ok := instance.regulationsUpdate(testStatConfigBackendError); !changed || !ok {
+		t.Fatalf("regulationsUpdate = %t, %t", changed, ok)
+	}
+
+	response, body := proxyGet(t, proxy.URL+"/workspaces/regulations?workspaceId=workspace-1&start=1&limit=1", testWorkspaceToken)
+	var page WRegulationsT
+	if err := json.Unmarshal(body, &page); err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, %v", response.StatusCode, err)
+	}
+	if len(page.WorkspaceRegulations) != 1 || page.WorkspaceRegulations[0].ID != "3" || !page.End || page.Next != 2 {
+		t.Errorf("got page %+v, want the second regulation of workspace-1 as the last one", page)
+	}
+	if response, _ := proxyGet(t, proxy.URL+"/workspaces/sources/regulations", testWorkspaceToken); response.StatusCode != http.StatusOK {
+		t.Errorf("status of empty source regulations = %d, want 200", response.StatusCode)
+	}
+}
@@ -0,0 +1,392 @@
+/*
+Package query serves a read-only HTTP/JSON view of the config loaded by backendconfig.
+It lets ops tooling and dashboards list the sources, destinations, definitions, regulations and libraries a running server actually has.
+Secrets are redacted with the Redactor configured for backendconfig. User IDs of regulations are left out unless the handler is
+created to expose them.
+*/
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	backendconfig "github.com/rudderlabs/rudder-platform/backend-config"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// StateProvider gives the handler access to the loaded config and regulations
+type StateProvider interface {
+	Config() backendconfig.ConfigT
+	Regulations() backendconfig.RegulationsT
+	WorkspaceLibraries(workspaceID string) backendconfig.LibrariesT
+}
+
+// defaultStateProvider reads the state of the backendconfig package
+type defaultStateProvider struct{}
+
+func (defaultStateProvider) Config() backendconfig.ConfigT {
+	return backendconfig.GetConfig()
+}
+
+func (defaultStateProvider) Regulations() backendconfig.RegulationsT {
+	return backendconfig.GetCurrentRegulations()
+}
+
+func (defaultStateProvider) WorkspaceLibraries(workspaceID string) backendconfig.LibrariesT {
+	return backendconfig.GetWorkspaceLibrariesForWorkspaceID(workspaceID)
+}
+
+// Handler serves the query API. Mount it under a prefix with http.StripPrefix.
+type Handler struct {
+	state StateProvider
+	// redactor is resolved on every request, so that a redactor replaced by backendconfig.Setup is used
+	redactor      func() *backendconfig.Redactor
+	exposeUserIDs bool
+	mux           *http.ServeMux
+}
+
+// PageT is the response of every list endpoint
+type PageT struct {
+	Total  int         `json:"total"`
+	Offset int         `json:"offset"`
+	Limit  int         `json:"limit"`
+	Items  interface{} `json:"items"`
+}
+
+// SourceT is a source as reported by the query API
+type SourceT struct {
+	ID             string                 `json:"id"`
+	Name           string                 `json:"name"`
+	WorkspaceID    string                 `json:"workspaceId"`
+	Type           string                 `json:"type"`
+	Enabled        bool                   `json:"enabled"`
+	WriteKey       string                 `json:"writeKey"`
+	Config         map[string]interface{} `json:"config,omitempty"`
+	ConfigError    string                 `json:"configError,omitempty"`
+	DestinationIDs []string               `json:"destinationIds"`
+}
+
+// DestinationT is a destination as reported by the query API
+type DestinationT struct {
+	ID               string                          `json:"id"`
+	Name             string                          `json:"name"`
+	SourceID         string                          `json:"sourceId"`
+	WorkspaceID      string                          `json:"workspaceId"`
+	Type             string                          `json:"type"`
+	Enabled          bool                            `json:"enabled"`
+	ProcessorEnabled bool                            `json:"processorEnabled"`
+	Config           map[string]interface{}          `json:"config,omitempty"`
+	ConfigError      string                          `json:"configError,omitempty"`
+	Transformations  []backendconfig.TransformationT `json:"transformations"`
+}
+
+// DefinitionT is a source or destination definition as reported by the query API
+type DefinitionT struct {
+	Kind        string `json:"kind"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName,omitempty"`
+	Category    string `json:"category,omitempty"`
+}
+
+// RegulationT is a workspace or source regulation as reported by the query API. UserID is only set by handlers exposing user IDs.
+type RegulationT struct {
+	Kind           string `json:"kind"`
+	ID             string `json:"id"`
+	RegulationType string `json:"regulationType"`
+	WorkspaceID    string `json:"workspaceId"`
+	SourceID       string `json:"sourceId,omitempty"`
+	UserID         string `json:"userId,omitempty"`
+}
+
+// LibraryT is a transformation library version as reported by the query API
+type LibraryT struct {
+	WorkspaceID string `json:"workspaceId"`
+	VersionID   string `json:"versionId"`
+}
+
+// NewHandler returns a query handler reading from the backendconfig package. Regulations include user IDs only if exposeUserIDs is set.
+func NewHandler(exposeUserIDs bool) *Handler {
+	return newHandler(defaultStateProvider{}, backendconfig.GetSecretRedactor, exposeUserIDs)
+}
+
+// NewHandlerWithState returns a query handler reading from state and masking secrets with redactor
+func NewHandlerWithState(state StateProvider, redactor *backendconfig.Redactor, exposeUserIDs bool) *Handler {
+	return newHandler(state, func() *backendconfig.Redactor { return redactor }, exposeUserIDs)
+}
+
+func newHandler(state StateProvider, redactor func() *backendconfig.Redactor, exposeUserIDs bool) *Handler {
+	handler := &Handler{state: state, redactor: redactor, exposeUserIDs: exposeUserIDs, mux: http.NewServeMux()}
+	handler.mux.HandleFunc("/sources", handler.getOnly(handler.sources))
+	handler.mux.HandleFunc("/destinations", handler.getOnly(handler.destinations))
+	handler.mux.HandleFunc("/definitions", handler.getOnly(handler.definitions))
+	handler.mux.HandleFunc("/regulations", handler.getOnly(handler.regulations))
+	handler.mux.HandleFunc("/libraries", handler.getOnly(handler.libraries))
+	return handler
+}
+
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler.mux.ServeHTTP(w, r)
+}
+
+// currentRedactor returns the redactor to mask secrets of a request with, masking everything if there is none
+func (handler *Handler) currentRedactor() *backendconfig.Redactor {
+	if redactor := handler.redactor(); redactor != nil {
+		return redactor
+	}
+	return backendconfig.NewRedactor(backendconfig.RedactFull, true)
+}
+
+func (handler *Handler) getOnly(handle http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handle(w, r)
+	}
+}
+
+// filters are the query parameters shared by the list endpoints. Unset filters match everything.
+type filters struct {
+	workspaceID     string
+	destinationType string
+	enabled         *bool
+}
+
+func parseFilters(r *http.Request) (filters, error) {
+	query := r.URL.Query()
+	f := filters{workspaceID: query.Get("workspaceId"), destinationType: query.Get("destinationType")}
+	if enabled := query.Get("enabled"); enabled != "" {
+		value, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return f, err
+		}
+		f.enabled = &value
+	}
+	return f, nil
+}
+
+func (f filters) matchWorkspace(workspaceID string) bool {
+	return f.workspaceID == "" || f.workspaceID == workspaceID
+}
+
+func (f filters) matchEnabled(enabled bool) bool {
+	return f.enabled == nil || *f.enabled == enabled
+}
+
+func (f filters) matchDestinationType(definition backendconfig.DestinationDefinitionT) bool {
+	return f.destinationType == "" || strings.EqualFold(f.destinationType, definition.Name) || strings.EqualFold(f.destinationType, definition.DisplayName)
+}
+
+func (handler *Handler) sources(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilters(r)
+	if err != nil {
+		http.Error(w, "invalid enabled filter", http.StatusBadRequest)
+		return
+	}
+
+	redactor := handler.currentRedactor()
+	items := make([]SourceT, 0)
+	for _, source := range handler.state.Config().Sources {
+		if !f.matchWorkspace(source.WorkspaceID) || !f.matchEnabled(source.Enabled) {
+			continue
+		}
+		item := SourceT{
+			ID:             source.ID,
+			Name:           source.Name,
+			WorkspaceID:    source.WorkspaceID,
+			Type:           source.SourceDefinition.Name,
+			Enabled:        source.Enabled,
+			WriteKey:       redactor.RedactWriteKey(source.WriteKey),
+			DestinationIDs: make([]string, 0, len(source.Destinations)),
+		}
+		if item.Config, err = redactor.RedactSourceConfig(source); err != nil {
+			item.ConfigError = err.Error()
+		}
+		for _, destination := range source.Destinations {
+			item.DestinationIDs = append(item.DestinationIDs, destination.ID)
+		}
+		items = append(items, item)
+	}
+
+	offset, limit, from, to := paginate(r, len(items))
+	writeJSON(w, PageT{Total: len(items), Offset: offset, Limit: limit, Items: items[from:to]})
+}
+
+func (handler *Handler) destinations(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilters(r)
+	if err != nil {
+		http.Error(w, "invalid enabled filter", http.StatusBadRequest)
+		return
+	}
+
+	redactor := handler.currentRedactor()
+	items := make([]DestinationT, 0)
+	for _, source := range handler.state.Config().Sources {
+		if !f.matchWorkspace(source.WorkspaceID) {
+			continue
+		}
+		for _, destination := range source.Destinations {
+			if !f.matchEnabled(destination.Enabled) || !f.matchDestinationType(destination.DestinationDefinition) {
+				continue
+			}
+			item := DestinationT{
+				ID:               destination.ID,
+				Name:             destination.Name,
+				SourceID:         source.ID,
+				WorkspaceID:      source.WorkspaceID,
+				Type:             destination.DestinationDefinition.Name,
+				Enabled:          destination.Enabled,
+				ProcessorEnabled: destination.IsProcessorEnabled,
+				Transformations:  destination.Transformations,
+			}
+			if item.Config, err = redactor.RedactDestinationConfig(destination); err != nil {
+				item.ConfigError = err.Error()
+			}
+			items = append(items, item)
+		}
+	}
+
+	offset, limit, from, to := paginate(r, len(items))
+	writeJSON(w, PageT{Total: len(items), Offset: offset, Limit: limit, Items: items[from:to]})
+}
+
+// definitions lists the distinct source and destination definitions in use, by enabled sources and destinations only if enabled is true
+func (handler *Handler) definitions(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilters(r)
+	if err != nil {
+		http.Error(w, "invalid enabled filter", http.StatusBadRequest)
+		return
+	}
+
+	seen := make(map[string]bool)
+	items := make([]DefinitionT, 0)
+	for _, source := range handler.state.Config().Sources {
+		if !f.matchWorkspace(source.WorkspaceID) {
+			continue
+		}
+		if key := "source/" + source.SourceDefinition.ID; !seen[key] && f.destinationType == "" && f.matchEnabled(source.Enabled) {
+			seen[key] = true
+			items = append(items, DefinitionT{Kind: "source", ID: source.SourceDefinition.ID, Name: source.SourceDefinition.Name, Category: source.SourceDefinition.Category})
+		}
+		for _, destination := range source.Destinations {
+			definition := destination.DestinationDefinition
+			if key := "destination/" + definition.ID; !seen[key] && f.matchDestinationType(definition) && f.matchEnabled(destination.Enabled) {
+				seen[key] = true
+				items = append(items, DefinitionT{Kind: "destination", ID: definition.ID, Name: definition.Name, DisplayName: definition.DisplayName})
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Kind != items[j].Kind {
+			return items[i].Kind > items[j].Kind
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	offset, limit, from, to := paginate(r, len(items))
+	writeJSON(w, PageT{Total: len(items), Offset: offset, Limit: limit, Items: items[from:to]})
+}
+
+func (handler *Handler) regulations(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilters(r)
+	if err != nil {
+		http.Error(w, "invalid enabled filter", http.StatusBadRequest)
+		return
+	}
+
+	regulations := handler.state.Regulations()
+	items := make([]RegulationT, 0)
+	for _, regulation := range regulations.WorkspaceRegulations {
+		if f.matchWorkspace(regulation.WorkspaceID) {
+			items = append(items, RegulationT{Kind: "workspace", ID: regulation.ID, RegulationType: string(regulation.RegulationType), WorkspaceID: regulation.WorkspaceID, UserID: handler.userID(regulation.UserID)})
+		}
+	}
+	for _, regulation := range regulations.SourceRegulations {
+		if f.matchWorkspace(regulation.WorkspaceID) {
+			items = append(items, RegulationT{Kind: "source", ID: regulation.ID, RegulationType: string(regulation.RegulationType), WorkspaceID: regulation.WorkspaceID, SourceID: regulation.SourceID, UserID: handler.userID(regulation.UserID)})
+		}
+	}
+
+	offset, limit, from, to := paginate(r, len(items))
+	writeJSON(w, PageT{Total: len(items), Offset: offset, Limit: limit, Items: items[from:to]})
+}
+
+// userID returns the user ID of a regulation if the handler exposes them, empty otherwise
+func (handler *Handler) userID(userID string) string {
+	if !handler.exposeUserIDs {
+		return ""
+	}
+	return userID
+}
+
+func (handler *Handler) libraries(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilters(r)
+	if err != nil {
+		http.Error(w, "invalid enabled filter", http.StatusBadRequest)
+		return
+	}
+
+	workspaceIDs := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, source := range handler.state.Config().Sources {
+		if !seen[source.WorkspaceID] && f.matchWorkspace(source.WorkspaceID) {
+			seen[source.WorkspaceID] = true
+			workspaceIDs = append(workspaceIDs, source.WorkspaceID)
+		}
+	}
+	sort.Strings(workspaceIDs)
+
+	items := make([]LibraryT, 0)
+	for _, workspaceID := range workspaceIDs {
+		for _, library := range handler.state.WorkspaceLibraries(workspaceID) {
+			items = append(items, LibraryT{WorkspaceID: workspaceID, VersionID: library.VersionID})
+		}
+	}
+
+	offset, limit, from, to := paginate(r, len(items))
+	writeJSON(w, PageT{Total: len(items), Offset: offset, Limit: limit, Items: items[from:to]})
+}
+
+// paginate reads the offset and limit query parameters and returns the bounds of the page within total items
+func paginate(r *http.Request, total int) (offset int, limit int, from int, to int) {
+	offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	from = offset
+	if from > total {
+		from = total
+	}
+	to = from + limit
+	if to > total {
+		to = total
+	}
+	return offset, limit, from, to
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,157 @@
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	backendconfig "github.com/rudderlabs/rudder-platform/backend-config"
+)
+
+type testStateProvider struct {
+	config      backendconfig.ConfigT
+	regulations backendconfig.RegulationsT
+}
+
+func (state testStateProvider) Config() backendconfig.ConfigT {
+	return state.config
+}
+
+func (state testStateProvider) Regulations() backendconfig.RegulationsT {
+	return state.regulations
+}
+
+func (state testStateProvider) WorkspaceLibraries(workspaceID string) backendconfig.LibrariesT {
+	return backendconfig.LibrariesT{}
+}
+
+func newTestState() testStateProvider {
+	secretDefinitionConfig := map[string]interface{}{"secretKeys": []interface{}{"apiKey"}}
+	return testStateProvider{
+		config: backendconfig.ConfigT{Sources: []backendconfig.SourceT{
+			{
+				ID:               "source-1",
+				WorkspaceID:      "workspace-1",
+				WriteKey:         "write-key-source-1",
+				Enabled:          true,
+				SourceDefinition: backendconfig.SourceDefinitionT{ID: "http", Name: "HTTP"},
+				Destinations: []backendconfig.DestinationT{
+					{
+						ID:                    "destination-1",
+						Enabled:               true,
+						Config:                map[string]interface{}{"apiKey": "abcdefghijkl"},
+						DestinationDefinition: backendconfig.DestinationDefinitionT{ID: "webhook", Name: "WEBHOOK", Config: secretDefinitionConfig},
+					},
+					{
+						ID:                    "destination-2",
+						Enabled:               false,
+						DestinationDefinition: backendconfig.DestinationDefinitionT{ID: "s3", Name: "S3"},
+					},
+				},
+			},
+			{
+				ID:               "source-2",
+				WorkspaceID:      "workspace-1",
+				Enabled:          false,
+				SourceDefinition: backendconfig.SourceDefinitionT{ID: "android", Name: "Android"},
+			},
+		}},
+		regulations: backendconfig.RegulationsT{
+			WorkspaceRegulations: []backendconfig.WorkspaceRegulationT{{ID: "regulation-1", RegulationType: "suppress", WorkspaceID: "workspace-1", UserID: "user-1"}},
+			SourceRegulations:    []backendconfig.SourceRegulationT{{ID: "regulation-2", RegulationType: "suppress", WorkspaceID: "workspace-1", SourceID: "source-1", UserID: "user-2"}},
+		},
+	}
+}
+
+// query serves path with handler and decodes the items of the returned page into items
+func query(t *testing.T, handler http.Handler, path string, items interface{}) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("%s returned %d: %s", path, recorder.Code, recorder.Body.String())
+	}
+	page := PageT{Items: items}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefinitions(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{name: "all", path: "/definitions", want: []string{"source/Android", "source/HTTP", "destination/S3", "destination/WEBHOOK"}},
+		{name: "enabled", path: "/definitions?enabled=true", want: []string{"source/HTTP", "destination/WEBHOOK"}},
+		{name: "disabled", path: "/definitions?enabled=false", want: []string{"source/Android", "destination/S3"}},
+		{name: "destination type", path: "/definitions?destinationType=s3", want: []string{"destination/S3"}},
+		{name: "enabled destination type", path: "/definitions?destinationType=s3&enabled=true", want: []string{}},
+	}
+	handler := NewHandlerWithState(newTestState(), nil, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var definitions []DefinitionT
+			query(t, handler, tt.path, &definitions)
+			got := make([]string, 0, len(definitions))
+			for _, definition := range definitions {
+				got = append(got, definition.Kind+"/"+definition.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegulationUserIDs(t *testing.T) {
+	tests := []struct {
+		name          string
+		exposeUserIDs bool
+		want          []string
+	}{
+		{name: "hidden", exposeUserIDs: false, want: []string{"", ""}},
+		{name: "exposed", exposeUserIDs: true, want: []string{"user-1", "user-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var regulations []RegulationT
+			query(t, NewHandlerWithState(newTestState(), nil, tt.exposeUserIDs), "/regulations", &regulations)
+			got := make([]string, 0, len(regulations))
+			for _, regulation := range regulations {
+				got = append(got, regulation.UserID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got user IDs %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorResolvedPerRequest(t *testing.T) {
+	redactor := backendconfig.NewRedactor(backendconfig.RedactFull, false)
+	handler := newHandler(newTestState(), func() *backendconfig.Redactor { return redactor }, false)
+
+	var destinations []DestinationT
+	query(t, handler, "/destinations?enabled=true", &destinations)
+	if got := destinations[0].Config["apiKey"]; got != "[redacted]" {
+		t.Errorf("got apiKey %v with the full policy", got)
+	}
+
+	redactor = backendconfig.NewRedactor(backendconfig.RedactPartial, false)
+	query(t, handler, "/destinations?enabled=true", &destinations)
+	if got := destinations[0].Config["apiKey"]; got != "abcdxxxxxxxx" {
+		t.Errorf("got apiKey %v after switching to the partial policy", got)
+	}
+}
+
+func TestMissingRedactorMasksEverything(t *testing.T) {
+	var sources []SourceT
+	query(t, NewHandlerWithState(newTestState(), nil, false), "/sources?enabled=true", &sources)
+	if len(sources) != 1 || sources[0].WriteKey != "[redacted]" {
+		t.Errorf("write key not masked without a redactor: %+v", sources)
+	}
+}
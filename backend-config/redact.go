@@ -0,0 +1,239 @@
+package backendconfig
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RedactionPolicy decides how secret config values are replaced before config is reported
+type RedactionPolicy string
+
+const (
+	// RedactFull replaces secrets with a fixed placeholder
+	RedactFull RedactionPolicy = "full"
+	// RedactPartial keeps the first third of a secret and replaces the rest with 'x's. Secrets shorter than minPartialSecretLength are fully masked.
+	RedactPartial RedactionPolicy = "partial"
+	// RedactFingerprint replaces secrets with a short HMAC-SHA256 fingerprint, so that changes can be spotted within a process without revealing them
+	RedactFingerprint RedactionPolicy = "fingerprint"
+
+	redactedPlaceholder = "[redacted]"
+	secretKeysConfigKey = "secretKeys"
+
+	// minPartialSecretLength is the length in characters below which a third of a secret reveals too much of it
+	minPartialSecretLength = 12
+)
+
+// fingerprintKey keys the fingerprints of RedactFingerprint. It is random per process, so fingerprints of low entropy secrets can't be brute forced offline.
+var fingerprintKey = newFingerprintKey()
+
+func newFingerprintKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Errorf("unable to generate secret fingerprint key: %w", err))
+	}
+	return key
+}
+
+/*
+Redactor masks secrets in source and destination configs.
+Secrets are declared by the secretKeys array of the source or destination definition config. Each secret key is a path:
+  - "apiKey" masks a top level field
+  - "auth.token" masks a nested field
+  - "headers.*" or "headers[*]" masks every value of a map or array
+  - "mappings[0].secret" masks a field of a single array element
+
+A path ending at a map or array masks every value below it. Numbers and booleans are masked like strings.
+*/
+type Redactor struct {
+	Policy        RedactionPolicy
+	MaskWriteKeys bool
+}
+
+// NewRedactor returns a Redactor for policy, falling back to RedactPartial for unknown policies
+func NewRedactor(policy RedactionPolicy, maskWriteKeys bool) *Redactor {
+	switch policy {
+	case RedactFull, RedactPartial, RedactFingerprint:
+	default:
+		if policy != "" {
+			pkgLogger.Errorf("Unknown secret redaction policy %q, using %q", policy, RedactPartial)
+		}
+		policy = RedactPartial
+	}
+	return &Redactor{Policy: policy, MaskWriteKeys: maskWriteKeys}
+}
+
+// RedactDestinationConfig returns a copy of the destination config with the secrets declared by its definition masked
+func (redactor *Redactor) RedactDestinationConfig(destination DestinationT) (map[string]interface{}, error) {
+	secretKeys, err := parseSecretKeys(destination.DestinationDefinition.Config)
+	if err != nil {
+		return nil, fmt.Errorf("%s of destination definition config is invalid. Destination definition name: %s", err.Error(), destination.DestinationDefinition.DisplayName)
+	}
+	return redactor.RedactConfig(destination.Config, secretKeys), nil
+}
+
+// RedactSourceConfig returns a copy of the source config with the secrets declared by its definition masked
+func (redactor *Redactor) RedactSourceConfig(source SourceT) (map[string]interface{}, error) {
+	secretKeys, err := parseSecretKeys(source.SourceDefinition.Config)
+	if err != nil {
+		return nil, fmt.Errorf("%s of source definition config is invalid. Source definition name: %s", err.Error(), source.SourceDefinition.Name)
+	}
+	return redactor.RedactConfig(source.Config, secretKeys), nil
+}
+
+// RedactWriteKey masks writeKey if write key masking is enabled
+func (redactor *Redactor) RedactWriteKey(writeKey string) string {
+	if !redactor.MaskWriteKeys || writeKey == "" {
+		return writeKey
+	}
+	return redactor.redactString(writeKey)
+}
+
+// RedactConfig returns a deep copy of config with the values at secretKeys paths masked
+func (redactor *Redactor) RedactConfig(config map[string]interface{}, secretKeys []string) map[string]interface{} {
+	configCopy, _ := copyConfigValue(config).(map[string]interface{})
+	if configCopy == nil {
+		configCopy = make(map[string]interface{})
+	}
+	for _, secretKey := range secretKeys {
+		// keys declared before paths were supported may contain path characters themselves
+		if value, ok := configCopy[secretKey]; ok {
+			configCopy[secretKey] = redactor.redactValue(value)
+			continue
+		}
+		redactor.redactPath(configCopy, splitSecretKeyPath(secretKey))
+	}
+	return configCopy
+}
+
+// redactPath masks the values matching path below value, which must be a copy owned by the redactor
+func (redactor *Redactor) redactPath(value interface{}, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	segment, rest := path[0], path[1:]
+
+	switch container := value.(type) {
+	case map[string]interface{}:
+		for key, child := range container {
+			if segment != "*" && segment != key {
+				continue
+			}
+			if len(rest) == 0 {
+				container[key] = redactor.redactValue(child)
+			} else {
+				redactor.redactPath(child, rest)
+			}
+		}
+	case []interface{}:
+		for i, child := range container {
+			if segment != "*" && segment != strconv.Itoa(i) {
+				continue
+			}
+			if len(rest) == 0 {
+				container[i] = redactor.redactValue(child)
+			} else {
+				redactor.redactPath(child, rest)
+			}
+		}
+	}
+}
+
+// redactValue masks a secret value, masking every value below it if it is a map or array
+func (redactor *Redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = redactor.redactValue(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactor.redactValue(child)
+		}
+		return v
+	case string:
+		return redactor.redactString(v)
+	default:
+		return redactor.redactString(fmt.Sprint(v))
+	}
+}
+
+func (redactor *Redactor) redactString(s string) string {
+	switch redactor.Policy {
+	case RedactFull:
+		return redactedPlaceholder
+	case RedactFingerprint:
+		mac := hmac.New(sha256.New, fingerprintKey)
+		mac.Write([]byte(s))
+		return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))[:12]
+	default:
+		runes := []rune(s)
+		if len(runes) < minPartialSecretLength {
+			return redactedPlaceholder
+		}
+		//Mask by replacing latter 2/3rd of the field with 'x's
+		mask := strings.Repeat("x", (len(runes)*2)/3)
+		return string(runes[:len(runes)-(len(runes)*2)/3]) + mask
+	}
+}
+
+// parseSecretKeys reads the secretKeys array of a source or destination definition config
+func parseSecretKeys(definitionConfig map[string]interface{}) ([]string, error) {
+	declared, ok := definitionConfig[secretKeysConfigKey]
+	if !ok || declared == nil {
+		return []string{}, nil
+	}
+	declaredKeys, ok := declared.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s field is not an array", secretKeysConfigKey)
+	}
+
+	secretKeys := make([]string, 0, len(declaredKeys))
+	for _, declaredKey := range declaredKeys {
+		secretKey, ok := declaredKey.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s field contains %v of type %T instead of a string", secretKeysConfigKey, declaredKey, declaredKey)
+		}
+		secretKeys = append(secretKeys, secretKey)
+	}
+	return secretKeys, nil
+}
+
+// splitSecretKeyPath splits "a.b[*].c" into ["a", "b", "*", "c"]
+func splitSecretKeyPath(secretKey string) []string {
+	normalized := strings.NewReplacer("[", ".", "]", "").Replace(secretKey)
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(normalized, ".") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// copyConfigValue deep copies the maps and arrays of a decoded JSON value
+func copyConfigValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		valueCopy := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			valueCopy[key] = copyConfigValue(child)
+		}
+		return valueCopy
+	case []interface{}:
+		valueCopy := make([]interface{}, len(v))
+		for i, child := range v {
+			valueCopy[i] = copyConfigValue(child)
+		}
+		return valueCopy
+	default:
+		return v
+	}
+}
@@ -0,0 +1,90 @@
+package backendconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactString(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RedactionPolicy
+		secret string
+		want   string
+	}{
+		{name: "full", policy: RedactFull, secret: "abcdefghijkl", want: redactedPlaceholder},
+		{name: "partial", policy: RedactPartial, secret: "abcdefghijkl", want: "abcdxxxxxxxx"},
+		{name: "partial keeps a third rounded up", policy: RedactPartial, secret: "abcdefghijklmn", want: "abcdexxxxxxxxx"},
+		{name: "partial fully masks short secrets", policy: RedactPartial, secret: "abcdefghijk", want: redactedPlaceholder},
+		{name: "partial fully masks empty secrets", policy: RedactPartial, secret: "", want: redactedPlaceholder},
+		{name: "partial slices runes", policy: RedactPartial, secret: "ééééüüüüööö€", want: "ééééxxxxxxxx"},
+		{name: "unknown policy is partial", policy: "", secret: "abcdefghijkl", want: "abcdxxxxxxxx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRedactor(tt.policy, false).redactString(tt.secret); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactFingerprint(t *testing.T) {
+	redactor := NewRedactor(RedactFingerprint, false)
+	fingerprint := redactor.redactString("secret")
+	if !strings.HasPrefix(fingerprint, "hmac-sha256:") || len(fingerprint) != len("hmac-sha256:")+12 {
+		t.Fatalf("unexpected fingerprint %q", fingerprint)
+	}
+	if again := redactor.redactString("secret"); again != fingerprint {
+		t.Errorf("fingerprint of the same secret changed from %q to %q", fingerprint, again)
+	}
+	if other := redactor.redactString("secret2"); other == fingerprint {
+		t.Errorf("different secrets have the same fingerprint %q", fingerprint)
+	}
+
+	// an unkeyed hash of the secret must not reveal it
+	hash := sha256.Sum256([]byte("secret"))
+	if strings.HasSuffix(fingerprint, hex.EncodeToString(hash[:])[:12]) {
+		t.Errorf("fingerprint %q is an unkeyed SHA-256 of the secret", fingerprint)
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	config := map[string]interface{}{
+		"apiKey": "abcdefghijkl",
+		"auth":   map[string]interface{}{"token": "abcdefghijkl", "user": "admin"},
+		"headers": map[string]interface{}{
+			"x-api-key": "abcdefghijkl",
+			"x-secret":  "short",
+		},
+		"mappings": []interface{}{
+			map[string]interface{}{"secret": "abcdefghijkl", "name": "first"},
+			map[string]interface{}{"secret": "abcdefghijkl", "name": "second"},
+		},
+		"port": 5432,
+	}
+	redacted := NewRedactor(RedactPartial, false).RedactConfig(config, []string{"apiKey", "auth.token", "headers.*", "mappings[0].secret", "port"})
+
+	want := map[string]interface{}{
+		"apiKey": "abcdxxxxxxxx",
+		"auth":   map[string]interface{}{"token": "abcdxxxxxxxx", "user": "admin"},
+		"headers": map[string]interface{}{
+			"x-api-key": "abcdxxxxxxxx",
+			"x-secret":  redactedPlaceholder,
+		},
+		"mappings": []interface{}{
+			map[string]interface{}{"secret": "abcdxxxxxxxx", "name": "first"},
+			map[string]interface{}{"secret": "abcdefghijkl", "name": "second"},
+		},
+		"port": redactedPlaceholder,
+	}
+	if !reflect.DeepEqual(redacted, want) {
+		t.Errorf("got %v, want %v", redacted, want)
+	}
+	if config["apiKey"] != "abcdefghijkl" {
+		t.Error("RedactConfig modified the config")
+	}
+}
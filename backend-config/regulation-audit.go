@@ -0,0 +1,237 @@
+package backendconfig
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+const (
+	// RegulationFirstSeen is logged the first time this server fetches a regulation, across restarts
+	RegulationFirstSeen = "first_seen"
+	// RegulationApplied is logged every time a regulation is published to subscribers, including after restarts
+	RegulationApplied = "applied"
+	// RegulationRevoked is logged when a published regulation is no longer returned by the config backend
+	RegulationRevoked = "revoked"
+)
+
+// RegulationAuditEventT is a line of the regulation audit log. SourceID is empty for workspace regulations.
+type RegulationAuditEventT struct {
+	Time           time.Time `json:"time"`
+	Event          string    `json:"event"`
+	RegulationID   string    `json:"regulationId"`
+	RegulationType string    `json:"regulationType"`
+	WorkspaceID    string    `json:"workspaceId"`
+	SourceID       string    `json:"sourceId,omitempty"`
+	UserID         string    `json:"userId"`
+}
+
+/*
+regulationAuditLog appends the lifecycle events of regulations to a JSON lines file, which is never rewritten.
+The IDs of regulations already seen are read back from the file when it is first written to, so that first_seen survives restarts.
+*/
+type regulationAuditLog struct {
+	path string
+	lock sync.Mutex
+	file *os.File
+	seen map[string]struct{}
+}
+
+// newRegulationAuditLog returns a log appending to path, nil if path is empty
+func newRegulationAuditLog(path string) *regulationAuditLog {
+	if path == "" {
+		return nil
+	}
+	return &regulationAuditLog{path: path}
+}
+
+func (auditLog *regulationAuditLog) open() error {
+	if auditLog.file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(auditLog.path), 0755); err != nil {
+		return err
+	}
+
+	seen := make(map[string]struct{})
+	// a corrupted line mustn't stop regulations from being published, it only loses the first_seen event it may hold
+	completeLength, invalidLines, err := readRegulationAuditLog(auditLog.path, time.Time{}, time.Time{}, true, func(event RegulationAuditEventT) error {
+		if event.Event == RegulationFirstSeen {
+			seen[event.RegulationID] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if invalidLines > 0 {
+		pkgLogger.Errorf("Skipped %d invalid lines of regulation audit log %s", invalidLines, auditLog.path)
+		stats.NewStat("config_backend.regulation_audit_invalid_lines", stats.CountType).Count(invalidLines)
+	}
+	// drop a line truncated by a crash, its batch was never synced and is logged again with the next update
+	if info, statErr := os.Stat(auditLog.path); statErr == nil && info.Size() > completeLength {
+		if err = os.Truncate(auditLog.path, completeLength); err != nil {
+			return err
+		}
+	}
+
+	// the log holds user IDs, keep it private to the server
+	file, err := os.OpenFile(auditLog.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	auditLog.file, auditLog.seen = file, seen
+	return nil
+}
+
+/*
+record logs the events of changes at now. A regulation changed under the same ID is logged as revoked and applied again.
+It is called before changes are published, which are held back when it fails, so that no regulation is applied without being logged.
+*/
+func (auditLog *regulationAuditLog) record(changes RegulationChangesT, now time.Time) error {
+	if auditLog == nil || changes.IsEmpty() {
+		return nil
+	}
+	auditLog.lock.Lock()
+	defer auditLog.lock.Unlock()
+
+	err := auditLog.open()
+	if err == nil {
+		events, firstSeen := auditLog.events(changes, now)
+		if err = auditLog.write(events); err == nil {
+			for _, regulationID := range firstSeen {
+				auditLog.seen[regulationID] = struct{}{}
+			}
+		}
+	}
+	if err != nil {
+		pkgLogger.Errorf("Unable to write regulation audit log %s: %s", auditLog.path, err.Error())
+		stats.NewStat("config_backend.regulation_audit_errors", stats.CountType).Increment()
+	}
+	return err
+}
+
+// events returns the events of changes, along with the IDs of the regulations they log as first seen
+func (auditLog *regulationAuditLog) events(changes RegulationChangesT, now time.Time) (events []RegulationAuditEventT, firstSeen []string) {
+	events = make([]RegulationAuditEventT, 0)
+	for _, workspaceChanges := range changes.Workspaces {
+		for _, regulation := range workspaceChanges.Removed {
+			events = append(events, RegulationAuditEventT{Time: now, Event: RegulationRevoked, RegulationID: regulation.ID, RegulationType: string(regulation.RegulationType), WorkspaceID: regulation.WorkspaceID, UserID: regulation.UserID})
+		}
+		for _, sourceChanges := range workspaceChanges.Sources {
+			for _, regulation := range sourceChanges.Removed {
+				events = append(events, RegulationAuditEventT{Time: now, Event: RegulationRevoked, RegulationID: regulation.ID, RegulationType: string(regulation.RegulationType), WorkspaceID: regulation.WorkspaceID, SourceID: regulation.SourceID, UserID: regulation.UserID})
+			}
+		}
+	}
+	added := make([]RegulationAuditEventT, 0)
+	for _, workspaceChanges := range changes.Workspaces {
+		for _, regulation := range workspaceChanges.Added {
+			added = append(added, RegulationAuditEventT{Time: now, RegulationID: regulation.ID, RegulationType: string(regulation.RegulationType), WorkspaceID: regulation.WorkspaceID, UserID: regulation.UserID})
+		}
+		for _, sourceChanges := range workspaceChanges.Sources {
+			for _, regulation := range sourceChanges.Added {
+				added = append(added, RegulationAuditEventT{Time: now, RegulationID: regulation.ID, RegulationType: string(regulation.RegulationType), WorkspaceID: regulation.WorkspaceID, SourceID: regulation.SourceID, UserID: regulation.UserID})
+			}
+		}
+	}
+	for _, event := range added {
+		if _, ok := auditLog.seen[event.RegulationID]; !ok {
+			firstSeen = append(firstSeen, event.RegulationID)
+			event.Event = RegulationFirstSeen
+			events = append(events, event)
+		}
+		event.Event = RegulationApplied
+		events = append(events, event)
+	}
+	return events, firstSeen
+}
+
+/*
+write appends events and syncs the file, so that events are on disk before the regulations are published.
+A batch that fails is cut off the log, as it is logged again in full with the next update.
+*/
+func (auditLog *regulationAuditLog) write(events []RegulationAuditEventT) error {
+	info, err := auditLog.file.Stat()
+	if err != nil {
+		return err
+	}
+	if err = auditLog.writeEvents(events); err != nil {
+		if truncateErr := auditLog.file.Truncate(info.Size()); truncateErr != nil {
+			// open reads the log back and drops the truncated line, if any, on the next attempt
+			auditLog.file.Close()
+			auditLog.file = nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (auditLog *regulationAuditLog) writeEvents(events []RegulationAuditEventT) error {
+	writer := bufio.NewWriter(auditLog.file)
+	encoder := json.NewEncoder(writer)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return auditLog.file.Sync()
+}
+
+/*
+ReadRegulationAuditLog calls f with the events of the audit log at path logged in [from, to), in the order they were logged.
+A zero from or to leaves that end of the range open. A truncated last line, left by a crash while writing, is skipped.
+*/
+func ReadRegulationAuditLog(path string, from time.Time, to time.Time, f func(event RegulationAuditEventT) error) error {
+	_, _, err := readRegulationAuditLog(path, from, to, false, f)
+	return err
+}
+
+/*
+readRegulationAuditLog is ReadRegulationAuditLog, also returning the length of the log up to its last complete line.
+With skipInvalid, complete lines that can't be parsed are skipped and counted in invalidLines instead of failing the read.
+*/
+func readRegulationAuditLog(path string, from time.Time, to time.Time, skipInvalid bool, f func(event RegulationAuditEventT) error) (completeLength int64, invalidLines int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			completeLength += int64(len(line))
+			var event RegulationAuditEventT
+			if err = json.Unmarshal(line, &event); err != nil {
+				if !skipInvalid {
+					return completeLength, invalidLines, fmt.Errorf("invalid regulation audit event at %s:%d: %w", path, lineNumber, err)
+				}
+				invalidLines++
+				continue
+			}
+			if (from.IsZero() || !event.Time.Before(from)) && (to.IsZero() || event.Time.Before(to)) {
+				if err = f(event); err != nil {
+					return completeLength, invalidLines, err
+				}
+			}
+		}
+		if readErr == io.EOF {
+			return completeLength, invalidLines, nil
+		}
+		if readErr != nil {
+			return completeLength, invalidLines, readErr
+		}
+	}
+}
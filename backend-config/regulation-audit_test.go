@@ -0,0 +1,115 @@
+package backendconfig
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegulationAuditLogOpen(t *testing.T) {
+	firstSeen := func(regulationID string) string {
+		return `{"time":"2021-06-01T00:00:00Z","event":"first_seen","regulationId":"` + regulationID + `","regulationType":"Suppress","workspaceId":"workspace-1","userId":"user-1"}` + "\n"
+	}
+	tests := []struct {
+		name          string
+		log           string
+		wantSeen      []string
+		wantLog       string
+		wantReadError bool
+	}{
+		{
+			name:     "complete",
+			log:      firstSeen("1") + firstSeen("2"),
+			wantSeen: []string{"1", "2"},
+			wantLog:  firstSeen("1") + firstSeen("2"),
+		},
+		{
+			name:     "truncated last line",
+			log:      firstSeen("1") + `{"time":"2021-06-01T00:00:00Z","event":"first_`,
+			wantSeen: []string{"1"},
+			wantLog:  firstSeen("1"),
+		},
+		{
+			name:          "corrupted line in the middle",
+			log:           firstSeen("1") + "\x00\x00\x00\n" + firstSeen("2"),
+			wantSeen:      []string{"1", "2"},
+			wantLog:       firstSeen("1") + "\x00\x00\x00\n" + firstSeen("2"),
+			wantReadError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			if err := ioutil.WriteFile(path, []byte(tt.log), 0600); err != nil {
+				t.Fatal(err)
+			}
+			auditLog := newRegulationAuditLog(path)
+			if err := auditLog.open(); err != nil {
+				t.Fatal(err)
+			}
+			defer auditLog.file.Close()
+
+			seen := make([]string, 0, len(auditLog.seen))
+			for regulationID := range auditLog.seen {
+				seen = append(seen, regulationID)
+			}
+			sort.Strings(seen)
+			if !reflect.DeepEqual(seen, tt.wantSeen) {
+				t.Errorf("got seen %v, want %v", seen, tt.wantSeen)
+			}
+			if data, err := ioutil.ReadFile(path); err != nil || string(data) != tt.wantLog {
+				t.Errorf("got log %q, %v, want %q", data, err, tt.wantLog)
+			}
+			// exports still fail on corrupted lines, an audit must not silently miss events
+			if err := ReadRegulationAuditLog(path, time.Time{}, time.Time{}, func(RegulationAuditEventT) error { return nil }); (err != nil) != tt.wantReadError {
+				t.Errorf("got read error %v, want error %t", err, tt.wantReadError)
+			}
+		})
+	}
+}
+
+func TestRegulationsHeldBackUntilAudited(t *testing.T) {
+	backend := newTestConfigBackend(t, testSourcesConfig("destination-1"))
+	backend.setRegulations(RegulationsT{
+		WorkspaceRegulations: []WorkspaceRegulationT{{ID: "regulation-1", RegulationType: RegulationSuppress, WorkspaceID: "workspace-1", UserID: "user-1"}},
+		SourceRegulations:    []SourceRegulationT{},
+	})
+	// a directory can't be created below a file, whatever the permissions of the user running the test
+	file := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(file, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+		setup.RegulationAuditLogPath = filepath.Join(file, "audit", "regulations.log")
+	})
+
+	if changed, ok := instance.regulationsUpdate(testStatConfigBackendError); changed || ok {
+		t.Fatalf("regulations published although they couldn't be audited: changed %t, ok %t", changed, ok)
+	}
+	if instance.IsSuppressedUser("workspace-1", "source-1", "user-1") || len(instance.GetCurrentRegulations().WorkspaceRegulations) != 0 {
+		t.Error("regulations applied although they couldn't be audited")
+	}
+	if instance.poller.lastRegulationsError == nil {
+		t.Error("audit failure not recorded as a sync error")
+	}
+
+	path := filepath.Join(t.TempDir(), "regulations.log")
+	instance.regulationAudit.path = path
+	if changed, ok := instance.regulationsUpdate(testStatConfigBackendError); !changed || !ok {
+		t.Fatalf("regulations not published once audited: changed %t, ok %t", changed, ok)
+	}
+	if !instance.IsSuppressedUser("workspace-1", "source-1", "user-1") {
+		t.Error("user not suppressed once the regulation is audited")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"event":"first_seen"`) || !strings.Contains(string(data), `"event":"applied"`) {
+		t.Errorf("got log %s, want the regulation first seen and applied", data)
+	}
+}
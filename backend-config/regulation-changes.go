@@ -0,0 +1,169 @@
+package backendconfig
+
+import (
+	"sort"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+/*
+RegulationChangesT is published on TopicRegulationChanges with the regulations added and removed since the previous publish,
+keyed by workspace ID. A regulation whose type or user changed under the same ID is reported as removed and added again.
+*/
+type RegulationChangesT struct {
+	Workspaces map[string]*WorkspaceRegulationChangesT `json:"workspaces"`
+}
+
+// WorkspaceRegulationChangesT holds the changes of one workspace, with source regulation changes keyed by source ID
+type WorkspaceRegulationChangesT struct {
+	Added   []WorkspaceRegulationT               `json:"added"`
+	Removed []WorkspaceRegulationT               `json:"removed"`
+	Sources map[string]*SourceRegulationChangesT `json:"sources"`
+}
+
+// SourceRegulationChangesT holds the changes of the regulations of one source
+type SourceRegulationChangesT struct {
+	Added   []SourceRegulationT `json:"added"`
+	Removed []SourceRegulationT `json:"removed"`
+}
+
+// IsEmpty reports whether no regulation was added or removed
+func (changes RegulationChangesT) IsEmpty() bool {
+	return len(changes.Workspaces) == 0
+}
+
+// AddedUserIDs returns the user IDs of the added workspace regulations and of the added regulations of each source
+func (changes *WorkspaceRegulationChangesT) AddedUserIDs() (workspaceUserIDs []string, sourceUserIDs map[string][]string) {
+	workspaceUserIDs = make([]string, 0, len(changes.Added))
+	for _, regulation := range changes.Added {
+		workspaceUserIDs = append(workspaceUserIDs, regulation.UserID)
+	}
+	sourceUserIDs = make(map[string][]string)
+	for sourceID, sourceChanges := range changes.Sources {
+		for _, regulation := range sourceChanges.Added {
+			sourceUserIDs[sourceID] = append(sourceUserIDs[sourceID], regulation.UserID)
+		}
+	}
+	return workspaceUserIDs, sourceUserIDs
+}
+
+// RemovedUserIDs returns the user IDs of the removed workspace regulations and of the removed regulations of each source
+func (changes *WorkspaceRegulationChangesT) RemovedUserIDs() (workspaceUserIDs []string, sourceUserIDs map[string][]string) {
+	workspaceUserIDs = make([]string, 0, len(changes.Removed))
+	for _, regulation := range changes.Removed {
+		workspaceUserIDs = append(workspaceUserIDs, regulation.UserID)
+	}
+	sourceUserIDs = make(map[string][]string)
+	for sourceID, sourceChanges := range changes.Sources {
+		for _, regulation := range sourceChanges.Removed {
+			sourceUserIDs[sourceID] = append(sourceUserIDs[sourceID], regulation.UserID)
+		}
+	}
+	return workspaceUserIDs, sourceUserIDs
+}
+
+func (changes *RegulationChangesT) workspace(workspaceID string) *WorkspaceRegulationChangesT {
+	if _, ok := changes.Workspaces[workspaceID]; !ok {
+		changes.Workspaces[workspaceID] = &WorkspaceRegulationChangesT{
+			Added:   make([]WorkspaceRegulationT, 0),
+			Removed: make([]WorkspaceRegulationT, 0),
+			Sources: make(map[string]*SourceRegulationChangesT),
+		}
+	}
+	return changes.Workspaces[workspaceID]
+}
+
+func (changes *RegulationChangesT) source(workspaceID string, sourceID string) *SourceRegulationChangesT {
+	workspaceChanges := changes.workspace(workspaceID)
+	if _, ok := workspaceChanges.Sources[sourceID]; !ok {
+		workspaceChanges.Sources[sourceID] = &SourceRegulationChangesT{
+			Added:   make([]SourceRegulationT, 0),
+			Removed: make([]SourceRegulationT, 0),
+		}
+	}
+	return workspaceChanges.Sources[sourceID]
+}
+
+/*
+diffRegulations returns the regulations of current that aren't in previous as added and the ones of previous that aren't in current as removed.
+Regulations are matched by ID. The changes of each workspace and source are sorted by ID like the regulations themselves.
+*/
+func diffRegulations(previous RegulationsT, current RegulationsT) RegulationChangesT {
+	changes := RegulationChangesT{Workspaces: make(map[string]*WorkspaceRegulationChangesT)}
+
+	previousWorkspaceRegulations := make(map[string]WorkspaceRegulationT, len(previous.WorkspaceRegulations))
+	for _, regulation := range previous.WorkspaceRegulations {
+		previousWorkspaceRegulations[regulation.ID] = regulation
+	}
+	currentWorkspaceRegulations := make(map[string]WorkspaceRegulationT, len(current.WorkspaceRegulations))
+	for _, regulation := range current.WorkspaceRegulations {
+		currentWorkspaceRegulations[regulation.ID] = regulation
+		if previousRegulation, ok := previousWorkspaceRegulations[regulation.ID]; !ok || previousRegulation != regulation {
+			workspaceChanges := changes.workspace(regulation.WorkspaceID)
+			workspaceChanges.Added = append(workspaceChanges.Added, regulation)
+		}
+	}
+	for _, regulation := range previous.WorkspaceRegulations {
+		if currentRegulation, ok := currentWorkspaceRegulations[regulation.ID]; !ok || currentRegulation != regulation {
+			workspaceChanges := changes.workspace(regulation.WorkspaceID)
+			workspaceChanges.Removed = append(workspaceChanges.Removed, regulation)
+		}
+	}
+
+	previousSourceRegulations := make(map[string]SourceRegulationT, len(previous.SourceRegulations))
+	for _, regulation := range previous.SourceRegulations {
+		previousSourceRegulations[regulation.ID] = regulation
+	}
+	currentSourceRegulations := make(map[string]SourceRegulationT, len(current.SourceRegulations))
+	for _, regulation := range current.SourceRegulations {
+		currentSourceRegulations[regulation.ID] = regulation
+		if previousRegulation, ok := previousSourceRegulations[regulation.ID]; !ok || previousRegulation != regulation {
+			sourceChanges := changes.source(regulation.WorkspaceID, regulation.SourceID)
+			sourceChanges.Added = append(sourceChanges.Added, regulation)
+		}
+	}
+	for _, regulation := range previous.SourceRegulations {
+		if currentRegulation, ok := currentSourceRegulations[regulation.ID]; !ok || currentRegulation != regulation {
+			sourceChanges := changes.source(regulation.WorkspaceID, regulation.SourceID)
+			sourceChanges.Removed = append(sourceChanges.Removed, regulation)
+		}
+	}
+
+	for _, workspaceChanges := range changes.Workspaces {
+		sortWorkspaceRegulations(workspaceChanges.Added)
+		sortWorkspaceRegulations(workspaceChanges.Removed)
+		for _, sourceChanges := range workspaceChanges.Sources {
+			sortSourceRegulations(sourceChanges.Added)
+			sortSourceRegulations(sourceChanges.Removed)
+		}
+	}
+	return changes
+}
+
+func sortWorkspaceRegulations(regulations []WorkspaceRegulationT) {
+	sort.Slice(regulations, func(i, j int) bool {
+		return regulations[i].ID < regulations[j].ID
+	})
+}
+
+func sortSourceRegulations(regulations []SourceRegulationT) {
+	sort.Slice(regulations, func(i, j int) bool {
+		return regulations[i].ID < regulations[j].ID
+	})
+}
+
+// reportRegulationChanges counts the regulations added and removed by changes
+func reportRegulationChanges(changes RegulationChangesT) {
+	added, removed := 0, 0
+	for _, workspaceChanges := range changes.Workspaces {
+		added += len(workspaceChanges.Added)
+		removed += len(workspaceChanges.Removed)
+		for _, sourceChanges := range workspaceChanges.Sources {
+			added += len(sourceChanges.Added)
+			removed += len(sourceChanges.Removed)
+		}
+	}
+	pkgLogger.Infof("Regulations changed, added: %d, removed: %d", added, removed)
+	stats.NewTaggedStat("config_backend.regulation_changes", stats.CountType, map[string]string{"change": "added"}).Count(added)
+	stats.NewTaggedStat("config_backend.regulation_changes", stats.CountType, map[string]string{"change": "removed"}).Count(removed)
+}
@@ -0,0 +1,106 @@
+package backendconfig
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffRegulations(t *testing.T) {
+	workspaceRegulation := func(id string, userID string) WorkspaceRegulationT {
+		return WorkspaceRegulationT{ID: id, RegulationType: "suppress", WorkspaceID: "workspace-1", UserID: userID}
+	}
+	sourceRegulation := func(id string, sourceID string, userID string) SourceRegulationT {
+		return SourceRegulationT{ID: id, RegulationType: "suppress", WorkspaceID: "workspace-1", SourceID: sourceID, UserID: userID}
+	}
+	tests := []struct {
+		name     string
+		previous RegulationsT
+		current  RegulationsT
+		want     RegulationChangesT
+	}{
+		{
+			name:     "unchanged",
+			previous: RegulationsT{WorkspaceRegulations: []WorkspaceRegulationT{workspaceRegulation("1", "user-1")}, SourceRegulations: []SourceRegulationT{sourceRegulation("2", "source-1", "user-2")}},
+			current:  RegulationsT{WorkspaceRegulations: []WorkspaceRegulationT{workspaceRegulation("1", "user-1")}, SourceRegulations: []SourceRegulationT{sourceRegulation("2", "source-1", "user-2")}},
+			want:     RegulationChangesT{Workspaces: map[string]*WorkspaceRegulationChangesT{}},
+		},
+		{
+			name:    "all added",
+			current: RegulationsT{WorkspaceRegulations: []WorkspaceRegulationT{workspaceRegulation("2", "user-2"), workspaceRegulation("1", "user-1")}},
+			want: RegulationChangesT{Workspaces: map[string]*WorkspaceRegulationChangesT{"workspace-1": {
+				Added:   []WorkspaceRegulationT{workspaceRegulation("1", "user-1"), workspaceRegulation("2", "user-2")},
+				Removed: []WorkspaceRegulationT{},
+				Sources: map[string]*SourceRegulationChangesT{},
+			}}},
+		},
+		{
+			name:     "all removed",
+			previous: RegulationsT{SourceRegulations: []SourceRegulationT{sourceRegulation("1", "source-1", "user-1")}},
+			want: RegulationChangesT{Workspaces: map[string]*WorkspaceRegulationChangesT{"workspace-1": {
+				Added:   []WorkspaceRegulationT{},
+				Removed: []WorkspaceRegulationT{},
+				Sources: map[string]*SourceRegulationChangesT{"source-1": {
+					Added:   []SourceRegulationT{},
+					Removed: []SourceRegulationT{sourceRegulation("1", "source-1", "user-1")},
+				}},
+			}}},
+		},
+		{
+			name:     "user changed under the same ID",
+			previous: RegulationsT{WorkspaceRegulations: []WorkspaceRegulationT{workspaceRegulation("1", "user-1")}},
+			current:  RegulationsT{WorkspaceRegulations: []WorkspaceRegulationT{workspaceRegulation("1", "user-2")}},
+			want: RegulationChangesT{Workspaces: map[string]*WorkspaceRegulationChangesT{"workspace-1": {
+				Added:   []WorkspaceRegulationT{workspaceRegulation("1", "user-2")},
+				Removed: []WorkspaceRegulationT{workspaceRegulation("1", "user-1")},
+				Sources: map[string]*SourceRegulationChangesT{},
+			}}},
+		},
+		{
+			name:     "keyed by source",
+			previous: RegulationsT{SourceRegulations: []SourceRegulationT{sourceRegulation("1", "source-1", "user-1"), sourceRegulation("2", "source-2", "user-2")}},
+			current:  RegulationsT{SourceRegulations: []SourceRegulationT{sourceRegulation("2", "source-2", "user-2"), sourceRegulation("3", "source-2", "user-3")}},
+			want: RegulationChangesT{Workspaces: map[string]*WorkspaceRegulationChangesT{"workspace-1": {
+				Added:   []WorkspaceRegulationT{},
+				Removed: []WorkspaceRegulationT{},
+				Sources: map[string]*SourceRegulationChangesT{
+					"source-1": {Added: []SourceRegulationT{}, Removed: []SourceRegulationT{sourceRegulation("1", "source-1", "user-1")}},
+					"source-2": {Added: []SourceRegulationT{sourceRegulation("3", "source-2", "user-3")}, Removed: []SourceRegulationT{}},
+				},
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffRegulations(tt.previous, tt.current)
+			if !reflect.DeepEqual(got, tt.want) {
+				gotJSON, _ := json.Marshal(got)
+				wantJSON, _ := json.Marshal(tt.want)
+				t.Errorf("got %s, want %s", gotJSON, wantJSON)
+			}
+			if got.IsEmpty() != (len(tt.want.Workspaces) == 0) {
+				t.Errorf("IsEmpty() = %t", got.IsEmpty())
+			}
+		})
+	}
+}
+
+func TestRegulationChangesUserIDs(t *testing.T) {
+	changes := WorkspaceRegulationChangesT{
+		Added:   []WorkspaceRegulationT{{ID: "1", UserID: "user-1"}},
+		Removed: []WorkspaceRegulationT{{ID: "2", UserID: "user-2"}},
+		Sources: map[string]*SourceRegulationChangesT{
+			"source-1": {Added: []SourceRegulationT{{ID: "3", UserID: "user-3"}}, Removed: []SourceRegulationT{}},
+			"source-2": {Added: []SourceRegulationT{}, Removed: []SourceRegulationT{{ID: "4", UserID: "user-4"}}},
+		},
+	}
+
+	workspaceUserIDs, sourceUserIDs := changes.AddedUserIDs()
+	if !reflect.DeepEqual(workspaceUserIDs, []string{"user-1"}) || !reflect.DeepEqual(sourceUserIDs, map[string][]string{"source-1": {"user-3"}}) {
+		t.Errorf("got added %v and %v", workspaceUserIDs, sourceUserIDs)
+	}
+	workspaceUserIDs, sourceUserIDs = changes.RemovedUserIDs()
+	if !reflect.DeepEqual(workspaceUserIDs, []string{"user-2"}) || !reflect.DeepEqual(sourceUserIDs, map[string][]string{"source-2": {"user-4"}}) {
+		t.Errorf("got removed %v and %v", workspaceUserIDs, sourceUserIDs)
+	}
+}
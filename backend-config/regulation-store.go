@@ -0,0 +1,745 @@
+package backendconfig
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+const (
+	// RegulationStoreMap keeps suppressed user IDs in plain maps, which is fast but takes a few hundred bytes per user
+	RegulationStoreMap = "map"
+	// RegulationStoreCompact keeps 8 byte hashes of suppressed user IDs in sorted sets, optionally behind a Bloom filter and spilled to disk
+	RegulationStoreCompact = "compact"
+
+	// compactSetMaxPending is the number of additions and removals a compact set buffers before merging them into its sorted hashes
+	compactSetMaxPending = 4096
+	// compactSetSpillSize is the number of hashes from which a compact set is kept on disk when spilling is enabled
+	compactSetSpillSize = 1 << 16
+	// compactSetSpillBlockSize is the number of hashes of a block of a spill file, a lookup reads a single 4KB block
+	compactSetSpillBlockSize = 512
+	// bloomFilterFalsePositiveRate is the rate the Bloom filter is sized for
+	bloomFilterFalsePositiveRate = 0.01
+)
+
+/*
+RegulationStore answers whether the events of a user must be suppressed. A workspace regulation suppresses the user in every source of
+the workspace, a source regulation in that source only. Stores are kept up to date with the RegulationChangesT of every update, so
+the gateway can switch between them through BackendConfigSetup.RegulationStore without any other change.
+*/
+type RegulationStore interface {
+	IsSuppressedUser(workspaceID string, sourceID string, userID string) bool
+	Apply(changes RegulationChangesT)
+	// Len returns the number of suppressed users, counting a user once per workspace or source regulating it
+	Len() int
+}
+
+// NewRegulationStore returns the store selected by setup. Unknown stores fall back to RegulationStoreMap.
+func NewRegulationStore(setup BackendConfigSetup) RegulationStore {
+	switch setup.RegulationStore {
+	case RegulationStoreCompact:
+		return newCompactRegulationStore(setup.RegulationStoreBloomFilter, setup.RegulationStoreSpillDir)
+	case RegulationStoreMap, "":
+		return newMapRegulationStore()
+	default:
+		pkgLogger.Errorf("Unknown regulation store %q, using %s", setup.RegulationStore, RegulationStoreMap)
+		return newMapRegulationStore()
+	}
+}
+
+// applyRegulationChanges calls remove for the suppressing regulations removed by changes, then add for the added ones.
+// An empty sourceID stands for a workspace regulation.
+func applyRegulationChanges(changes RegulationChangesT, add func(workspaceID, sourceID, userID string), remove func(workspaceID, sourceID, userID string)) {
+	for workspaceID, workspaceChanges := range changes.Workspaces {
+		for _, regulation := range workspaceChanges.Removed {
+			if regulation.RegulationType.Suppresses() {
+				remove(workspaceID, "", regulation.UserID)
+			}
+		}
+		for sourceID, sourceChanges := range workspaceChanges.Sources {
+			for _, regulation := range sourceChanges.Removed {
+				if regulation.RegulationType.Suppresses() {
+					remove(workspaceID, sourceID, regulation.UserID)
+				}
+			}
+		}
+	}
+	for workspaceID, workspaceChanges := range changes.Workspaces {
+		for _, regulation := range workspaceChanges.Added {
+			if regulation.RegulationType.Suppresses() {
+				add(workspaceID, "", regulation.UserID)
+			}
+		}
+		for sourceID, sourceChanges := range workspaceChanges.Sources {
+			for _, regulation := range sourceChanges.Added {
+				if regulation.RegulationType.Suppresses() {
+					add(workspaceID, sourceID, regulation.UserID)
+				}
+			}
+		}
+	}
+}
+
+// mapRegulationStore counts the regulations of each user by workspace and source, an empty source holding workspace regulations
+type mapRegulationStore struct {
+	lock  sync.RWMutex
+	users map[string]map[string]map[string]int
+	count int
+}
+
+func newMapRegulationStore() *mapRegulationStore {
+	return &mapRegulationStore{users: make(map[string]map[string]map[string]int)}
+}
+
+func (store *mapRegulationStore) IsSuppressedUser(workspaceID string, sourceID string, userID string) bool {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	sources := store.users[workspaceID]
+	return sources[""][userID] > 0 || (sourceID != "" && sources[sourceID][userID] > 0)
+}
+
+func (store *mapRegulationStore) Apply(changes RegulationChangesT) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	applyRegulationChanges(changes, store.add, store.remove)
+}
+
+func (store *mapRegulationStore) Len() int {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	return store.count
+}
+
+func (store *mapRegulationStore) add(workspaceID string, sourceID string, userID string) {
+	if _, ok := store.users[workspaceID]; !ok {
+		store.users[workspaceID] = make(map[string]map[string]int)
+	}
+	if _, ok := store.users[workspaceID][sourceID]; !ok {
+		store.users[workspaceID][sourceID] = make(map[string]int)
+	}
+	if store.users[workspaceID][sourceID][userID] == 0 {
+		store.count++
+	}
+	store.users[workspaceID][sourceID][userID]++
+}
+
+func (store *mapRegulationStore) remove(workspaceID string, sourceID string, userID string) {
+	users := store.users[workspaceID][sourceID]
+	if users[userID] == 0 {
+		return
+	}
+	users[userID]--
+	if users[userID] > 0 {
+		return
+	}
+	store.count--
+	delete(users, userID)
+	if len(users) == 0 {
+		delete(store.users[workspaceID], sourceID)
+	}
+	if len(store.users[workspaceID]) == 0 {
+		delete(store.users, workspaceID)
+	}
+}
+
+// regulationSetKey identifies the users regulated in a workspace or source by interned IDs, source 0 holding workspace regulations
+type regulationSetKey struct {
+	workspace uint32
+	source    uint32
+}
+
+type regulationEntry struct {
+	set      regulationSetKey
+	userHash uint64
+}
+
+/*
+compactRegulationStore keeps 64 bit FNV-1a hashes of suppressed user IDs instead of the IDs, in sorted sets per interned workspace
+and source ID. With millions of users, a hash collision suppressing an unregulated user is in the order of one in 10^12 lookups.
+Users regulated more than once by the same workspace or source are counted in duplicates, so that they stay suppressed until
+their last regulation is removed.
+*/
+type compactRegulationStore struct {
+	lock       sync.RWMutex
+	ids        map[string]uint32
+	sets       map[regulationSetKey]*compactUserSet
+	duplicates map[regulationEntry]int
+	count      int
+
+	// bloom answers most lookups of unregulated users without searching the sets. As it can't forget, it is rebuilt once
+	// removals reach a tenth of the users.
+	bloom         *bloomFilter
+	bloomRemovals int
+	useBloom      bool
+
+	spillDir string
+}
+
+func newCompactRegulationStore(useBloom bool, spillDir string) *compactRegulationStore {
+	if spillDir != "" {
+		if err := os.MkdirAll(spillDir, 0755); err != nil {
+			pkgLogger.Errorf("Unable to create regulation spill directory %s, keeping regulations in memory: %s", spillDir, err.Error())
+			spillDir = ""
+		}
+	}
+	store := &compactRegulationStore{
+		// the empty source ID is interned first so that workspace regulations get source 0
+		ids:        map[string]uint32{"": 0},
+		sets:       make(map[regulationSetKey]*compactUserSet),
+		duplicates: make(map[regulationEntry]int),
+		useBloom:   useBloom,
+		spillDir:   spillDir,
+	}
+	if useBloom {
+		store.bloom = newBloomFilter(0)
+	}
+	return store
+}
+
+func hashUserID(userID string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(userID))
+	return hash.Sum64()
+}
+
+func (store *compactRegulationStore) IsSuppressedUser(workspaceID string, sourceID string, userID string) bool {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	workspace, ok := store.ids[workspaceID]
+	if !ok {
+		return false
+	}
+	userHash := hashUserID(userID)
+	if store.contains(regulationSetKey{workspace: workspace}, userHash) {
+		return true
+	}
+	source, ok := store.ids[sourceID]
+	return ok && source != 0 && store.contains(regulationSetKey{workspace: workspace, source: source}, userHash)
+}
+
+func (store *compactRegulationStore) contains(key regulationSetKey, userHash uint64) bool {
+	set, ok := store.sets[key]
+	if !ok {
+		return false
+	}
+	if store.bloom != nil && !store.bloom.mayContain(key, userHash) {
+		return false
+	}
+	return set.contains(userHash)
+}
+
+func (store *compactRegulationStore) Apply(changes RegulationChangesT) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	applyRegulationChanges(changes, store.add, store.remove)
+
+	if store.useBloom && (store.bloom.capacity < store.count || store.bloomRemovals*10 > store.count) {
+		store.rebuildBloomFilter()
+	}
+	stats.NewStat("config_backend.regulation_store_users", stats.GaugeType).Gauge(store.count)
+}
+
+func (store *compactRegulationStore) Len() int {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	return store.count
+}
+
+func (store *compactRegulationStore) intern(id string) uint32 {
+	if index, ok := store.ids[id]; ok {
+		return index
+	}
+	index := uint32(len(store.ids))
+	store.ids[id] = index
+	return index
+}
+
+func (store *compactRegulationStore) add(workspaceID string, sourceID string, userID string) {
+	key := regulationSetKey{workspace: store.intern(workspaceID), source: store.intern(sourceID)}
+	set, ok := store.sets[key]
+	if !ok {
+		set = &compactUserSet{}
+		store.sets[key] = set
+	}
+	userHash := hashUserID(userID)
+	if set.contains(userHash) {
+		store.duplicates[regulationEntry{set: key, userHash: userHash}]++
+		return
+	}
+	set.add(userHash)
+	store.count++
+	if store.bloom != nil {
+		store.bloom.add(key, userHash)
+	}
+	store.maybeCompact(key, set)
+}
+
+func (store *compactRegulationStore) remove(workspaceID string, sourceID string, userID string) {
+	workspace, ok := store.ids[workspaceID]
+	if !ok {
+		return
+	}
+	source, ok := store.ids[sourceID]
+	if !ok {
+		return
+	}
+	key := regulationSetKey{workspace: workspace, source: source}
+	set, ok := store.sets[key]
+	userHash := hashUserID(userID)
+	if !ok || !set.contains(userHash) {
+		return
+	}
+	entry := regulationEntry{set: key, userHash: userHash}
+	if store.duplicates[entry] > 0 {
+		store.duplicates[entry]--
+		if store.duplicates[entry] == 0 {
+			delete(store.duplicates, entry)
+		}
+		return
+	}
+	set.remove(userHash)
+	store.count--
+	store.bloomRemovals++
+	if set.size() == 0 {
+		set.close()
+		delete(store.sets, key)
+		return
+	}
+	store.maybeCompact(key, set)
+}
+
+func (store *compactRegulationStore) maybeCompact(key regulationSetKey, set *compactUserSet) {
+	if len(set.added)+len(set.removed) < compactSetMaxPending {
+		return
+	}
+	spillPath := ""
+	if store.spillDir != "" {
+		spillPath = filepath.Join(store.spillDir, fmt.Sprintf("%d-%d.hashes", key.workspace, key.source))
+	}
+	if err := set.compact(spillPath); err != nil {
+		pkgLogger.Errorf("Unable to spill regulations to %s, keeping them in memory: %s", spillPath, err.Error())
+	}
+}
+
+func (store *compactRegulationStore) rebuildBloomFilter() {
+	store.bloom = newBloomFilter(2 * store.count)
+	store.bloomRemovals = 0
+	for key, set := range store.sets {
+		set.each(func(userHash uint64) {
+			store.bloom.add(key, userHash)
+		})
+	}
+}
+
+/*
+compactUserSet holds sorted user hashes, in memory or in a spill file, along with the additions and removals since they were sorted.
+Merging pending changes on every update would rewrite the whole set, so they are only merged once compactSetMaxPending accumulate.
+*/
+type compactUserSet struct {
+	sorted  []uint64
+	spilled *os.File
+	// spilledCount is the number of hashes in spilled
+	spilledCount int
+	// spillIndex holds the first hash of every block of spilled
+	spillIndex []uint64
+	added      map[uint64]struct{}
+	removed    map[uint64]struct{}
+}
+
+func (set *compactUserSet) size() int {
+	return len(set.sorted) + set.spilledCount + len(set.added) - len(set.removed)
+}
+
+func (set *compactUserSet) contains(userHash uint64) bool {
+	if _, ok := set.added[userHash]; ok {
+		return true
+	}
+	if _, ok := set.removed[userHash]; ok {
+		return false
+	}
+	return set.sortedContains(userHash)
+}
+
+func (set *compactUserSet) sortedContains(userHash uint64) bool {
+	if set.spilled == nil {
+		i := sort.Search(len(set.sorted), func(i int) bool { return set.sorted[i] >= userHash })
+		return i < len(set.sorted) && set.sorted[i] == userHash
+	}
+
+	// the last block starting at or below userHash is the only one that can hold it
+	block := sort.Search(len(set.spillIndex), func(i int) bool { return set.spillIndex[i] > userHash }) - 1
+	if block < 0 {
+		return false
+	}
+	start := block * compactSetSpillBlockSize
+	count := set.spilledCount - start
+	if count > compactSetSpillBlockSize {
+		count = compactSetSpillBlockSize
+	}
+	buf := spillBlockPool.Get().(*[compactSetSpillBlockSize * 8]byte)
+	defer spillBlockPool.Put(buf)
+	data := buf[:count*8]
+	if _, err := set.spilled.ReadAt(data, int64(start)*8); err != nil {
+		pkgLogger.Errorf("Unable to read spilled regulations from %s: %s", set.spilled.Name(), err.Error())
+		return false
+	}
+	i := sort.Search(count, func(i int) bool { return binary.BigEndian.Uint64(data[i*8:]) >= userHash })
+	return i < count && binary.BigEndian.Uint64(data[i*8:]) == userHash
+}
+
+// spillBlockPool holds the buffers blocks of spill files are read into, as lookups run concurrently
+var spillBlockPool = sync.Pool{New: func() interface{} { return new([compactSetSpillBlockSize * 8]byte) }}
+
+// add adds a hash the set doesn't contain
+func (set *compactUserSet) add(userHash uint64) {
+	if _, ok := set.removed[userHash]; ok {
+		delete(set.removed, userHash)
+		return
+	}
+	if set.added == nil {
+		set.added = make(map[uint64]struct{})
+	}
+	set.added[userHash] = struct{}{}
+}
+
+// remove removes a hash the set contains
+func (set *compactUserSet) remove(userHash uint64) {
+	if _, ok := set.added[userHash]; ok {
+		delete(set.added, userHash)
+		return
+	}
+	if set.removed == nil {
+		set.removed = make(map[uint64]struct{})
+	}
+	set.removed[userHash] = struct{}{}
+}
+
+func (set *compactUserSet) each(f func(userHash uint64)) {
+	for _, userHash := range set.sortedHashes() {
+		if _, ok := set.removed[userHash]; !ok {
+			f(userHash)
+		}
+	}
+	for userHash := range set.added {
+		f(userHash)
+	}
+}
+
+// sortedHashes returns the sorted hashes, reading them back if they were spilled
+func (set *compactUserSet) sortedHashes() []uint64 {
+	if set.spilled == nil {
+		return set.sorted
+	}
+	data := make([]byte, set.spilledCount*8)
+	if _, err := set.spilled.ReadAt(data, 0); err != nil {
+		pkgLogger.Errorf("Unable to read spilled regulations from %s: %s", set.spilled.Name(), err.Error())
+		return nil
+	}
+	hashes := make([]uint64, set.spilledCount)
+	for i := range hashes {
+		hashes[i] = binary.BigEndian.Uint64(data[i*8:])
+	}
+	return hashes
+}
+
+// compact merges the pending changes into the sorted hashes, writing them to spillPath if it is set and the set is large enough
+func (set *compactUserSet) compact(spillPath string) error {
+	hashes := make([]uint64, 0, set.size())
+	set.each(func(userHash uint64) {
+		hashes = append(hashes, userHash)
+	})
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	set.close()
+	set.added, set.removed = nil, nil
+	set.sorted = hashes
+
+	if spillPath == "" || len(hashes) < compactSetSpillSize {
+		return nil
+	}
+	data := make([]byte, len(hashes)*8)
+	for i, userHash := range hashes {
+		binary.BigEndian.PutUint64(data[i*8:], userHash)
+	}
+	if err := writeFileAtomically(filepath.Dir(spillPath), filepath.Base(spillPath), data); err != nil {
+		return err
+	}
+	file, err := os.Open(spillPath)
+	if err != nil {
+		return err
+	}
+	spillIndex := make([]uint64, 0, (len(hashes)+compactSetSpillBlockSize-1)/compactSetSpillBlockSize)
+	for i := 0; i < len(hashes); i += compactSetSpillBlockSize {
+		spillIndex = append(spillIndex, hashes[i])
+	}
+	set.spilled, set.spilledCount, set.spillIndex, set.sorted = file, len(hashes), spillIndex, nil
+	return nil
+}
+
+func (set *compactUserSet) close() {
+	if set.spilled != nil {
+		set.spilled.Close()
+		set.spilled, set.spilledCount, set.spillIndex = nil, 0, nil
+	}
+}
+
+// bloomFilter is sized for capacity entries at bloomFilterFalsePositiveRate, hashing entries with double hashing
+type bloomFilter struct {
+	bits     []uint64
+	hashes   uint64
+	capacity int
+}
+
+func newBloomFilter(capacity int) *bloomFilter {
+	if capacity < 1024 {
+		capacity = 1024
+	}
+	bitCount := uint64(math.Ceil(-float64(capacity) * math.Log(bloomFilterFalsePositiveRate) / (math.Ln2 * math.Ln2)))
+	return &bloomFilter{
+		bits:     make([]uint64, (bitCount+63)/64),
+		hashes:   uint64(math.Ceil(math.Ln2 * float64(bitCount) / float64(capacity))),
+		capacity: capacity,
+	}
+}
+
+func (filter *bloomFilter) positions(key regulationSetKey, userHash uint64, f func(bit uint64) bool) bool {
+	h1 := mix64(userHash ^ (uint64(key.workspace)<<32 | uint64(key.source)))
+	h2 := mix64(h1) | 1
+	bitCount := uint64(len(filter.bits)) * 64
+	for i := uint64(0); i < filter.hashes; i++ {
+		if !f((h1 + i*h2) % bitCount) {
+			return false
+		}
+	}
+	return true
+}
+
+func (filter *bloomFilter) add(key regulationSetKey, userHash uint64) {
+	filter.positions(key, userHash, func(bit uint64) bool {
+		filter.bits[bit/64] |= 1 << (bit % 64)
+		return true
+	})
+}
+
+func (filter *bloomFilter) mayContain(key regulationSetKey, userHash uint64) bool {
+	return filter.positions(key, userHash, func(bit uint64) bool {
+		return filter.bits[bit/64]&(1<<(bit%64)) != 0
+	})
+}
+
+// mix64 is the splitmix64 finalizer, spreading the bits of hashes of similar keys
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+/*
+retainedRegulations keeps the last published regulations, which the next ones are diffed against. With RegulationStoreCompact,
+they are kept as compactRegulations instead of a full copy, and only expanded when they are diffed or asked for.
+*/
+type retainedRegulations struct {
+	full     RegulationsT
+	compact  *compactRegulations
+	compacts bool
+	// loaded is set once regulations have been retained, even empty ones
+	loaded bool
+}
+
+func newRetainedRegulations(setup BackendConfigSetup) retainedRegulations {
+	return retainedRegulations{compacts: setup.RegulationStore == RegulationStoreCompact}
+}
+
+func (retained *retainedRegulations) get() RegulationsT {
+	if retained.compact != nil {
+		return retained.compact.expand()
+	}
+	return retained.full
+}
+
+func (retained *retainedRegulations) set(regulations RegulationsT) {
+	retained.loaded = true
+	if retained.compacts {
+		retained.compact = newCompactRegulations(regulations)
+		return
+	}
+	retained.full = regulations
+}
+
+/*
+workspaceRegulationsPage returns the page of at most limit workspace regulations of workspaceID from start, or of every workspace
+if workspaceID is empty, paged the way WorkspaceRegulationsPage does. Only the regulations of the page are expanded.
+*/
+func (retained *retainedRegulations) workspaceRegulationsPage(workspaceID string, start int, limit int) WRegulationsT {
+	count, at := len(retained.full.WorkspaceRegulations), func(i int) WorkspaceRegulationT { return retained.full.WorkspaceRegulations[i] }
+	if retained.compact != nil {
+		count, at = len(retained.compact.workspaceRegulations), retained.compact.workspaceRegulation
+	}
+
+	regulations := make([]WorkspaceRegulationT, 0)
+	matched, end := 0, true
+	if workspaceID == "" {
+		// every regulation matches, the ones before start needn't be looked at
+		matched = start
+		if matched > count {
+			matched = count
+		}
+	}
+	for i := matched; i < count; i++ {
+		regulation := at(i)
+		if workspaceID != "" && regulation.WorkspaceID != workspaceID {
+			continue
+		}
+		if matched >= start+limit {
+			end = false
+			break
+		}
+		if matched >= start {
+			regulations = append(regulations, regulation)
+		}
+		matched++
+	}
+	return WRegulationsT{WorkspaceRegulations: regulations, Start: start, Limit: limit, Size: len(regulations), End: end, Next: pageNext(start, len(regulations), matched)}
+}
+
+// sourceRegulationsPage is workspaceRegulationsPage for source regulations
+func (retained *retainedRegulations) sourceRegulationsPage(workspaceID string, start int, limit int) SRegulationsT {
+	count, at := len(retained.full.SourceRegulations), func(i int) SourceRegulationT { return retained.full.SourceRegulations[i] }
+	if retained.compact != nil {
+		count, at = len(retained.compact.sourceRegulations), retained.compact.sourceRegulation
+	}
+
+	regulations := make([]SourceRegulationT, 0)
+	matched, end := 0, true
+	if workspaceID == "" {
+		matched = start
+		if matched > count {
+			matched = count
+		}
+	}
+	for i := matched; i < count; i++ {
+		regulation := at(i)
+		if workspaceID != "" && regulation.WorkspaceID != workspaceID {
+			continue
+		}
+		if matched >= start+limit {
+			end = false
+			break
+		}
+		if matched >= start {
+			regulations = append(regulations, regulation)
+		}
+		matched++
+	}
+	return SRegulationsT{SourceRegulations: regulations, Start: start, Limit: limit, Size: len(regulations), End: end, Next: pageNext(start, len(regulations), matched)}
+}
+
+// pageNext is the start of the page after size regulations from start, out of the matched regulations seen so far
+func pageNext(start int, size int, matched int) int {
+	if start+size > matched {
+		return matched
+	}
+	return start + size
+}
+
+// compactRegulation is a regulation whose workspace ID, source ID and type are indexes into compactRegulations.strings
+type compactRegulation struct {
+	id             string
+	userID         string
+	workspace      uint32
+	source         uint32
+	regulationType uint32
+}
+
+// compactRegulations holds regulations without repeating the workspace IDs, source IDs and types they share.
+// Its slices are nil when those of the regulations are, so that expanded regulations are deeply equal to the retained ones.
+type compactRegulations struct {
+	strings              []string
+	workspaceRegulations []compactRegulation
+	sourceRegulations    []compactRegulation
+}
+
+func newCompactRegulations(regulations RegulationsT) *compactRegulations {
+	compact := &compactRegulations{}
+	if regulations.WorkspaceRegulations != nil {
+		compact.workspaceRegulations = make([]compactRegulation, 0, len(regulations.WorkspaceRegulations))
+	}
+	if regulations.SourceRegulations != nil {
+		compact.sourceRegulations = make([]compactRegulation, 0, len(regulations.SourceRegulations))
+	}
+	indexes := make(map[string]uint32)
+	intern := func(s string) uint32 {
+		if index, ok := indexes[s]; ok {
+			return index
+		}
+		index := uint32(len(compact.strings))
+		indexes[s] = index
+		compact.strings = append(compact.strings, s)
+		return index
+	}
+	for _, regulation := range regulations.WorkspaceRegulations {
+		compact.workspaceRegulations = append(compact.workspaceRegulations, compactRegulation{
+			id:             regulation.ID,
+			userID:         regulation.UserID,
+			workspace:      intern(regulation.WorkspaceID),
+			regulationType: intern(string(regulation.RegulationType)),
+		})
+	}
+	for _, regulation := range regulations.SourceRegulations {
+		compact.sourceRegulations = append(compact.sourceRegulations, compactRegulation{
+			id:             regulation.ID,
+			userID:         regulation.UserID,
+			workspace:      intern(regulation.WorkspaceID),
+			source:         intern(regulation.SourceID),
+			regulationType: intern(string(regulation.RegulationType)),
+		})
+	}
+	return compact
+}
+
+func (compact *compactRegulations) expand() RegulationsT {
+	regulations := RegulationsT{}
+	if compact.workspaceRegulations != nil {
+		regulations.WorkspaceRegulations = make([]WorkspaceRegulationT, 0, len(compact.workspaceRegulations))
+	}
+	if compact.sourceRegulations != nil {
+		regulations.SourceRegulations = make([]SourceRegulationT, 0, len(compact.sourceRegulations))
+	}
+	for i := range compact.workspaceRegulations {
+		regulations.WorkspaceRegulations = append(regulations.WorkspaceRegulations, compact.workspaceRegulation(i))
+	}
+	for i := range compact.sourceRegulations {
+		regulations.SourceRegulations = append(regulations.SourceRegulations, compact.sourceRegulation(i))
+	}
+	return regulations
+}
+
+func (compact *compactRegulations) workspaceRegulation(i int) WorkspaceRegulationT {
+	regulation := compact.workspaceRegulations[i]
+	return WorkspaceRegulationT{
+		ID:             regulation.id,
+		RegulationType: Regulation(compact.strings[regulation.regulationType]),
+		WorkspaceID:    compact.strings[regulation.workspace],
+		UserID:         regulation.userID,
+	}
+}
+
+func (compact *compactRegulations) sourceRegulation(i int) SourceRegulationT {
+	regulation := compact.sourceRegulations[i]
+	return SourceRegulationT{
+		ID:             regulation.id,
+		RegulationType: Regulation(compact.strings[regulation.regulationType]),
+		WorkspaceID:    compact.strings[regulation.workspace],
+		SourceID:       compact.strings[regulation.source],
+		UserID:         regulation.userID,
+	}
+}
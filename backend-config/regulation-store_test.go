@@ -0,0 +1,244 @@
+package backendconfig
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// testLargeRegulations returns count source regulations of 10 sources of a workspace, with strings allocated one by one like decoded ones
+func testLargeRegulations(count int) RegulationsT {
+	regulations := RegulationsT{SourceRegulations: make([]SourceRegulationT, 0, count)}
+	for i := 0; i < count; i++ {
+		regulations.SourceRegulations = append(regulations.SourceRegulations, SourceRegulationT{
+			ID:             fmt.Sprintf("regulation-%d", i),
+			RegulationType: Regulation(fmt.Sprint(RegulationSuppress)),
+			WorkspaceID:    fmt.Sprintf("1tBTxXrm8mqzcFlqgFBiL4u4Fvy-%s", "workspace"),
+			SourceID:       fmt.Sprintf("1tBTxZxjBdnCVpLqZoKb4vtwD9G-source-%d", i%10),
+			UserID:         fmt.Sprintf("user-%d", i),
+		})
+	}
+	return regulations
+}
+
+func TestRegulationStores(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup BackendConfigSetup
+	}{
+		{name: "map", setup: BackendConfigSetup{RegulationStore: RegulationStoreMap}},
+		{name: "compact", setup: BackendConfigSetup{RegulationStore: RegulationStoreCompact}},
+		{name: "compact with bloom filter", setup: BackendConfigSetup{RegulationStore: RegulationStoreCompact, RegulationStoreBloomFilter: true}},
+		{name: "compact with a spill directory", setup: BackendConfigSetup{RegulationStore: RegulationStoreCompact, RegulationStoreSpillDir: t.TempDir()}},
+	}
+	previous := RegulationsT{
+		WorkspaceRegulations: []WorkspaceRegulationT{
+			{ID: "1", RegulationType: RegulationSuppress, WorkspaceID: "workspace-1", UserID: "user-1"},
+			{ID: "2", RegulationType: RegulationSuppressAndDelete, WorkspaceID: "workspace-1", UserID: "user-2"},
+		},
+		SourceRegulations: []SourceRegulationT{
+			{ID: "3", RegulationType: RegulationSuppress, WorkspaceID: "workspace-1", SourceID: "source-1", UserID: "user-3"},
+			{ID: "4", RegulationType: RegulationSuppress, WorkspaceID: "workspace-1", SourceID: "source-1", UserID: "user-4"},
+			{ID: "5", RegulationType: RegulationSuppress, WorkspaceID: "workspace-1", SourceID: "source-1", UserID: "user-4"},
+		},
+	}
+	current := RegulationsT{
+		WorkspaceRegulations: []WorkspaceRegulationT{previous.WorkspaceRegulations[0]},
+		SourceRegulations:    []SourceRegulationT{previous.SourceRegulations[0], previous.SourceRegulations[2]},
+	}
+	lookups := []struct {
+		workspaceID     string
+		sourceID        string
+		userID          string
+		wantPrevious    bool
+		wantCurrent     bool
+		wantDescription string
+	}{
+		{workspaceID: "workspace-1", sourceID: "source-2", userID: "user-1", wantPrevious: true, wantCurrent: true, wantDescription: "workspace regulation in any source"},
+		{workspaceID: "workspace-1", sourceID: "", userID: "user-2", wantPrevious: true, wantCurrent: false, wantDescription: "removed workspace regulation"},
+		{workspaceID: "workspace-1", sourceID: "source-1", userID: "user-3", wantPrevious: true, wantCurrent: true, wantDescription: "source regulation"},
+		{workspaceID: "workspace-1", sourceID: "source-2", userID: "user-3", wantPrevious: false, wantCurrent: false, wantDescription: "source regulation in another source"},
+		{workspaceID: "workspace-1", sourceID: "source-1", userID: "user-4", wantPrevious: true, wantCurrent: true, wantDescription: "user regulated twice, one regulation removed"},
+		{workspaceID: "workspace-2", sourceID: "source-1", userID: "user-1", wantPrevious: false, wantCurrent: false, wantDescription: "another workspace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewRegulationStore(tt.setup)
+			store.Apply(diffRegulations(RegulationsT{}, previous))
+			if store.Len() != 4 {
+				t.Errorf("got %d users, want 4", store.Len())
+			}
+			for _, lookup := range lookups {
+				if got := store.IsSuppressedUser(lookup.workspaceID, lookup.sourceID, lookup.userID); got != lookup.wantPrevious {
+					t.Errorf("%s: suppressed %t, want %t", lookup.wantDescription, got, lookup.wantPrevious)
+				}
+			}
+
+			store.Apply(diffRegulations(previous, current))
+			if store.Len() != 3 {
+				t.Errorf("got %d users after removals, want 3", store.Len())
+			}
+			for _, lookup := range lookups {
+				if got := store.IsSuppressedUser(lookup.workspaceID, lookup.sourceID, lookup.userID); got != lookup.wantCurrent {
+					t.Errorf("%s after removals: suppressed %t, want %t", lookup.wantDescription, got, lookup.wantCurrent)
+				}
+			}
+		})
+	}
+}
+
+func TestCompactUserSetSpill(t *testing.T) {
+	set := &compactUserSet{}
+	count := compactSetSpillSize + compactSetSpillBlockSize/2
+	// even hashes are in the set, odd ones aren't
+	for i := 1; i <= count; i++ {
+		set.add(uint64(i) * 2 * 0x9e3779b9)
+	}
+	if err := set.compact(filepath.Join(t.TempDir(), "1-1.hashes")); err != nil {
+		t.Fatal(err)
+	}
+	defer set.close()
+	if set.spilled == nil || set.sorted != nil {
+		t.Fatal("set wasn't spilled")
+	}
+	if want := (count + compactSetSpillBlockSize - 1) / compactSetSpillBlockSize; len(set.spillIndex) != want {
+		t.Errorf("got %d index entries, want %d", len(set.spillIndex), want)
+	}
+
+	tests := []struct {
+		name     string
+		userHash uint64
+		want     bool
+	}{
+		{name: "below the first hash", userHash: 0, want: false},
+		{name: "first hash", userHash: 2 * 0x9e3779b9, want: true},
+		{name: "first hash of a block", userHash: set.spillIndex[3], want: true},
+		{name: "last hash of a block", userHash: set.spillIndex[4] - 2*0x9e3779b9, want: true},
+		{name: "between blocks", userHash: set.spillIndex[4] - 0x9e3779b9, want: false},
+		{name: "last hash", userHash: uint64(count) * 2 * 0x9e3779b9, want: true},
+		{name: "above the last hash", userHash: uint64(count+1) * 2 * 0x9e3779b9, want: false},
+	}
+	for _, tt := range tests {
+		if got := set.contains(tt.userHash); got != tt.want {
+			t.Errorf("%s: contains %t, want %t", tt.name, got, tt.want)
+		}
+	}
+	if set.size() != count {
+		t.Errorf("got size %d, want %d", set.size(), count)
+	}
+}
+
+func TestRetainedRegulations(t *testing.T) {
+	tests := []struct {
+		name        string
+		regulations RegulationsT
+	}{
+		{name: "none"},
+		{name: "empty", regulations: RegulationsT{WorkspaceRegulations: []WorkspaceRegulationT{}, SourceRegulations: []SourceRegulationT{}}},
+		{name: "regulations", regulations: RegulationsT{
+			WorkspaceRegulations: []WorkspaceRegulationT{{ID: "1", RegulationType: RegulationSuppress, WorkspaceID: "workspace-1", UserID: "user-1"}},
+			SourceRegulations:    testLargeRegulations(3).SourceRegulations,
+		}},
+	}
+	for _, tt := range tests {
+		for _, store := range []string{RegulationStoreMap, RegulationStoreCompact} {
+			t.Run(tt.name+" "+store, func(t *testing.T) {
+				retained := newRetainedRegulations(BackendConfigSetup{RegulationStore: store})
+				if !reflect.DeepEqual(retained.get(), RegulationsT{}) {
+					t.Errorf("got %+v before any regulations were retained", retained.get())
+				}
+				retained.set(tt.regulations)
+				if (retained.compact != nil) != (store == RegulationStoreCompact) {
+					t.Errorf("compacted %t with the %s store", retained.compact != nil, store)
+				}
+				if got := retained.get(); !reflect.DeepEqual(got, tt.regulations) {
+					t.Errorf("got %+v, want %+v", got, tt.regulations)
+				}
+			})
+		}
+	}
+}
+
+func TestRetainedRegulationsPage(t *testing.T) {
+	regulations := RegulationsT{}
+	for i, workspaceID := range []string{"workspace-1", "workspace-2", "workspace-1", "workspace-1", "workspace-2"} {
+		regulations.WorkspaceRegulations = append(regulations.WorkspaceRegulations, WorkspaceRegulationT{ID: fmt.Sprint(i), RegulationType: RegulationSuppress, WorkspaceID: workspaceID, UserID: "user-1"})
+		regulations.SourceRegulations = append(regulations.SourceRegulations, SourceRegulationT{ID: fmt.Sprint(i), RegulationType: RegulationDelete, WorkspaceID: workspaceID, SourceID: "source-1", UserID: "user-1"})
+	}
+	for _, store := range []string{RegulationStoreMap, RegulationStoreCompact} {
+		retained := newRetainedRegulations(BackendConfigSetup{RegulationStore: store})
+		retained.set(regulations)
+		for _, workspaceID := range []string{"", "workspace-1", "workspace-2", "workspace-3"} {
+			workspaceRegulations, sourceRegulations := make([]WorkspaceRegulationT, 0), make([]SourceRegulationT, 0)
+			for i := range regulations.WorkspaceRegulations {
+				if workspaceID == "" || regulations.WorkspaceRegulations[i].WorkspaceID == workspaceID {
+					workspaceRegulations = append(workspaceRegulations, regulations.WorkspaceRegulations[i])
+					sourceRegulations = append(sourceRegulations, regulations.SourceRegulations[i])
+				}
+			}
+			// pages of the retained regulations are the pages of the filtered regulations
+			for start := 0; start <= len(regulations.WorkspaceRegulations)+1; start++ {
+				for limit := 1; limit <= 3; limit++ {
+					if got, want := retained.workspaceRegulationsPage(workspaceID, start, limit), WorkspaceRegulationsPage(workspaceRegulations, start, limit); !reflect.DeepEqual(got, want) {
+						t.Errorf("%s %q from %d by %d: got workspace page %+v, want %+v", store, workspaceID, start, limit, got, want)
+					}
+					if got, want := retained.sourceRegulationsPage(workspaceID, start, limit), SourceRegulationsPage(sourceRegulations, start, limit); !reflect.DeepEqual(got, want) {
+						t.Errorf("%s %q from %d by %d: got source page %+v, want %+v", store, workspaceID, start, limit, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// retainedHeapBytes returns the heap retained by what retain returns
+func retainedHeapBytes(retain func() interface{}) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	retained := retain()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(retained)
+	if after.HeapAlloc < before.HeapAlloc {
+		return 0
+	}
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+func TestCompactRetainedRegulationsSaveMemory(t *testing.T) {
+	const count = 100000
+	full := retainedHeapBytes(func() interface{} {
+		retained := newRetainedRegulations(BackendConfigSetup{RegulationStore: RegulationStoreMap})
+		retained.set(testLargeRegulations(count))
+		return &retained
+	})
+	compact := retainedHeapBytes(func() interface{} {
+		retained := newRetainedRegulations(BackendConfigSetup{RegulationStore: RegulationStoreCompact})
+		retained.set(testLargeRegulations(count))
+		return &retained
+	})
+	t.Logf("retained %d bytes per regulation in full, %d compacted", full/count, compact/count)
+	if compact > full*7/10 {
+		t.Errorf("compacted regulations retain %d bytes, want less than 70%% of the %d bytes of the full copy", compact, full)
+	}
+}
+
+func BenchmarkRetainedRegulations(b *testing.B) {
+	const count = 100000
+	for _, store := range []string{RegulationStoreMap, RegulationStoreCompact} {
+		b.Run(store, func(b *testing.B) {
+			var bytes uint64
+			for i := 0; i < b.N; i++ {
+				bytes += retainedHeapBytes(func() interface{} {
+					retained := newRetainedRegulations(BackendConfigSetup{RegulationStore: store})
+					retained.set(testLargeRegulations(count))
+					return &retained
+				})
+			}
+			b.ReportMetric(float64(bytes)/float64(b.N)/count, "retained-bytes/regulation")
+		})
+	}
+}
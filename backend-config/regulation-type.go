@@ -0,0 +1,100 @@
+package backendconfig
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+// knownRegulations are the regulation types the server knows the semantics of
+var knownRegulations = []Regulation{RegulationSuppress, RegulationDelete, RegulationSuppressAndDelete}
+
+/*
+ParseRegulation returns the known regulation type matching value regardless of case, e.g. "suppress_with_delete".
+Unknown values are returned as they are, with ok set to false.
+*/
+func ParseRegulation(value string) (regulation Regulation, ok bool) {
+	for _, known := range knownRegulations {
+		if strings.EqualFold(string(known), value) {
+			return known, true
+		}
+	}
+	return Regulation(value), false
+}
+
+/*
+UnmarshalJSON parses regulation types the way ParseRegulation does. Unknown types don't fail decoding, so that one of them can't hold
+back every other regulation. They are kept as fetched, neither suppress nor delete, and are reported by reportUnknownRegulations.
+*/
+func (regulation *Regulation) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*regulation, _ = ParseRegulation(value)
+	return nil
+}
+
+// Known reports whether regulation is exactly one of RegulationSuppress, RegulationDelete or RegulationSuppressAndDelete
+func (regulation Regulation) Known() bool {
+	for _, known := range knownRegulations {
+		if regulation == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Suppresses reports whether the events of the regulated user must be dropped
+func (regulation Regulation) Suppresses() bool {
+	return regulation == RegulationSuppress || regulation == RegulationSuppressAndDelete
+}
+
+// Deletes reports whether the data of the regulated user must be deleted from destinations
+func (regulation Regulation) Deletes() bool {
+	return regulation == RegulationDelete || regulation == RegulationSuppressAndDelete
+}
+
+/*
+reportUnknownRegulations logs the added regulations of changes whose type is unknown, which are ignored by consumers, once per type
+with their count. As types come from the config backend, they are counted under a single "unknown" tag to keep the stat bounded.
+*/
+func reportUnknownRegulations(changes RegulationChangesT) {
+	unknown := countUnknownRegulations(changes)
+	if len(unknown) == 0 {
+		return
+	}
+	regulationTypes := make([]string, 0, len(unknown))
+	total := 0
+	for regulationType, count := range unknown {
+		regulationTypes = append(regulationTypes, string(regulationType))
+		total += count
+	}
+	sort.Strings(regulationTypes)
+	for _, regulationType := range regulationTypes {
+		pkgLogger.Errorf("Ignoring %d regulations of unknown type %q", unknown[Regulation(regulationType)], regulationType)
+	}
+	stats.NewTaggedStat("config_backend.unknown_regulation_types", stats.CountType, map[string]string{"regulationType": "unknown"}).Count(total)
+}
+
+// countUnknownRegulations returns the number of added regulations of changes by unknown type
+func countUnknownRegulations(changes RegulationChangesT) map[Regulation]int {
+	unknown := make(map[Regulation]int)
+	for _, workspaceChanges := range changes.Workspaces {
+		for _, regulation := range workspaceChanges.Added {
+			if !regulation.RegulationType.Known() {
+				unknown[regulation.RegulationType]++
+			}
+		}
+		for _, sourceChanges := range workspaceChanges.Sources {
+			for _, regulation := range sourceChanges.Added {
+				if !regulation.RegulationType.Known() {
+					unknown[regulation.RegulationType]++
+				}
+			}
+		}
+	}
+	return unknown
+}
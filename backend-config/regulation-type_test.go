@@ -0,0 +1,86 @@
+package backendconfig
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseRegulation(t *testing.T) {
+	tests := []struct {
+		value          string
+		want           Regulation
+		wantOk         bool
+		wantSuppresses bool
+		wantDeletes    bool
+	}{
+		{value: "Suppress", want: RegulationSuppress, wantOk: true, wantSuppresses: true},
+		{value: "suppress", want: RegulationSuppress, wantOk: true, wantSuppresses: true},
+		{value: "DELETE", want: RegulationDelete, wantOk: true, wantDeletes: true},
+		{value: "suppress_with_delete", want: RegulationSuppressAndDelete, wantOk: true, wantSuppresses: true, wantDeletes: true},
+		{value: "anonymize", want: "anonymize", wantOk: false},
+		{value: "", want: "", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := ParseRegulation(tt.value)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("got %q, %t, want %q, %t", got, ok, tt.want, tt.wantOk)
+			}
+			if got.Known() != tt.wantOk || got.Suppresses() != tt.wantSuppresses || got.Deletes() != tt.wantDeletes {
+				t.Errorf("got known %t, suppresses %t, deletes %t", got.Known(), got.Suppresses(), got.Deletes())
+			}
+
+			var decoded Regulation
+			data, _ := json.Marshal(tt.value)
+			if err := json.Unmarshal(data, &decoded); err != nil || decoded != tt.want {
+				t.Errorf("decoded %q, %v, want %q", decoded, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountUnknownRegulations(t *testing.T) {
+	tests := []struct {
+		name        string
+		regulations RegulationsT
+		want        map[Regulation]int
+	}{
+		{
+			name: "known",
+			regulations: RegulationsT{
+				WorkspaceRegulations: []WorkspaceRegulationT{{ID: "1", RegulationType: RegulationSuppress, WorkspaceID: "workspace-1"}},
+				SourceRegulations:    []SourceRegulationT{{ID: "2", RegulationType: RegulationDelete, WorkspaceID: "workspace-1", SourceID: "source-1"}},
+			},
+			want: map[Regulation]int{},
+		},
+		{
+			name: "unknown counted by type across workspaces and sources",
+			regulations: RegulationsT{
+				WorkspaceRegulations: []WorkspaceRegulationT{
+					{ID: "1", RegulationType: "anonymize", WorkspaceID: "workspace-1"},
+					{ID: "2", RegulationType: "anonymize", WorkspaceID: "workspace-2"},
+					{ID: "3", RegulationType: RegulationSuppress, WorkspaceID: "workspace-2"},
+				},
+				SourceRegulations: []SourceRegulationT{
+					{ID: "4", RegulationType: "anonymize", WorkspaceID: "workspace-1", SourceID: "source-1"},
+					{ID: "5", RegulationType: "export", WorkspaceID: "workspace-1", SourceID: "source-2"},
+				},
+			},
+			want: map[Regulation]int{"anonymize": 3, "export": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := diffRegulations(RegulationsT{}, tt.regulations)
+			if got := countUnknownRegulations(changes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			// removed regulations aren't reported again
+			if got := countUnknownRegulations(diffRegulations(tt.regulations, RegulationsT{})); len(got) != 0 {
+				t.Errorf("got %v for removed regulations", got)
+			}
+			reportUnknownRegulations(changes)
+		})
+	}
+}
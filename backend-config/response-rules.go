@@ -0,0 +1,307 @@
+package backendconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+	"github.com/tidwall/gjson"
+)
+
+// ResponseClass is the outcome of a destination HTTP response
+type ResponseClass string
+
+const (
+	ResponseSuccess   ResponseClass = "success"
+	ResponseRetryable ResponseClass = "retryable"
+	ResponseAbortable ResponseClass = "abortable"
+	ResponseThrottled ResponseClass = "throttled"
+
+	// responseRulesStatusCodeKey matches the HTTP status code instead of a path of the response body
+	responseRulesStatusCodeKey = "statusCode"
+)
+
+// responseClassPrecedence is the order categories are matched in, so that a response matching several is classified the same every time
+var responseClassPrecedence = []ResponseClass{ResponseAbortable, ResponseThrottled, ResponseRetryable, ResponseSuccess}
+
+// responseRuleCache holds the rule sets compiled from the config of an instance, by destination definition ID
+type responseRuleCache struct {
+	lock sync.RWMutex
+	sets map[string]*compiledResponseRules
+}
+
+func newResponseRuleCache() *responseRuleCache {
+	return &responseRuleCache{sets: make(map[string]*compiledResponseRules)}
+}
+
+/*
+ResponseRuleSet classifies the responses of a destination, compiled from the responseRules of its definition:
+
+	{
+		"responseType": "JSON",
+		"rules": {
+			"abortable": [{"statusCode": "4xx", "error.code": ["invalid_key", "invalid_payload"]}],
+			"throttled": [{"error.code": "rate_limited"}],
+			"retryable": [{"statusCode": 500}]
+		}
+	}
+
+A category matches if any of its rules matches, and a rule matches if all its conditions match. Conditions are gjson paths of the
+response body, or statusCode which also accepts classes like "5xx", and expect a value or any of an array of values.
+Body conditions never match unless responseType is JSON. A response no rule matches is classified by its status code alone.
+*/
+type ResponseRuleSet struct {
+	responseType string
+	categories   map[ResponseClass][]responseRule
+}
+
+type responseRule []responseCondition
+
+type responseCondition struct {
+	path     string
+	expected []string
+}
+
+type compiledResponseRules struct {
+	raw     string
+	ruleSet *ResponseRuleSet
+	err     error
+}
+
+// CompileResponseRules compiles the responseRules of a destination definition. Empty rules compile to a nil rule set, which classifies by status code.
+func CompileResponseRules(rules map[string]interface{}) (*ResponseRuleSet, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	ruleSet := &ResponseRuleSet{categories: make(map[ResponseClass][]responseRule)}
+	if responseType, ok := rules["responseType"]; ok {
+		s, ok := responseType.(string)
+		if !ok {
+			return nil, fmt.Errorf("responseType must be a string, got %T", responseType)
+		}
+		ruleSet.responseType = strings.ToUpper(s)
+	}
+
+	categories, ok := rules["rules"].(map[string]interface{})
+	if !ok {
+		if _, declared := rules["rules"]; declared {
+			return nil, fmt.Errorf("rules must be an object, got %T", rules["rules"])
+		}
+		return ruleSet, nil
+	}
+	for category, declared := range categories {
+		class := ResponseClass(category)
+		if !isResponseClass(class) {
+			return nil, fmt.Errorf("unknown rule category %q", category)
+		}
+		declaredRules, ok := declared.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("rules.%s must be an array, got %T", category, declared)
+		}
+		for i, declaredRule := range declaredRules {
+			rule, err := compileResponseRule(declaredRule)
+			if err != nil {
+				return nil, fmt.Errorf("rules.%s[%d]: %w", category, i, err)
+			}
+			ruleSet.categories[class] = append(ruleSet.categories[class], rule)
+		}
+	}
+	return ruleSet, nil
+}
+
+func compileResponseRule(declared interface{}) (responseRule, error) {
+	conditions, ok := declared.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rule must be an object, got %T", declared)
+	}
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("rule has no conditions")
+	}
+
+	rule := make(responseRule, 0, len(conditions))
+	for path, value := range conditions {
+		expected, err := responseConditionValues(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if path == responseRulesStatusCodeKey {
+			for _, pattern := range expected {
+				if !isStatusCodePattern(pattern) {
+					return nil, fmt.Errorf("%s: %q is neither a status code nor a class like 5xx", path, pattern)
+				}
+			}
+		}
+		rule = append(rule, responseCondition{path: path, expected: expected})
+	}
+	// map iteration order is random, keep conditions in a stable order
+	sort.Slice(rule, func(i, j int) bool { return rule[i].path < rule[j].path })
+	return rule, nil
+}
+
+// responseConditionValues converts the expected value of a condition, or an array of them, to strings
+func responseConditionValues(value interface{}) ([]string, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("no expected values")
+	}
+
+	expected := make([]string, 0, len(values))
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			expected = append(expected, v)
+		case bool:
+			expected = append(expected, strconv.FormatBool(v))
+		case float64:
+			expected = append(expected, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return nil, fmt.Errorf("expected value must be a string, number or boolean, got %T", v)
+		}
+	}
+	return expected, nil
+}
+
+// Classify returns the class of a destination response. It is safe to call on a nil rule set.
+func (ruleSet *ResponseRuleSet) Classify(statusCode int, body []byte) ResponseClass {
+	if ruleSet != nil {
+		for _, class := range responseClassPrecedence {
+			for _, rule := range ruleSet.categories[class] {
+				if ruleSet.matches(rule, statusCode, body) {
+					return class
+				}
+			}
+		}
+	}
+	return classifyStatusCode(statusCode)
+}
+
+func (ruleSet *ResponseRuleSet) matches(rule responseRule, statusCode int, body []byte) bool {
+	for _, condition := range rule {
+		if condition.path == responseRulesStatusCodeKey {
+			if !matchesAnyStatusCode(condition.expected, statusCode) {
+				return false
+			}
+			continue
+		}
+		if ruleSet.responseType != "JSON" {
+			return false
+		}
+		result := gjson.GetBytes(body, condition.path)
+		if !result.Exists() || !containsString(condition.expected, result.String()) {
+			return false
+		}
+	}
+	return true
+}
+
+func classifyStatusCode(statusCode int) ResponseClass {
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return ResponseSuccess
+	case statusCode == 429:
+		return ResponseThrottled
+	case statusCode >= 400 && statusCode < 500:
+		return ResponseAbortable
+	default:
+		return ResponseRetryable
+	}
+}
+
+func matchesAnyStatusCode(patterns []string, statusCode int) bool {
+	code := strconv.Itoa(statusCode)
+	for _, pattern := range patterns {
+		if pattern == code || (len(code) == 3 && strings.EqualFold(pattern, code[:1]+"xx")) {
+			return true
+		}
+	}
+	return false
+}
+
+func isStatusCodePattern(pattern string) bool {
+	if len(pattern) != 3 || pattern[0] < '1' || pattern[0] > '5' {
+		return false
+	}
+	if strings.EqualFold(pattern[1:], "xx") {
+		return true
+	}
+	_, err := strconv.Atoi(pattern)
+	return err == nil
+}
+
+func isResponseClass(class ResponseClass) bool {
+	for _, known := range responseClassPrecedence {
+		if class == known {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+compile compiles the responseRules of the destination definitions used by config.
+Definitions whose rules are unchanged since the last compilation keep their rule set, and definitions no longer used are dropped.
+A definition whose rules don't compile is classified by status code alone until they are fixed.
+*/
+func (cache *responseRuleCache) compile(config ConfigT) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	compiled := make(map[string]*compiledResponseRules)
+	for _, source := range config.Sources {
+		for _, destination := range source.Destinations {
+			definition := destination.DestinationDefinition
+			if _, ok := compiled[definition.ID]; ok {
+				continue
+			}
+			raw, _ := json.Marshal(definition.ResponseRules)
+			if previous, ok := cache.sets[definition.ID]; ok && previous.raw == string(raw) {
+				compiled[definition.ID] = previous
+				continue
+			}
+
+			ruleSet, err := CompileResponseRules(definition.ResponseRules)
+			if err != nil {
+				pkgLogger.Errorf("Invalid responseRules of destination definition %s (%s): %s", definition.ID, definition.Name, err.Error())
+				stats.NewTaggedStat("config_backend.response_rules_errors", stats.CountType, map[string]string{"destType": definition.Name}).Increment()
+			}
+			compiled[definition.ID] = &compiledResponseRules{raw: string(raw), ruleSet: ruleSet, err: err}
+		}
+	}
+	cache.sets = compiled
+}
+
+// GetResponseRules returns the compiled responseRules of a destination definition, nil if it has none or they are invalid
+func GetResponseRules(destinationDefinitionID string) *ResponseRuleSet {
+	return defaultInstance.GetResponseRules(destinationDefinitionID)
+}
+
+// GetResponseRules returns the compiled responseRules of a destination definition in the config of the instance
+func (instance *Instance) GetResponseRules(destinationDefinitionID string) *ResponseRuleSet {
+	return instance.responseRules.get(destinationDefinitionID)
+}
+
+func (cache *responseRuleCache) get(destinationDefinitionID string) *ResponseRuleSet {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	if compiled, ok := cache.sets[destinationDefinitionID]; ok {
+		return compiled.ruleSet
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package backendconfig
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// testResponseRulesConfig returns a config whose destination definition classifies statusCode as retryable and uses transformationVersionID, if set
+func testResponseRulesConfig(statusCode int, transformationVersionID string) ConfigT {
+	config := testSourcesConfig("destination-1")
+	destination := &config.Sources[0].Destinations[0]
+	destination.DestinationDefinition.ResponseRules = map[string]interface{}{
+		"rules": map[string]interface{}{"retryable": []interface{}{map[string]interface{}{"statusCode": float64(statusCode)}}},
+	}
+	if transformationVersionID != "" {
+		destination.Transformations = []TransformationT{{VersionID: transformationVersionID}}
+	}
+	return config
+}
+
+func TestResponseRulesCompiledOnlyWhenPublished(t *testing.T) {
+	backend := newTestConfigBackend(t, testResponseRulesConfig(418, ""))
+	instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+		setup.TransformationCacheDir = t.TempDir()
+	})
+	if changed, ok := instance.configUpdate(testStatConfigBackendError); !changed || !ok {
+		t.Fatalf("first config not published: changed %t, ok %t", changed, ok)
+	}
+	if class := instance.GetResponseRules("definition-1").Classify(418, nil); class != ResponseRetryable {
+		t.Fatalf("got %s for 418 with the first rules", class)
+	}
+
+	// the new config is held back while its transformation can't be fetched, along with its rules
+	backend.setConfig(testResponseRulesConfig(429, "transformation-1"))
+	backend.setCodeStatusCode(http.StatusInternalServerError)
+	if changed, ok := instance.configUpdate(testStatConfigBackendError); changed || ok {
+		t.Fatalf("config published although its transformation couldn't be fetched: changed %t, ok %t", changed, ok)
+	}
+	if class := instance.GetResponseRules("definition-1").Classify(418, nil); class != ResponseRetryable {
+		t.Errorf("got %s for 418 while the new config is held back, rules of the held back config were compiled", class)
+	}
+
+	backend.setCodeStatusCode(0)
+	backend.setCode("transformation-1", `{"code": "export function transformEvent(event) { return event; }"}`)
+	if changed, ok := instance.configUpdate(testStatConfigBackendError); !changed || !ok {
+		t.Fatalf("config not published once its transformation is fetched: changed %t, ok %t", changed, ok)
+	}
+	if class := instance.GetResponseRules("definition-1").Classify(429, nil); class != ResponseRetryable {
+		t.Errorf("got %s for 429 after publishing the new rules", class)
+	}
+}
+
+func decodeResponseRules(t *testing.T, rules string) map[string]interface{} {
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(rules), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestCompileResponseRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		rules      string
+		wantErr    bool
+		wantNilSet bool
+	}{
+		{name: "none", rules: `{}`, wantNilSet: true},
+		{name: "null", rules: `null`, wantNilSet: true},
+		{name: "without rules", rules: `{"responseType": "JSON"}`},
+		{name: "valid", rules: `{"responseType": "json", "rules": {"abortable": [{"statusCode": "4xx", "error.code": ["invalid_key", 1, true]}], "retryable": [{"statusCode": 500}]}}`},
+		{name: "responseType not a string", rules: `{"responseType": 1}`, wantErr: true},
+		{name: "rules not an object", rules: `{"rules": []}`, wantErr: true},
+		{name: "unknown category", rules: `{"rules": {"ignored": [{"statusCode": 500}]}}`, wantErr: true},
+		{name: "category not an array", rules: `{"rules": {"retryable": {"statusCode": 500}}}`, wantErr: true},
+		{name: "rule not an object", rules: `{"rules": {"retryable": ["500"]}}`, wantErr: true},
+		{name: "rule without conditions", rules: `{"rules": {"retryable": [{}]}}`, wantErr: true},
+		{name: "no expected values", rules: `{"rules": {"retryable": [{"error.code": []}]}}`, wantErr: true},
+		{name: "object as expected value", rules: `{"rules": {"retryable": [{"error.code": {"a": 1}}]}}`, wantErr: true},
+		{name: "status code out of range", rules: `{"rules": {"retryable": [{"statusCode": 600}]}}`, wantErr: true},
+		{name: "status code not a number", rules: `{"rules": {"retryable": [{"statusCode": "5x1"}]}}`, wantErr: true},
+		{name: "status code class of two digits", rules: `{"rules": {"retryable": [{"statusCode": "5x"}]}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleSet, err := CompileResponseRules(decodeResponseRules(t, tt.rules))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && (ruleSet == nil) != tt.wantNilSet {
+				t.Errorf("got rule set %+v, want nil %t", ruleSet, tt.wantNilSet)
+			}
+		})
+	}
+}
+
+func TestResponseRuleSetClassify(t *testing.T) {
+	const rules = `{
+		"responseType": "JSON",
+		"rules": {
+			"success": [{"error.code": "duplicate"}],
+			"abortable": [{"statusCode": "4xx", "error.code": ["invalid_key", "invalid_payload"]}, {"statusCode": 503, "retry": false}],
+			"throttled": [{"statusCode": ["4XX", "5xx"], "error.code": "rate_limited"}],
+			"retryable": [{"statusCode": 418}, {"statusCode": "5xx"}]
+		}
+	}`
+	ruleSet, err := CompileResponseRules(decodeResponseRules(t, rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	textRuleSet, err := CompileResponseRules(decodeResponseRules(t, `{"responseType": "TEXT", "rules": {"abortable": [{"error.code": "invalid_key"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		ruleSet    *ResponseRuleSet
+		statusCode int
+		body       string
+		want       ResponseClass
+	}{
+		{name: "nil rule set success", statusCode: 200, want: ResponseSuccess},
+		{name: "nil rule set too many requests", statusCode: 429, want: ResponseThrottled},
+		{name: "nil rule set client error", statusCode: 404, want: ResponseAbortable},
+		{name: "nil rule set server error", statusCode: 502, want: ResponseRetryable},
+		{name: "nil rule set without a response", statusCode: 0, want: ResponseRetryable},
+
+		{name: "exact status code", ruleSet: ruleSet, statusCode: 418, want: ResponseRetryable},
+		{name: "status code class", ruleSet: ruleSet, statusCode: 502, want: ResponseRetryable},
+		{name: "status code class doesn't match another class", ruleSet: ruleSet, statusCode: 404, want: ResponseAbortable},
+		{name: "status code class doesn't match codes that aren't 3 digits", ruleSet: ruleSet, statusCode: 5000, want: ResponseRetryable},
+		{name: "all conditions match", ruleSet: ruleSet, statusCode: 400, body: `{"error": {"code": "invalid_key"}}`, want: ResponseAbortable},
+		{name: "a condition doesn't match", ruleSet: ruleSet, statusCode: 500, body: `{"error": {"code": "invalid_key"}}`, want: ResponseRetryable},
+		{name: "boolean body value", ruleSet: ruleSet, statusCode: 503, body: `{"retry": false}`, want: ResponseAbortable},
+		{name: "missing body path", ruleSet: ruleSet, statusCode: 503, body: `{}`, want: ResponseRetryable},
+
+		// a response matching several categories gets the first of abortable, throttled, retryable and success
+		{name: "abortable before retryable", ruleSet: ruleSet, statusCode: 503, body: `{"retry": false, "error": {"code": "rate_limited"}}`, want: ResponseAbortable},
+		{name: "throttled before retryable", ruleSet: ruleSet, statusCode: 503, body: `{"error": {"code": "rate_limited"}}`, want: ResponseThrottled},
+		{name: "retryable before success", ruleSet: ruleSet, statusCode: 500, body: `{"error": {"code": "duplicate"}}`, want: ResponseRetryable},
+		{name: "success rule overrides the status code", ruleSet: ruleSet, statusCode: 409, body: `{"error": {"code": "duplicate"}}`, want: ResponseSuccess},
+
+		{name: "body that isn't JSON", ruleSet: ruleSet, statusCode: 400, body: `invalid_key`, want: ResponseAbortable},
+		{name: "body that isn't JSON falls back to the status code", ruleSet: ruleSet, statusCode: 200, body: `<html>error.code</html>`, want: ResponseSuccess},
+		{name: "truncated JSON body", ruleSet: ruleSet, statusCode: 409, body: `{"error": {"code": "dupl`, want: ResponseAbortable},
+		{name: "body conditions need a JSON responseType", ruleSet: textRuleSet, statusCode: 200, body: `{"error": {"code": "invalid_key"}}`, want: ResponseSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ruleSet.Classify(tt.statusCode, []byte(tt.body)); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package backendconfig
+
+//go:generate go run ../cmd/generate-config-schema -output workspace-config.schema.json
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// requiredConfigFields lists the fields the control plane is expected to always send, by Go field name
+var requiredConfigFields = map[reflect.Type][]string{
+	reflect.TypeOf(ConfigT{}):                {"Sources"},
+	reflect.TypeOf(SourceT{}):                {"ID", "Name", "SourceDefinition", "Enabled", "WriteKey", "Destinations"},
+	reflect.TypeOf(SourceDefinitionT{}):      {"ID", "Name"},
+	reflect.TypeOf(DestinationT{}):           {"ID", "Name", "DestinationDefinition", "Config", "Enabled", "IsProcessorEnabled"},
+	reflect.TypeOf(DestinationDefinitionT{}): {"ID", "Name", "DisplayName", "Config"},
+	reflect.TypeOf(TransformationT{}):        {"VersionID"},
+	reflect.TypeOf(LibraryT{}):               {"VersionID"},
+	reflect.TypeOf(StorageBucketT{}):         {"Type", "Bucket"},
+}
+
+/*
+GenerateConfigSchema returns a JSON Schema (draft-07) describing the ConfigT document served by the config backend.
+Fields without a json tag are named in the camelCase used by the control plane, e.g. WriteKey is "writeKey" and VersionID is "versionId".
+The schema is checked in as workspace-config.schema.json, regenerate it with go generate when these types change.
+*/
+func GenerateConfigSchema() map[string]interface{} {
+	definitions := make(map[string]interface{})
+	root := schemaForType(reflect.TypeOf(ConfigT{}), definitions)
+	schema := map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "Rudder workspace config",
+		"definitions": definitions,
+	}
+	for key, value := range root {
+		schema[key] = value
+	}
+	return schema
+}
+
+func schemaForType(typ reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		if typ == reflect.TypeOf(time.Time{}) {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if typ == reflect.TypeOf(ConfigT{}) {
+			return structSchema(typ, definitions)
+		}
+		if _, ok := definitions[typ.Name()]; !ok {
+			// reserve the name first so that recursive types terminate
+			definitions[typ.Name()] = map[string]interface{}{}
+			definitions[typ.Name()] = structSchema(typ, definitions)
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + typ.Name()}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": schemaForType(typ.Elem(), definitions)}
+	case reflect.Map:
+		if typ.Elem().Kind() == reflect.Interface {
+			return map[string]interface{}{"type": []string{"object", "null"}}
+		}
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": schemaForType(typ.Elem(), definitions)}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(typ reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, ok := configFieldJSONName(field)
+		if !ok {
+			continue
+		}
+		properties[name] = schemaForType(field.Type, definitions)
+	}
+
+	required := make([]string, 0)
+	for _, fieldName := range requiredConfigFields[typ] {
+		if field, ok := typ.FieldByName(fieldName); ok {
+			name, _ := configFieldJSONName(field)
+			required = append(required, name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// configFieldJSONName returns the name the control plane uses for a field, or false if the field isn't decoded
+func configFieldJSONName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	if tag := field.Tag.Get("json"); tag != "" {
+		tagName := strings.Split(tag, ",")[0]
+		if tagName == "-" {
+			return "", false
+		}
+		if tagName != "" {
+			return tagName, true
+		}
+	}
+
+	name := field.Name
+	if name == "ID" {
+		return "id", true
+	}
+	if strings.HasSuffix(name, "ID") {
+		name = strings.TrimSuffix(name, "ID") + "Id"
+	}
+	return strings.ToLower(name[:1]) + name[1:], true
+}
@@ -0,0 +1,98 @@
+package backendconfig
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+// statusReportTimeout bounds a status report along with its retries, a report that takes longer is outdated anyway
+const statusReportTimeout = 10 * time.Second
+
+// DataPlaneStatusT is posted to the config backend by the status reporter, so that replicas running stale config can be spotted
+type DataPlaneStatusT struct {
+	InstanceID         string                 `json:"instanceId"`
+	Mode               string                 `json:"mode"`
+	ConfigVersion      string                 `json:"configVersion"`
+	LastSync           string                 `json:"lastSync"`
+	LastRegulationSync string                 `json:"lastRegulationSync"`
+	WorkspaceErrors    map[string]*SyncErrorT `json:"workspaceErrors"`
+	// SyncError is the most recent of the config and regulation errors of the instance, e.g. a failed fetch of every hosted workspace
+	SyncError  *SyncErrorT `json:"syncError,omitempty"`
+	ReportedAt time.Time   `json:"reportedAt"`
+}
+
+/*
+currentDataPlaneStatus collects the status to report. Config and regulation errors are reported as SyncError, and in single
+workspace mode also belong to the one workspace.
+*/
+func (instance *Instance) currentDataPlaneStatus() DataPlaneStatusT {
+	isMultiWorkspace := instance.setup.IsMultiWorkspace
+	status := DataPlaneStatusT{
+		InstanceID:      instance.instanceID,
+		Mode:            "single-workspace",
+		ConfigVersion:   instance.GetConfigVersion(),
+		WorkspaceErrors: make(map[string]*SyncErrorT),
+		ReportedAt:      time.Now(),
+	}
+	if isMultiWorkspace {
+		status.Mode = "multi-workspace"
+	} else if instance.setup.ConfigFromFile {
+		status.Mode = "file"
+	}
+
+	status.LastSync, status.LastRegulationSync = instance.GetLastSync()
+
+	workspaceID := ""
+	if !isMultiWorkspace {
+		workspaceID = instance.GetConfig().WorkspaceID
+	}
+
+	poller := instance.poller
+	poller.lock.RLock()
+	for id, syncErr := range poller.workspaceSyncErrors {
+		status.WorkspaceErrors[id] = syncErr
+	}
+	// the most recent of the config and regulation errors
+	status.SyncError = poller.lastConfigError
+	if poller.lastRegulationsError != nil && (poller.lastConfigError == nil || poller.lastRegulationsError.Time.After(poller.lastConfigError.Time)) {
+		status.SyncError = poller.lastRegulationsError
+	}
+	if !isMultiWorkspace && status.SyncError != nil {
+		status.WorkspaceErrors[workspaceID] = status.SyncError
+	}
+	poller.lock.RUnlock()
+	return status
+}
+
+// reportStatus posts the current status once, without spooling as an outdated status is of no use
+func (instance *Instance) reportStatus(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, statusReportTimeout)
+	defer cancel()
+	_, _, err := instance.MakeBackendPostRequestWithContext(ctx, instance.setup.StatusReportEndpoint, instance.currentDataPlaneStatus(), PostRequestOptions{Idempotent: true})
+	return err
+}
+
+func (instance *Instance) pollStatusReports() {
+	statStatusReportError := stats.NewStat("config_backend.status_report_errors", stats.CountType)
+	for {
+		if err := instance.reportStatus(context.Background()); err != nil {
+			statStatusReportError.Increment()
+			pkgLogger.Errorf("ConfigBackend: Failed to report data plane status, Error: %s", err.Error())
+		}
+		if !instance.sleep(instance.setup.StatusReportInterval) {
+			return
+		}
+	}
+}
+
+// defaultInstanceID identifies replicas by host name, which is unique per pod in kubernetes
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
@@ -0,0 +1,143 @@
+package backendconfig
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestReportStatus(t *testing.T) {
+	tests := []struct {
+		name             string
+		isMultiWorkspace bool
+		// failConfig makes the config backend fail after the first config was published
+		failConfig bool
+		// workspaceErrors are recorded for hosted workspaces before reporting
+		workspaceErrors    map[string]string
+		wantMode           string
+		wantWorkspaceIDs   []string
+		wantSyncError      string
+		wantWorkspaceError string
+	}{
+		{
+			name:             "single workspace",
+			wantMode:         "single-workspace",
+			wantWorkspaceIDs: []string{},
+		},
+		{
+			name:               "single workspace failing",
+			failConfig:         true,
+			wantMode:           "single-workspace",
+			wantWorkspaceIDs:   []string{"workspace-1"},
+			wantSyncError:      "failed to fetch workspace config",
+			wantWorkspaceError: "failed to fetch workspace config",
+		},
+		{
+			name:             "multi workspace",
+			isMultiWorkspace: true,
+			workspaceErrors:  map[string]string{"workspace-2": "failed to fetch source regulations"},
+			wantMode:         "multi-workspace",
+			wantWorkspaceIDs: []string{"workspace-2"},
+		},
+		{
+			name:             "multi workspace failing",
+			isMultiWorkspace: true,
+			failConfig:       true,
+			workspaceErrors:  map[string]string{"workspace-2": "failed to fetch source regulations"},
+			wantMode:         "multi-workspace",
+			wantWorkspaceIDs: []string{"workspace-2"},
+			wantSyncError:    "failed to fetch workspace config",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestConfigBackend(t, testSourcesConfig("destination-1"))
+			instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+				setup.IsMultiWorkspace = tt.isMultiWorkspace
+				setup.InstanceID = "instance-1"
+				setup.StatusReportInterval = time.Second
+			})
+			if _, ok := instance.configUpdate(testStatConfigBackendError); !ok {
+				t.Fatal("first config not published")
+			}
+			if tt.failConfig {
+				backend.setStatusCode(http.StatusInternalServerError)
+				if _, ok := instance.configUpdate(testStatConfigBackendError); ok {
+					t.Fatal("config update succeeded while the backend fails")
+				}
+				backend.setStatusCode(http.StatusOK)
+			}
+			for workspaceID, err := range tt.workspaceErrors {
+				instance.poller.recordWorkspaceSyncError(workspaceID, err)
+			}
+
+			if err := instance.reportStatus(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			reports := backend.getStatusReports()
+			if len(reports) != 1 {
+				t.Fatalf("got %d status reports, want 1", len(reports))
+			}
+			report := reports[0]
+
+			lastSync, lastRegulationSync := instance.GetLastSync()
+			if report.InstanceID != "instance-1" || report.Mode != tt.wantMode || report.ConfigVersion != instance.GetConfigVersion() || report.ConfigVersion == "" {
+				t.Errorf("unexpected report %+v", report)
+			}
+			if report.LastSync != lastSync || report.LastSync == "" || report.LastRegulationSync != lastRegulationSync {
+				t.Errorf("got last syncs %q and %q, want %q and %q", report.LastSync, report.LastRegulationSync, lastSync, lastRegulationSync)
+			}
+
+			workspaceIDs := make([]string, 0, len(report.WorkspaceErrors))
+			for workspaceID := range report.WorkspaceErrors {
+				workspaceIDs = append(workspaceIDs, workspaceID)
+			}
+			sort.Strings(workspaceIDs)
+			if !reflect.DeepEqual(workspaceIDs, tt.wantWorkspaceIDs) {
+				t.Errorf("got errors of workspaces %v, want %v", workspaceIDs, tt.wantWorkspaceIDs)
+			}
+			for workspaceID, err := range tt.workspaceErrors {
+				if got := report.WorkspaceErrors[workspaceID]; got == nil || got.Error != err {
+					t.Errorf("got error %+v of workspace %s, want %q", got, workspaceID, err)
+				}
+			}
+			if tt.wantWorkspaceError != "" {
+				if got := report.WorkspaceErrors["workspace-1"]; got == nil || got.Error != tt.wantWorkspaceError {
+					t.Errorf("got error %+v of workspace-1, want %q", got, tt.wantWorkspaceError)
+				}
+			}
+
+			if tt.wantSyncError == "" && report.SyncError != nil {
+				t.Errorf("got sync error %+v, want none", report.SyncError)
+			}
+			if tt.wantSyncError != "" && (report.SyncError == nil || report.SyncError.Error != tt.wantSyncError) {
+				t.Errorf("got sync error %+v, want %q", report.SyncError, tt.wantSyncError)
+			}
+		})
+	}
+}
+
+func TestStatusReportSetup(t *testing.T) {
+	backend := newTestConfigBackend(t, testSourcesConfig("destination-1"))
+	instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+		setup.StatusReportInterval = time.Second
+		setup.StatusReportEndpoint = ""
+	})
+	if instance.setup.StatusReportInterval != 0 {
+		t.Errorf("got status report interval %v without an endpoint, want reporting disabled", instance.setup.StatusReportInterval)
+	}
+
+	// an interval shorter than the request doesn't time the report out
+	instance = newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+		setup.StatusReportInterval = time.Nanosecond
+	})
+	if err := instance.reportStatus(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if reports := backend.getStatusReports(); len(reports) != 1 {
+		t.Errorf("got %d status reports, want 1", len(reports))
+	}
+}
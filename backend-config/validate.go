@@ -0,0 +1,403 @@
+package backendconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+const (
+	// IssueUnknownField is a field the config types have no field for, which json.Unmarshal silently drops
+	IssueUnknownField = "unknown_field"
+	// IssueMissingField is a required field that is absent, which json.Unmarshal silently zeroes
+	IssueMissingField = "missing_field"
+	// IssueIntegrity is a broken reference or duplicate within the config
+	IssueIntegrity = "integrity"
+	// IssueResponseRules is a destination definition whose responseRules don't compile
+	IssueResponseRules = "response_rules"
+)
+
+// ValidationIssueT is a problem found in a workspace config. Line and Column are 1-based and zero when the issue has no location.
+type ValidationIssueT struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (issue ValidationIssueT) String() string {
+	if issue.Line > 0 {
+		return fmt.Sprintf("%d:%d: %s: %s", issue.Line, issue.Column, issue.Path, issue.Message)
+	}
+	return fmt.Sprintf("%s: %s", issue.Path, issue.Message)
+}
+
+// ConfigDiffT is a source or destination that differs between two configs
+type ConfigDiffT struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Change string `json:"change"`
+}
+
+/*
+CheckConfigFields decodes a ConfigT JSON document strictly. It reports every field json.Unmarshal would silently drop
+and every required field, as listed in the generated schema, that is missing.
+*/
+func CheckConfigFields(data []byte) ([]ValidationIssueT, error) {
+	return checkFields(data, reflect.TypeOf(ConfigT{}))
+}
+
+func checkFields(data []byte, typ reflect.Type) ([]ValidationIssueT, error) {
+	walker := &jsonFieldWalker{data: data, issues: make([]ValidationIssueT, 0)}
+	if err := walker.walk(bytes.TrimSpace(data), len(data)-len(bytes.TrimLeft(data, " \t\r\n")), typ, ""); err != nil {
+		return nil, describeJSONError(data, err)
+	}
+	return walker.issues, nil
+}
+
+// maxLoggedValidationWarnings caps the warnings logged per config, so that a renamed field doesn't flood the logs for every source
+const maxLoggedValidationWarnings = 20
+
+/*
+configFieldChecker reports the field issues of fetched configs with reportConfigFieldIssues, checking a config again only
+once its content changed, so that an unchanged config doesn't pay for the check and repeat its warnings on every poll.
+A nil checker checks nothing, it is only created with StrictConfigDecoding.
+*/
+type configFieldChecker struct {
+	lock sync.Mutex
+	// checkedHashes holds the hash of the last config checked by source and origin
+	checkedHashes map[string][sha256.Size]byte
+}
+
+func newConfigFieldChecker(strict bool) *configFieldChecker {
+	if !strict {
+		return nil
+	}
+	return &configFieldChecker{checkedHashes: make(map[string][sha256.Size]byte)}
+}
+
+// check reports the field issues of data fetched from source for origin, unless data didn't change since it was last checked. It returns whether data was checked.
+func (checker *configFieldChecker) check(source string, origin string, data []byte) bool {
+	if checker == nil {
+		return false
+	}
+	key, hash := source+"/"+origin, sha256.Sum256(data)
+	checker.lock.Lock()
+	checked, ok := checker.checkedHashes[key]
+	checker.checkedHashes[key] = hash
+	checker.lock.Unlock()
+	if ok && checked == hash {
+		return false
+	}
+	reportConfigFieldIssues(source, origin, data)
+	return true
+}
+
+// reportConfigFieldIssues logs the unknown and missing required fields of a config fetched from source and counts them by kind
+func reportConfigFieldIssues(source string, origin string, data []byte) {
+	issues, err := CheckConfigFields(data)
+	if err != nil {
+		// json.Unmarshal reports the error itself
+		return
+	}
+
+	counts := make(map[string]int)
+	for i, issue := range issues {
+		counts[issue.Kind]++
+		if i < maxLoggedValidationWarnings {
+			pkgLogger.Warnf("[[ Workspace-config ]] Config from %s does not match the schema: %s", origin, issue.String())
+		}
+	}
+	if len(issues) > maxLoggedValidationWarnings {
+		pkgLogger.Warnf("[[ Workspace-config ]] Config from %s has %d more schema warnings", origin, len(issues)-maxLoggedValidationWarnings)
+	}
+	for kind, count := range counts {
+		stats.NewTaggedStat("config_backend.validation_warnings", stats.CountType, map[string]string{"source": source, "kind": kind}).Count(count)
+	}
+}
+
+/*
+ValidateConfig checks the referential integrity of a config:
+sources without ID, write key or definition, write keys and source IDs used more than once,
+destinations without ID or definition or attached twice to the same source, responseRules that don't compile,
+invalid destination schedules and workspace settings.
+*/
+func ValidateConfig(config ConfigT) []ValidationIssueT {
+	issues := make([]ValidationIssueT, 0)
+	sourceIDs := make(map[string]string)
+	writeKeys := make(map[string]string)
+	checkedDefinitions := make(map[string]bool)
+
+	for i, source := range config.Sources {
+		path := fmt.Sprintf("sources[%d]", i)
+		if source.ID == "" {
+			issues = append(issues, ValidationIssueT{Kind: IssueIntegrity, Path: path, Message: "source has no id"})
+		} else if otherPath, ok := sourceIDs[source.ID]; ok {
+			issues = append(issues, ValidationIssueT{Kind: IssueIntegrity, Path: path, Message: fmt.Sprintf("duplicate source id %s, also used by %s", source.ID, otherPath)})
+		} else {
+			sourceIDs[source.ID] = path
+		}
+
+		if source.WriteKey == "" {
+			issues = append(issues, ValidationIssueT{Kind: IssueIntegrity, Path: path, Message: "source has no write key"})
+		} else if otherPath, ok := writeKeys[source.WriteKey]; ok {
+			issues = append(issues, ValidationIssueT{Kind: IssueIntegrity, Path: path, Message: fmt.Sprintf("duplicate write key, also used by %s", otherPath)})
+		} else {
+			writeKeys[source.WriteKey] = path
+		}
+
+		if source.SourceDefinition.ID == "" && source.SourceDefinition.Name == "" {
+			issues = append(issues, ValidationIssueT{Kind: IssueIntegrity, Path: path, Message: "source has no source definition"})
+		}
+
+		destinationIDs := make(map[string]bool)
+		for j, destination := range source.Destinations {
+			destinationPath := fmt.Sprintf("%s.destinations[%d]", path, j)
+			if destination.ID == "" {
+				issues = append(issues, ValidationIssueT{Kind: IssueIntegrity, Path: destinationPath, Message: "destination has no id"})
+			} else if destinationIDs[destination.ID] {
+				issues = append(issues, ValidationIssueT{Kind: IssueIntegrity, Path: destinationPath, Message: fmt.Sprintf("destination %s is attached to source %s more than once", destination.ID, source.ID)})
+			}
+			destinationIDs[destination.ID] = true
+
+			if destination.DestinationDefinition.ID == "" && destination.DestinationDefinition.Name == "" {
+				issues = append(issues, ValidationIssueT{Kind: IssueIntegrity, Path: destinationPath, Message: "destination has no destination definition"})
+			}
+
+			if definitionID := destination.DestinationDefinition.ID; !checkedDefinitions[definitionID] {
+				checkedDefinitions[definitionID] = true
+				if _, err := CompileResponseRules(destination.DestinationDefinition.ResponseRules); err != nil {
+					issues = append(issues, ValidationIssueT{Kind: IssueResponseRules, Path: destinationPath + ".destinationDefinition.responseRules", Message: err.Error()})
+				}
+			}
+
+			if destination.Schedule != nil {
+				issues = append(issues, ValidateDestinationSchedule(*destination.Schedule, destinationPath+".schedule")...)
+			}
+		}
+	}
+	issues = append(issues, ValidateWorkspaceSettings(config.Settings, "settings")...)
+	return issues
+}
+
+// DiffConfigs lists the sources and destinations added, removed or changed from oldConfig to newConfig
+func DiffConfigs(oldConfig ConfigT, newConfig ConfigT) []ConfigDiffT {
+	oldSources, oldDestinations := indexConfig(oldConfig)
+	newSources, newDestinations := indexConfig(newConfig)
+
+	diffs := make([]ConfigDiffT, 0)
+	for id, oldSource := range oldSources {
+		newSource, ok := newSources[id]
+		if !ok {
+			diffs = append(diffs, ConfigDiffT{Kind: "source", ID: id, Name: oldSource.Name, Change: "removed"})
+			continue
+		}
+		// destinations are compared separately
+		oldSource.Destinations, newSource.Destinations = nil, nil
+		if !reflect.DeepEqual(oldSource, newSource) {
+			diffs = append(diffs, ConfigDiffT{Kind: "source", ID: id, Name: newSource.Name, Change: "changed"})
+		}
+	}
+	for id, newSource := range newSources {
+		if _, ok := oldSources[id]; !ok {
+			diffs = append(diffs, ConfigDiffT{Kind: "source", ID: id, Name: newSource.Name, Change: "added"})
+		}
+	}
+
+	for id, oldDestination := range oldDestinations {
+		newDestination, ok := newDestinations[id]
+		if !ok {
+			diffs = append(diffs, ConfigDiffT{Kind: "destination", ID: id, Name: oldDestination.Name, Change: "removed"})
+		} else if !reflect.DeepEqual(oldDestination, newDestination) {
+			diffs = append(diffs, ConfigDiffT{Kind: "destination", ID: id, Name: newDestination.Name, Change: "changed"})
+		}
+	}
+	for id, newDestination := range newDestinations {
+		if _, ok := oldDestinations[id]; !ok {
+			diffs = append(diffs, ConfigDiffT{Kind: "destination", ID: id, Name: newDestination.Name, Change: "added"})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Kind != diffs[j].Kind {
+			return diffs[i].Kind > diffs[j].Kind
+		}
+		return diffs[i].ID < diffs[j].ID
+	})
+	return diffs
+}
+
+// indexConfig maps sources by ID and destinations by source and destination ID, as a destination may be attached to several sources
+func indexConfig(config ConfigT) (map[string]SourceT, map[string]DestinationT) {
+	sources := make(map[string]SourceT)
+	destinations := make(map[string]DestinationT)
+	for _, source := range config.Sources {
+		sources[source.ID] = source
+		for _, destination := range source.Destinations {
+			destinations[source.ID+"/"+destination.ID] = destination
+		}
+	}
+	return sources, destinations
+}
+
+// describeJSONError adds the line and column to syntax and type errors of json.Unmarshal
+func describeJSONError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		line, column := offsetToLineColumn(data, int(syntaxErr.Offset))
+		return fmt.Errorf("%d:%d: %w", line, column, err)
+	case errors.As(err, &typeErr):
+		line, column := offsetToLineColumn(data, int(typeErr.Offset))
+		return fmt.Errorf("%d:%d: %w", line, column, err)
+	default:
+		return err
+	}
+}
+
+func offsetToLineColumn(data []byte, offset int) (line int, column int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line = 1 + bytes.Count(data[:offset], []byte("\n"))
+	column = offset - bytes.LastIndexByte(data[:offset], '\n')
+	return line, column
+}
+
+// jsonFieldWalker walks a JSON document alongside the Go type it is decoded into, recording object keys the type has no field for and required keys that are absent
+type jsonFieldWalker struct {
+	data   []byte
+	issues []ValidationIssueT
+}
+
+// walk checks raw, found at offset of the document, against typ
+func (walker *jsonFieldWalker) walk(raw []byte, offset int, typ reflect.Type, path string) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ.Kind() == reflect.Struct && bytes.HasPrefix(raw, []byte("{")):
+		fields := jsonFields(typ)
+		seen := make(map[string]bool)
+		err := walker.walkObject(raw, offset, path, func(key string, keyOffset int) (reflect.Type, bool) {
+			field, ok := fields[strings.ToLower(key)]
+			if !ok {
+				line, column := offsetToLineColumn(walker.data, keyOffset)
+				walker.issues = append(walker.issues, ValidationIssueT{Kind: IssueUnknownField, Path: joinPath(path, key), Line: line, Column: column, Message: fmt.Sprintf("unknown field %q", key)})
+				return nil, false
+			}
+			seen[field.Name] = true
+			return field.Type, true
+		})
+		if err != nil {
+			return err
+		}
+
+		line, column := offsetToLineColumn(walker.data, offset)
+		for _, fieldName := range requiredConfigFields[typ] {
+			if !seen[fieldName] {
+				field, _ := typ.FieldByName(fieldName)
+				name, _ := configFieldJSONName(field)
+				walker.issues = append(walker.issues, ValidationIssueT{Kind: IssueMissingField, Path: joinPath(path, name), Line: line, Column: column, Message: fmt.Sprintf("missing required field %q", name)})
+			}
+		}
+		return nil
+	case typ.Kind() == reflect.Map && typ.Elem().Kind() != reflect.Interface && bytes.HasPrefix(raw, []byte("{")):
+		return walker.walkObject(raw, offset, path, func(string, int) (reflect.Type, bool) {
+			return typ.Elem(), true
+		})
+	case (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) && bytes.HasPrefix(raw, []byte("[")):
+		return walker.walkArray(raw, offset, typ.Elem(), path)
+	}
+	// scalars, free-form values and type mismatches, which json.Unmarshal reports itself
+	return nil
+}
+
+// walkObject walks the values of a JSON object, asking fieldType for the type of each key
+func (walker *jsonFieldWalker) walkObject(raw []byte, offset int, path string, fieldType func(key string, keyOffset int) (reflect.Type, bool)) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	for decoder.More() {
+		// the key starts after the separators following the previous token, its escaped quotes make searching back from its end unreliable
+		keyOffset := offset + skipJSONSeparators(raw, int(decoder.InputOffset()))
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		keyEnd := int(decoder.InputOffset())
+
+		valueOffset := offset + skipJSONSeparators(raw, keyEnd)
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return err
+		}
+		if typ, ok := fieldType(key, keyOffset); ok {
+			if err = walker.walk(value, valueOffset, typ, joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := decoder.Token()
+	return err
+}
+
+func (walker *jsonFieldWalker) walkArray(raw []byte, offset int, elemType reflect.Type, path string) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	for i := 0; decoder.More(); i++ {
+		valueOffset := offset + skipJSONSeparators(raw, int(decoder.InputOffset()))
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		if err := walker.walk(value, valueOffset, elemType, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	_, err := decoder.Token()
+	return err
+}
+
+// jsonFields maps the lower cased JSON names of the fields of a struct to the fields, matching keys case-insensitively like json.Unmarshal
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if name, ok := configFieldJSONName(field); ok {
+			fields[strings.ToLower(name)] = field
+		}
+	}
+	return fields
+}
+
+// skipJSONSeparators returns the index of the first byte at or after i that isn't whitespace, ':' or ','
+func skipJSONSeparators(raw []byte, i int) int {
+	for i < len(raw) && strings.IndexByte(" \t\r\n:,", raw[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
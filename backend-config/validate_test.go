@@ -0,0 +1,98 @@
+package backendconfig
+
+import (
+	"testing"
+)
+
+func TestCheckConfigFieldsUnknownFieldPosition(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantPath   string
+		wantLine   int
+		wantColumn int
+	}{
+		{
+			name:       "plain key",
+			data:       `{"workspaceId": "workspace-1", "unknown": 1}`,
+			wantPath:   "unknown",
+			wantLine:   1,
+			wantColumn: 32,
+		},
+		{
+			name:       "escaped quote in key",
+			data:       `{"workspaceId": "workspace-1", "un\"known": 1}`,
+			wantPath:   `un"known`,
+			wantLine:   1,
+			wantColumn: 32,
+		},
+		{
+			name:       "escaped quote at the end of the key",
+			data:       `{"workspaceId": "workspace-1", "unknown\"": 1}`,
+			wantPath:   `unknown"`,
+			wantLine:   1,
+			wantColumn: 32,
+		},
+		{
+			name:       "escaped quote on a later line",
+			data:       "{\n  \"workspaceId\": \"workspace-1\",\n    \"\\\"quoted\\\"\": 1\n}",
+			wantPath:   `"quoted"`,
+			wantLine:   3,
+			wantColumn: 5,
+		},
+		{
+			name:       "nested key",
+			data:       "{\"workspaceId\": \"workspace-1\", \"sources\": [\n {\"id\": \"source-1\", \"a\\\\\": 1}]}",
+			wantPath:   `sources[0].a\`,
+			wantLine:   2,
+			wantColumn: 21,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := CheckConfigFields([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var found bool
+			for _, issue := range issues {
+				if issue.Kind != IssueUnknownField {
+					continue
+				}
+				found = true
+				if issue.Path != tt.wantPath || issue.Line != tt.wantLine || issue.Column != tt.wantColumn {
+					t.Errorf("got %s at %d:%d, want %s at %d:%d", issue.Path, issue.Line, issue.Column, tt.wantPath, tt.wantLine, tt.wantColumn)
+				}
+			}
+			if !found {
+				t.Errorf("no unknown field reported in %v", issues)
+			}
+		})
+	}
+}
+
+func TestConfigFieldCheckerSkipsUnchangedConfigs(t *testing.T) {
+	checker := newConfigFieldChecker(true)
+	steps := []struct {
+		name   string
+		origin string
+		data   string
+		want   bool
+	}{
+		{name: "first config", origin: "workspace-1", data: `{"workspaceId": "workspace-1", "unknown": 1}`, want: true},
+		{name: "same config", origin: "workspace-1", data: `{"workspaceId": "workspace-1", "unknown": 1}`, want: false},
+		{name: "same config of another origin", origin: "workspace-2", data: `{"workspaceId": "workspace-1", "unknown": 1}`, want: true},
+		{name: "changed config", origin: "workspace-1", data: `{"workspaceId": "workspace-1", "unknown": 2}`, want: true},
+		{name: "changed back", origin: "workspace-1", data: `{"workspaceId": "workspace-1", "unknown": 1}`, want: true},
+		{name: "unchanged again", origin: "workspace-1", data: `{"workspaceId": "workspace-1", "unknown": 1}`, want: false},
+	}
+	for _, step := range steps {
+		if got := checker.check("hosted", step.origin, []byte(step.data)); got != step.want {
+			t.Errorf("%s: checked %t, want %t", step.name, got, step.want)
+		}
+	}
+
+	if newConfigFieldChecker(false).check("hosted", "workspace-1", []byte(`{}`)) {
+		t.Error("checked a config without strict decoding")
+	}
+}
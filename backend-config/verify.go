@@ -0,0 +1,171 @@
+package backendconfig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"strings"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+const (
+	// configSignatureHeader carries the base64 encoded signature of the config payload
+	configSignatureHeader = "X-Rudder-Signature"
+	// configSignatureFileSuffix is appended to ConfigJSONPath to find the signature of a config file
+	configSignatureFileSuffix = ".sig"
+
+	// ConfigVerificationHMAC verifies config payloads against an HMAC-SHA256 with one of ConfigHMACSecrets
+	ConfigVerificationHMAC = "hmac"
+	// ConfigVerificationEd25519 verifies config payloads against an ed25519 signature by one of ConfigPublicKeys
+	ConfigVerificationEd25519 = "ed25519"
+)
+
+var (
+	// ErrConfigSignatureMissing is reported when verification is enabled but the config has no signature
+	ErrConfigSignatureMissing = errors.New("config signature missing")
+	// ErrConfigSignatureInvalid is reported when the config signature doesn't match any configured key
+	ErrConfigSignatureInvalid = errors.New("config signature invalid")
+)
+
+/*
+configVerifier checks that config payloads are signed by the control plane before they are accepted.
+Several secrets or public keys may be configured at once to allow key rotation.
+*/
+type configVerifier struct {
+	mode        string
+	hmacSecrets [][]byte
+	publicKeys  []ed25519.PublicKey
+}
+
+func newConfigVerifier(mode string, hmacSecrets []string, publicKeys []string) *configVerifier {
+	verifier := &configVerifier{mode: strings.ToLower(mode)}
+	for _, secret := range hmacSecrets {
+		if secret != "" {
+			verifier.hmacSecrets = append(verifier.hmacSecrets, []byte(secret))
+		}
+	}
+	for _, encodedKey := range publicKeys {
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			pkgLogger.Errorf("Ignoring invalid ed25519 config public key %q", encodedKey)
+			continue
+		}
+		verifier.publicKeys = append(verifier.publicKeys, ed25519.PublicKey(key))
+	}
+	if verifier.enabled() && len(verifier.hmacSecrets) == 0 && len(verifier.publicKeys) == 0 {
+		pkgLogger.Errorf("Config verification mode %s is enabled without any usable key, every config will be rejected", verifier.mode)
+	}
+	return verifier
+}
+
+func (verifier *configVerifier) enabled() bool {
+	return verifier.mode == ConfigVerificationHMAC || verifier.mode == ConfigVerificationEd25519
+}
+
+// verify checks the base64 encoded signature of payload. It returns nil if verification is disabled.
+func (verifier *configVerifier) verify(payload []byte, signature string) error {
+	payloadVerifier := verifier.newPayloadVerifier(signature)
+	payloadVerifier.Write(payload)
+	return payloadVerifier.verify()
+}
+
+/*
+payloadVerifier checks the signature of a payload written to it as it is read, e.g. from a streamed response.
+HMACs are computed on the fly, while ed25519 signatures need the whole payload, of which a copy is kept.
+*/
+type payloadVerifier struct {
+	verifier  *configVerifier
+	signature string
+	macs      []hash.Hash
+	payload   bytes.Buffer
+}
+
+func (verifier *configVerifier) newPayloadVerifier(signature string) *payloadVerifier {
+	payloadVerifier := &payloadVerifier{verifier: verifier, signature: strings.TrimSpace(signature)}
+	if verifier.mode == ConfigVerificationHMAC {
+		for _, secret := range verifier.hmacSecrets {
+			payloadVerifier.macs = append(payloadVerifier.macs, hmac.New(sha256.New, secret))
+		}
+	}
+	return payloadVerifier
+}
+
+func (payloadVerifier *payloadVerifier) Write(p []byte) (int, error) {
+	switch payloadVerifier.verifier.mode {
+	case ConfigVerificationHMAC:
+		for _, mac := range payloadVerifier.macs {
+			mac.Write(p)
+		}
+	case ConfigVerificationEd25519:
+		payloadVerifier.payload.Write(p)
+	}
+	return len(p), nil
+}
+
+// verify checks the signature against the payload written so far. It returns nil if verification is disabled.
+func (payloadVerifier *payloadVerifier) verify() error {
+	verifier := payloadVerifier.verifier
+	if !verifier.enabled() {
+		return nil
+	}
+	if payloadVerifier.signature == "" {
+		return ErrConfigSignatureMissing
+	}
+	decodedSignature, err := base64.StdEncoding.DecodeString(payloadVerifier.signature)
+	if err != nil {
+		return ErrConfigSignatureInvalid
+	}
+
+	switch verifier.mode {
+	case ConfigVerificationHMAC:
+		for _, mac := range payloadVerifier.macs {
+			if hmac.Equal(mac.Sum(nil), decodedSignature) {
+				return nil
+			}
+		}
+	case ConfigVerificationEd25519:
+		for _, key := range verifier.publicKeys {
+			if ed25519.Verify(key, payloadVerifier.payload.Bytes(), decodedSignature) {
+				return nil
+			}
+		}
+	}
+	return ErrConfigSignatureInvalid
+}
+
+// sign returns the signature of payload for peers of the config proxy. Only HMAC verification can sign, as no private key is held.
+func (verifier *configVerifier) sign(payload []byte) (string, bool) {
+	if verifier.mode != ConfigVerificationHMAC || len(verifier.hmacSecrets) == 0 {
+		return "", false
+	}
+	return base64.StdEncoding.EncodeToString(computeHMAC(verifier.hmacSecrets[0], payload)), true
+}
+
+func computeHMAC(secret []byte, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// verifyConfigPayload verifies a config fetched from source ("api" or "file"), counting and logging rejections
+func (verifier *configVerifier) verifyConfigPayload(source string, payload []byte, signature string) error {
+	return verifier.reportRejection(source, verifier.verify(payload, signature))
+}
+
+// verifyStreamedPayload verifies a config streamed from source through payloadVerifier, counting and logging rejections
+func (verifier *configVerifier) verifyStreamedPayload(source string, payloadVerifier *payloadVerifier) error {
+	return verifier.reportRejection(source, payloadVerifier.verify())
+}
+
+func (verifier *configVerifier) reportRejection(source string, err error) error {
+	if err != nil {
+		pkgLogger.Errorf("Rejecting workspace config from %s: %s", source, err.Error())
+		stats.NewTaggedStat("config_backend.signature_rejected", stats.CountType, map[string]string{"source": source, "reason": err.Error()}).Increment()
+	}
+	return err
+}
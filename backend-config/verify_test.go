@@ -0,0 +1,104 @@
+package backendconfig
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+func TestConfigVerifier(t *testing.T) {
+	payload := []byte(`{"sources":[]}`)
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherPublicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	hmacSignature := func(secret string) string {
+		return base64.StdEncoding.EncodeToString(computeHMAC([]byte(secret), payload))
+	}
+	ed25519Signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+
+	tests := []struct {
+		name       string
+		mode       string
+		secrets    []string
+		publicKeys []ed25519.PublicKey
+		signature  string
+		want       error
+	}{
+		{name: "disabled accepts anything", mode: "", signature: "", want: nil},
+		{name: "hmac", mode: ConfigVerificationHMAC, secrets: []string{"secret"}, signature: hmacSignature("secret"), want: nil},
+		{name: "hmac mode is case insensitive", mode: "HMAC", secrets: []string{"secret"}, signature: hmacSignature("secret"), want: nil},
+		{name: "hmac with a rotated secret", mode: ConfigVerificationHMAC, secrets: []string{"new", "old"}, signature: hmacSignature("old"), want: nil},
+		{name: "hmac with another secret", mode: ConfigVerificationHMAC, secrets: []string{"secret"}, signature: hmacSignature("other"), want: ErrConfigSignatureInvalid},
+		{name: "hmac missing", mode: ConfigVerificationHMAC, secrets: []string{"secret"}, signature: " ", want: ErrConfigSignatureMissing},
+		{name: "not base64", mode: ConfigVerificationHMAC, secrets: []string{"secret"}, signature: "!!", want: ErrConfigSignatureInvalid},
+		{name: "hmac without usable secret", mode: ConfigVerificationHMAC, secrets: []string{""}, signature: hmacSignature(""), want: ErrConfigSignatureInvalid},
+		{name: "ed25519", mode: ConfigVerificationEd25519, publicKeys: []ed25519.PublicKey{otherPublicKey, publicKey}, signature: ed25519Signature, want: nil},
+		{name: "ed25519 with another key", mode: ConfigVerificationEd25519, publicKeys: []ed25519.PublicKey{otherPublicKey}, signature: ed25519Signature, want: ErrConfigSignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encodedKeys := make([]string, 0)
+			for _, key := range tt.publicKeys {
+				encodedKeys = append(encodedKeys, base64.StdEncoding.EncodeToString(key))
+			}
+			verifier := newConfigVerifier(tt.mode, tt.secrets, append(encodedKeys, "not a key"))
+			if err := verifier.verify(payload, tt.signature); err != tt.want {
+				t.Errorf("verify = %v, want %v", err, tt.want)
+			}
+
+			// written in chunks, the way a streamed response is
+			payloadVerifier := verifier.newPayloadVerifier(tt.signature)
+			for _, b := range payload {
+				payloadVerifier.Write([]byte{b})
+			}
+			if err := payloadVerifier.verify(); err != tt.want {
+				t.Errorf("streamed verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigVerifierSign(t *testing.T) {
+	verifier := newConfigVerifier(ConfigVerificationHMAC, []string{"secret"}, nil)
+	signature, ok := verifier.sign([]byte("payload"))
+	if !ok || verifier.verify([]byte("payload"), signature) != nil {
+		t.Fatalf("signature %q of the proxy doesn't verify", signature)
+	}
+	if _, ok = newConfigVerifier(ConfigVerificationEd25519, nil, nil).sign([]byte("payload")); ok {
+		t.Error("ed25519 verification can't sign without a private key")
+	}
+}
+
+func TestVerifyFetchedConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		multi         bool
+		signingSecret string
+		wantOK        bool
+	}{
+		{name: "signed", signingSecret: "secret", wantOK: true},
+		{name: "unsigned", signingSecret: "", wantOK: false},
+		{name: "signed with another secret", signingSecret: "other", wantOK: false},
+		{name: "hosted signed", multi: true, signingSecret: "secret", wantOK: true},
+		{name: "hosted unsigned", multi: true, signingSecret: "", wantOK: false},
+		{name: "hosted signed with another secret", multi: true, signingSecret: "other", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newTestConfigBackend(t, testSourcesConfig("d1"))
+			backend.setSigningSecret(tt.signingSecret)
+			instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+				setup.IsMultiWorkspace = tt.multi
+				setup.ConfigVerificationMode = ConfigVerificationHMAC
+				setup.ConfigHMACSecrets = []string{"secret"}
+			})
+			config, ok := instance.provider.Get()
+			if ok != tt.wantOK {
+				t.Fatalf("Get ok = %t, want %t", ok, tt.wantOK)
+			}
+			if ok && len(config.Sources) != 1 {
+				t.Errorf("got %d sources, want 1", len(config.Sources))
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -15,7 +16,17 @@ type WorkspaceConfig struct {
 	CommonBackendConfig
 	workspaceID               string
 	workspaceIDToLibrariesMap map[string]LibrariesT
-	workspaceIDLock           sync.RWMutex
+	workspaceIDToSettingsMap  map[string]WorkspaceSettingsT
+	// fetched holds the workspace ID, libraries and settings of the last config fetched until the instance publishes it
+	fetched         *fetchedWorkspaceT
+	workspaceIDLock sync.RWMutex
+}
+
+// fetchedWorkspaceT is what WorkspaceConfig indexes from a fetched config
+type fetchedWorkspaceT struct {
+	workspaceID               string
+	workspaceIDToLibrariesMap map[string]LibrariesT
+	workspaceIDToSettingsMap  map[string]WorkspaceSettingsT
 }
 
 func (workspaceConfig *WorkspaceConfig) SetUp() {
@@ -38,9 +49,42 @@ func (workspaceConfig *WorkspaceConfig) GetWorkspaceLibrariesForWorkspaceID(work
 	return workspaceConfig.workspaceIDToLibrariesMap[workspaceID]
 }
 
+//GetWorkspaceSettingsForWorkspaceID returns the settings of the workspace published last, with defaults for the settings that aren't set or are invalid
+func (workspaceConfig *WorkspaceConfig) GetWorkspaceSettingsForWorkspaceID(workspaceID string) WorkspaceSettingsT {
+	workspaceConfig.workspaceIDLock.RLock()
+	defer workspaceConfig.workspaceIDLock.RUnlock()
+	return workspaceConfig.workspaceIDToSettingsMap[workspaceID].withDefaults()
+}
+
+// fetchedWorkspace keeps the workspace ID, libraries and settings of a fetched config until publishFetchedWorkspaces is called
+func (workspaceConfig *WorkspaceConfig) fetchedWorkspace(config ConfigT) {
+	workspaceConfig.workspaceIDLock.Lock()
+	defer workspaceConfig.workspaceIDLock.Unlock()
+	workspaceConfig.fetched = &fetchedWorkspaceT{
+		workspaceID:               config.WorkspaceID,
+		workspaceIDToLibrariesMap: map[string]LibrariesT{config.WorkspaceID: config.Libraries},
+		workspaceIDToSettingsMap:  map[string]WorkspaceSettingsT{config.WorkspaceID: config.Settings},
+	}
+}
+
+// publishFetchedWorkspaces replaces the workspace ID, libraries and settings with the fetched ones, reporting the settings if they changed
+func (workspaceConfig *WorkspaceConfig) publishFetchedWorkspaces() {
+	workspaceConfig.workspaceIDLock.Lock()
+	defer workspaceConfig.workspaceIDLock.Unlock()
+	fetched := workspaceConfig.fetched
+	if fetched == nil {
+		return
+	}
+	reportChangedWorkspaceSettings(workspaceConfig.workspaceIDToSettingsMap, fetched.workspaceIDToSettingsMap)
+	workspaceConfig.workspaceID = fetched.workspaceID
+	workspaceConfig.workspaceIDToLibrariesMap = fetched.workspaceIDToLibrariesMap
+	workspaceConfig.workspaceIDToSettingsMap = fetched.workspaceIDToSettingsMap
+	workspaceConfig.fetched = nil
+}
+
 //Get returns sources from the workspace
 func (workspaceConfig *WorkspaceConfig) Get() (ConfigT, bool) {
-	if configFromFile {
+	if workspaceConfig.getInstance().setup.ConfigFromFile {
 		return workspaceConfig.getFromFile()
 	} else {
 		return workspaceConfig.getFromAPI()
@@ -49,7 +93,7 @@ func (workspaceConfig *WorkspaceConfig) Get() (ConfigT, bool) {
 
 //GetRegulations returns sources from the workspace
 func (workspaceConfig *WorkspaceConfig) GetRegulations() (RegulationsT, bool) {
-	if configFromFile {
+	if workspaceConfig.getInstance().setup.ConfigFromFile {
 		return workspaceConfig.getRegulationsFromFile()
 	} else {
 		return workspaceConfig.getRegulationsFromAPI()
@@ -58,14 +102,19 @@ func (workspaceConfig *WorkspaceConfig) GetRegulations() (RegulationsT, bool) {
 
 // getFromApi gets the workspace config from api
 func (workspaceConfig *WorkspaceConfig) getFromAPI() (ConfigT, bool) {
-	url := fmt.Sprintf("%s/workspaceConfig?fetchAll=true", configBackendURL)
+	path := "/workspaceConfig?fetchAll=true"
 
 	var respBody []byte
 	var statusCode int
+	var signature string
 
 	operation := func() error {
 		var fetchError error
-		respBody, statusCode, fetchError = workspaceConfig.makeHTTPRequest(url)
+		respBody, statusCode, fetchError = workspaceConfig.getInstance().endpoints.get(path, func(url string) ([]byte, int, error) {
+			body, code, header, err := workspaceConfig.makeHTTPRequestWithHeader(url)
+			signature = header.Get(configSignatureHeader)
+			return body, code, err
+		})
 		return fetchError
 	}
 
@@ -79,11 +128,17 @@ func (workspaceConfig *WorkspaceConfig) getFromAPI() (ConfigT, bool) {
 		return ConfigT{}, false
 	}
 
+	if err = workspaceConfig.getInstance().verifier.verifyConfigPayload("api", respBody, signature); err != nil {
+		return ConfigT{}, false
+	}
+
 	configEnvHandler := workspaceConfig.CommonBackendConfig.configEnvHandler
-	if configEnvReplacementEnabled && configEnvHandler != nil {
+	if workspaceConfig.getInstance().setup.ConfigEnvReplacementEnabled && configEnvHandler != nil {
 		respBody = configEnvHandler.ReplaceConfigWithEnvVariables(respBody)
 	}
 
+	workspaceConfig.getInstance().fieldChecker.check("api", "API", respBody)
+
 	var sourcesJSON ConfigT
 	err = json.Unmarshal(respBody, &sourcesJSON)
 	if err != nil {
@@ -91,11 +146,7 @@ func (workspaceConfig *WorkspaceConfig) getFromAPI() (ConfigT, bool) {
 		return ConfigT{}, false
 	}
 
-	workspaceConfig.workspaceIDLock.Lock()
-	workspaceConfig.workspaceID = sourcesJSON.WorkspaceID
-	workspaceConfig.workspaceIDToLibrariesMap = make(map[string]LibrariesT)
-	workspaceConfig.workspaceIDToLibrariesMap[sourcesJSON.WorkspaceID] = sourcesJSON.Libraries
-	workspaceConfig.workspaceIDLock.Unlock()
+	workspaceConfig.fetchedWorkspace(sourcesJSON)
 
 	return sourcesJSON, true
 }
@@ -103,18 +154,43 @@ func (workspaceConfig *WorkspaceConfig) getFromAPI() (ConfigT, bool) {
 // getFromFile reads the workspace config from JSON file
 func (workspaceConfig *WorkspaceConfig) getFromFile() (ConfigT, bool) {
 	pkgLogger.Info("Reading workspace config from JSON file")
-	data, err := IoUtil.ReadFile(configJSONPath)
+	configJSONPath := workspaceConfig.getInstance().setup.ConfigJSONPath
+	configJSON, data, err := workspaceConfig.getInstance().LoadConfigFile(configJSONPath)
 	if err != nil {
-		pkgLogger.Errorf("Unable to read backend config from file: %s with error : %s", configJSONPath, err.Error())
+		pkgLogger.Error(err.Error())
 		return ConfigT{}, false
 	}
+	workspaceConfig.getInstance().fieldChecker.check("file", configJSONPath, data)
+	workspaceConfig.fetchedWorkspace(configJSON)
+	return configJSON, true
+}
+
+// LoadConfigFile reads, verifies and parses a workspace config file the way ConfigFromFile does. It also returns the raw file content.
+func LoadConfigFile(path string) (ConfigT, []byte, error) {
+	return defaultInstance.LoadConfigFile(path)
+}
+
+// LoadConfigFile reads, verifies and parses a workspace config file with the verification settings of the instance
+func (instance *Instance) LoadConfigFile(path string) (ConfigT, []byte, error) {
+	data, err := IoUtil.ReadFile(path)
+	if err != nil {
+		return ConfigT{}, nil, fmt.Errorf("Unable to read backend config from file: %s with error : %w", path, err)
+	}
+	if instance.verifier.enabled() {
+		signature, err := IoUtil.ReadFile(path + configSignatureFileSuffix)
+		if err != nil && !os.IsNotExist(err) {
+			return ConfigT{}, data, fmt.Errorf("Unable to read backend config signature from file: %s with error : %w", path+configSignatureFileSuffix, err)
+		}
+		if err = instance.verifier.verifyConfigPayload("file", data, string(signature)); err != nil {
+			return ConfigT{}, data, err
+		}
+	}
 	var configJSON ConfigT
-	error := json.Unmarshal(data, &configJSON)
-	if error != nil {
-		pkgLogger.Errorf("Unable to parse backend config from file: %s", configJSONPath)
-		return ConfigT{}, false
+	err = json.Unmarshal(data, &configJSON)
+	if err != nil {
+		return ConfigT{}, data, fmt.Errorf("Unable to parse backend config from file: %s: %w", path, describeJSONError(data, err))
 	}
-	return configJSON, true
+	return configJSON, data, nil
 }
 
 func (workspaceConfig *WorkspaceConfig) getRegulationsFromAPI() (RegulationsT, bool) {
@@ -140,14 +216,14 @@ func (workspaceConfig *WorkspaceConfig) getWorkspaceRegulationsFromAPI() ([]Work
 
 	totalWorkspaceRegulations := []WorkspaceRegulationT{}
 	for {
-		url := fmt.Sprintf("%s/workspaces/regulations?start=%d&limit=%d", configBackendURL, start, maxRegulationsPerRequest)
+		path := fmt.Sprintf("/workspaces/regulations?start=%d&limit=%d", start, workspaceConfig.getInstance().setup.MaxRegulationsPerRequest)
 
 		var respBody []byte
 		var statusCode int
 
 		operation := func() error {
 			var fetchError error
-			respBody, statusCode, fetchError = workspaceConfig.makeHTTPRequest(url)
+			respBody, statusCode, fetchError = workspaceConfig.getInstance().endpoints.get(path, workspaceConfig.makeHTTPRequest)
 			return fetchError
 		}
 
@@ -195,14 +271,14 @@ func (workspaceConfig *WorkspaceConfig) getSourceRegulationsFromAPI() ([]SourceR
 
 	totalSourceRegulations := []SourceRegulationT{}
 	for {
-		url := fmt.Sprintf("%s/workspaces/sources/regulations?start=%d&limit=%d", configBackendURL, start, maxRegulationsPerRequest)
+		path := fmt.Sprintf("/workspaces/sources/regulations?start=%d&limit=%d", start, workspaceConfig.getInstance().setup.MaxRegulationsPerRequest)
 
 		var respBody []byte
 		var statusCode int
 
 		operation := func() error {
 			var fetchError error
-			respBody, statusCode, fetchError = workspaceConfig.makeHTTPRequest(url)
+			respBody, statusCode, fetchError = workspaceConfig.getInstance().endpoints.get(path, workspaceConfig.makeHTTPRequest)
 			return fetchError
 		}
 
@@ -252,18 +328,23 @@ func (workspaceConfig *WorkspaceConfig) getRegulationsFromFile() (RegulationsT,
 }
 
 func (workspaceConfig *WorkspaceConfig) makeHTTPRequest(url string) ([]byte, int, error) {
+	respBody, statusCode, _, err := workspaceConfig.makeHTTPRequestWithHeader(url)
+	return respBody, statusCode, err
+}
+
+func (workspaceConfig *WorkspaceConfig) makeHTTPRequestWithHeader(url string) ([]byte, int, http.Header, error) {
 	req, err := Http.NewRequest("GET", url, nil)
 	if err != nil {
-		return []byte{}, 400, err
+		return []byte{}, 400, nil, err
 	}
 
-	req.SetBasicAuth(workspaceToken, "")
+	req.SetBasicAuth(workspaceConfig.getInstance().setup.WorkSpaceToken, "")
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return []byte{}, 400, err
+		return []byte{}, 400, nil, err
 	}
 
 	var respBody []byte
@@ -272,5 +353,5 @@ func (workspaceConfig *WorkspaceConfig) makeHTTPRequest(url string) ([]byte, int
 		defer resp.Body.Close()
 	}
 
-	return respBody, resp.StatusCode, nil
+	return respBody, resp.StatusCode, resp.Header, nil
 }
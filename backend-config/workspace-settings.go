@@ -0,0 +1,156 @@
+package backendconfig
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+
+	"github.com/rudderlabs/rudder-utils/stats"
+)
+
+const (
+	// IssueSettings is a workspace setting with an invalid value
+	IssueSettings = "settings"
+
+	// defaultDataRetentionDays applies to workspaces that don't set DataRetentionT.Days
+	defaultDataRetentionDays = 30
+)
+
+// knownEventTypes are the event types EventFilteringT can allow
+var knownEventTypes = []string{"identify", "track", "page", "screen", "group", "alias"}
+
+// knownStorageBucketTypes are the object storages a StorageBucketT can point at
+var knownStorageBucketTypes = []string{"S3", "GCS", "AZURE_BLOB", "MINIO", "DIGITAL_OCEAN_SPACES"}
+
+/*
+WorkspaceSettingsT holds the workspace wide settings sent along with the config of a workspace. Settings that aren't sent take their
+defaults, see GetWorkspaceSettingsForWorkspaceID.
+*/
+type WorkspaceSettingsT struct {
+	DataRetention  DataRetentionT  `json:"dataRetention"`
+	EventFiltering EventFilteringT `json:"eventFiltering"`
+	// AllowedIPRanges are the CIDR ranges events are accepted from. Empty accepts events from anywhere.
+	AllowedIPRanges []string `json:"allowedIpRanges"`
+	// StorageBucket overrides the object storage the server uploads the data of the workspace to
+	StorageBucket *StorageBucketT `json:"storageBucket,omitempty"`
+}
+
+// DataRetentionT is how long the data of the workspace is kept, e.g. failed events and job archives
+type DataRetentionT struct {
+	Days int `json:"days"`
+}
+
+// EventFilteringT is the default filtering applied to the events of every source of the workspace
+type EventFilteringT struct {
+	// AllowedEventTypes are the event types accepted, all of them if empty
+	AllowedEventTypes []string `json:"allowedEventTypes"`
+	// BlockedEventNames are the names of track events dropped
+	BlockedEventNames []string `json:"blockedEventNames"`
+}
+
+// StorageBucketT is an object storage bucket, with Config holding the credentials in the format of the matching destination
+type StorageBucketT struct {
+	Type   string                 `json:"type"`
+	Bucket string                 `json:"bucket"`
+	Prefix string                 `json:"prefix"`
+	Config map[string]interface{} `json:"config"`
+}
+
+/*
+withDefaults returns settings with the defaults of the settings that aren't set or are invalid, as reported by ValidateWorkspaceSettings.
+An invalid storage bucket override is dropped, so that data is uploaded to the default storage rather than nowhere. Unknown allowed
+event types are dropped too, an event type like "Track" would otherwise reject every event, and none left allows every type.
+*/
+func (settings WorkspaceSettingsT) withDefaults() WorkspaceSettingsT {
+	if settings.DataRetention.Days <= 0 {
+		settings.DataRetention.Days = defaultDataRetentionDays
+	}
+	if allowedEventTypes := settings.EventFiltering.AllowedEventTypes; len(allowedEventTypes) > 0 {
+		// the fetched slice is shared by every call, filter into a new one
+		settings.EventFiltering.AllowedEventTypes = make([]string, 0, len(allowedEventTypes))
+		for _, eventType := range allowedEventTypes {
+			if containsString(knownEventTypes, eventType) {
+				settings.EventFiltering.AllowedEventTypes = append(settings.EventFiltering.AllowedEventTypes, eventType)
+			}
+		}
+	}
+	if bucket := settings.StorageBucket; bucket != nil && (!containsString(knownStorageBucketTypes, bucket.Type) || bucket.Bucket == "") {
+		settings.StorageBucket = nil
+	}
+	return settings
+}
+
+// EventAllowed reports whether an event of eventType, with eventName for track events, passes the event filtering of the workspace
+func (settings WorkspaceSettingsT) EventAllowed(eventType string, eventName string) bool {
+	if len(settings.EventFiltering.AllowedEventTypes) > 0 && !containsString(settings.EventFiltering.AllowedEventTypes, eventType) {
+		return false
+	}
+	return eventType != "track" || !containsString(settings.EventFiltering.BlockedEventNames, eventName)
+}
+
+/*
+IPAllowed reports whether events from ip are accepted. Invalid ranges are ignored, but if ranges are set and none of them is valid
+no ip is accepted, so that a typo never opens the workspace to everyone.
+*/
+func (settings WorkspaceSettingsT) IPAllowed(ip string) bool {
+	if len(settings.AllowedIPRanges) == 0 {
+		return true
+	}
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+	for _, ipRange := range settings.AllowedIPRanges {
+		if _, network, err := net.ParseCIDR(ipRange); err == nil && network.Contains(parsedIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateWorkspaceSettings checks the settings of a workspace, prefixing the paths of the issues with path
+func ValidateWorkspaceSettings(settings WorkspaceSettingsT, path string) []ValidationIssueT {
+	issues := make([]ValidationIssueT, 0)
+	if settings.DataRetention.Days < 0 {
+		issues = append(issues, ValidationIssueT{Kind: IssueSettings, Path: path + ".dataRetention.days", Message: fmt.Sprintf("retention of %d days is negative", settings.DataRetention.Days)})
+	}
+	for i, eventType := range settings.EventFiltering.AllowedEventTypes {
+		if !containsString(knownEventTypes, eventType) {
+			issues = append(issues, ValidationIssueT{Kind: IssueSettings, Path: fmt.Sprintf("%s.eventFiltering.allowedEventTypes[%d]", path, i), Message: fmt.Sprintf("unknown event type %q", eventType)})
+		}
+	}
+	for i, ipRange := range settings.AllowedIPRanges {
+		if _, _, err := net.ParseCIDR(ipRange); err != nil {
+			issues = append(issues, ValidationIssueT{Kind: IssueSettings, Path: fmt.Sprintf("%s.allowedIpRanges[%d]", path, i), Message: fmt.Sprintf("invalid CIDR range %q", ipRange)})
+		}
+	}
+	if bucket := settings.StorageBucket; bucket != nil {
+		if !containsString(knownStorageBucketTypes, bucket.Type) {
+			issues = append(issues, ValidationIssueT{Kind: IssueSettings, Path: path + ".storageBucket.type", Message: fmt.Sprintf("unknown storage type %q", bucket.Type)})
+		}
+		if bucket.Bucket == "" {
+			issues = append(issues, ValidationIssueT{Kind: IssueSettings, Path: path + ".storageBucket.bucket", Message: "storage bucket override has no bucket"})
+		}
+	}
+	return issues
+}
+
+// reportChangedWorkspaceSettings reports the invalid settings of the workspaces whose settings changed since previous
+func reportChangedWorkspaceSettings(previous map[string]WorkspaceSettingsT, current map[string]WorkspaceSettingsT) {
+	for workspaceID, settings := range current {
+		if previousSettings, ok := previous[workspaceID]; !ok || !reflect.DeepEqual(previousSettings, settings) {
+			reportWorkspaceSettingsIssues(workspaceID, settings)
+		}
+	}
+}
+
+// reportWorkspaceSettingsIssues logs the invalid settings of a fetched workspace and counts them
+func reportWorkspaceSettingsIssues(workspaceID string, settings WorkspaceSettingsT) {
+	issues := ValidateWorkspaceSettings(settings, "settings")
+	for _, issue := range issues {
+		pkgLogger.Warnf("Invalid setting of workspace %s: %s", workspaceID, issue.String())
+	}
+	if len(issues) > 0 {
+		stats.NewTaggedStat("config_backend.settings_errors", stats.CountType, map[string]string{"workspaceId": workspaceID}).Count(len(issues))
+	}
+}
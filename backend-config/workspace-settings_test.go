@@ -0,0 +1,204 @@
+package backendconfig
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestWorkspaceSettingsWithDefaults(t *testing.T) {
+	bucket := &StorageBucketT{Type: "S3", Bucket: "bucket-1"}
+	tests := []struct {
+		name       string
+		settings   WorkspaceSettingsT
+		want       WorkspaceSettingsT
+		wantIssues int
+	}{
+		{
+			name:     "unset",
+			settings: WorkspaceSettingsT{},
+			want:     WorkspaceSettingsT{DataRetention: DataRetentionT{Days: defaultDataRetentionDays}},
+		},
+		{
+			name:     "valid",
+			settings: WorkspaceSettingsT{DataRetention: DataRetentionT{Days: 7}, StorageBucket: bucket},
+			want:     WorkspaceSettingsT{DataRetention: DataRetentionT{Days: 7}, StorageBucket: bucket},
+		},
+		{
+			name:       "negative retention",
+			settings:   WorkspaceSettingsT{DataRetention: DataRetentionT{Days: -1}},
+			want:       WorkspaceSettingsT{DataRetention: DataRetentionT{Days: defaultDataRetentionDays}},
+			wantIssues: 1,
+		},
+		{
+			name:       "unknown bucket type",
+			settings:   WorkspaceSettingsT{DataRetention: DataRetentionT{Days: 7}, StorageBucket: &StorageBucketT{Type: "FTP", Bucket: "bucket-1"}},
+			want:       WorkspaceSettingsT{DataRetention: DataRetentionT{Days: 7}},
+			wantIssues: 1,
+		},
+		{
+			name:       "unknown event type",
+			settings:   WorkspaceSettingsT{DataRetention: DataRetentionT{Days: 7}, EventFiltering: EventFilteringT{AllowedEventTypes: []string{"Track", "identify", "purchase"}}},
+			want:       WorkspaceSettingsT{DataRetention: DataRetentionT{Days: 7}, EventFiltering: EventFilteringT{AllowedEventTypes: []string{"identify"}}},
+			wantIssues: 2,
+		},
+		{
+			name:       "only unknown event types",
+			settings:   WorkspaceSettingsT{DataRetention: DataRetentionT{Days: 7}, EventFiltering: EventFilteringT{AllowedEventTypes: []string{"Track"}}},
+			want:       WorkspaceSettingsT{DataRetention: DataRetentionT{Days: 7}, EventFiltering: EventFilteringT{AllowedEventTypes: []string{}}},
+			wantIssues: 1,
+		},
+		{
+			name:       "bucket without a name",
+			settings:   WorkspaceSettingsT{DataRetention: DataRetentionT{Days: 7}, StorageBucket: &StorageBucketT{Type: "S3"}},
+			want:       WorkspaceSettingsT{DataRetention: DataRetentionT{Days: 7}},
+			wantIssues: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if issues := ValidateWorkspaceSettings(tt.settings, "settings"); len(issues) != tt.wantIssues {
+				t.Errorf("got issues %v, want %d", issues, tt.wantIssues)
+			}
+			got := tt.settings.withDefaults()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if issues := ValidateWorkspaceSettings(got, "settings"); len(issues) != 0 {
+				t.Errorf("settings with defaults are invalid: %v", issues)
+			}
+			// unknown event types don't reject the events of the known ones, nor every event when no known one is left
+			if !got.EventAllowed("identify", "") {
+				t.Error("identify events rejected")
+			}
+		})
+	}
+}
+
+func TestWorkspaceSettingsPublishedWithConfig(t *testing.T) {
+	tests := []struct {
+		name             string
+		isMultiWorkspace bool
+	}{
+		{name: "single workspace"},
+		{name: "multi workspace", isMultiWorkspace: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testSourcesConfig("destination-1")
+			config.Settings.DataRetention.Days = 7
+			backend := newTestConfigBackend(t, config)
+			instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+				setup.IsMultiWorkspace = tt.isMultiWorkspace
+				setup.TransformationCacheDir = t.TempDir()
+			})
+			if changed, ok := instance.configUpdate(testStatConfigBackendError); !changed || !ok {
+				t.Fatalf("first config not published: changed %t, ok %t", changed, ok)
+			}
+			if days := instance.GetWorkspaceSettingsForWorkspaceID("workspace-1").DataRetention.Days; days != 7 {
+				t.Fatalf("got retention of %d days, want 7", days)
+			}
+
+			// the next config is held back while its transformation can't be fetched, and its settings with it
+			config.Settings.DataRetention.Days = 14
+			config.Sources[0].Destinations[0].Transformations = []TransformationT{{VersionID: "transformation-1"}}
+			backend.setConfig(config)
+			backend.setCodeStatusCode(http.StatusInternalServerError)
+			if changed, ok := instance.configUpdate(testStatConfigBackendError); changed || ok {
+				t.Fatalf("config published although its transformation couldn't be fetched: changed %t, ok %t", changed, ok)
+			}
+			if days := instance.GetWorkspaceSettingsForWorkspaceID("workspace-1").DataRetention.Days; days != 7 {
+				t.Errorf("got retention of %d days while the config is held back, want 7", days)
+			}
+
+			backend.setCodeStatusCode(0)
+			backend.setCode("transformation-1", `{"code": "1"}`)
+			if changed, ok := instance.configUpdate(testStatConfigBackendError); !changed || !ok {
+				t.Fatalf("config not published: changed %t, ok %t", changed, ok)
+			}
+			if days := instance.GetWorkspaceSettingsForWorkspaceID("workspace-1").DataRetention.Days; days != 14 {
+				t.Errorf("got retention of %d days once the config is published, want 14", days)
+			}
+		})
+	}
+}
+
+func TestWorkspaceLibrariesAndWriteKeysPublishedWithConfig(t *testing.T) {
+	tests := []struct {
+		name             string
+		isMultiWorkspace bool
+	}{
+		{name: "single workspace"},
+		{name: "multi workspace", isMultiWorkspace: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testSourcesConfig("destination-1")
+			config.Libraries = LibrariesT{{VersionID: "library-1"}}
+			backend := newTestConfigBackend(t, config)
+			instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+				setup.IsMultiWorkspace = tt.isMultiWorkspace
+				setup.TransformationCacheDir = t.TempDir()
+			})
+			backend.setCode("library-1", `{"code": "1"}`)
+			if changed, ok := instance.configUpdate(testStatConfigBackendError); !changed || !ok {
+				t.Fatalf("first config not published: changed %t, ok %t", changed, ok)
+			}
+
+			// the next config of another workspace is held back while its transformation can't be fetched, and its libraries and write keys with it
+			config.WorkspaceID = "workspace-2"
+			config.Sources[0].WorkspaceID = "workspace-2"
+			config.Sources[0].Destinations[0].Transformations = []TransformationT{{VersionID: "transformation-1"}}
+			config.Libraries = LibrariesT{{VersionID: "library-2"}}
+			backend.setConfig(config)
+			backend.setCodeStatusCode(http.StatusInternalServerError)
+			if changed, ok := instance.configUpdate(testStatConfigBackendError); changed || ok {
+				t.Fatalf("config published although its transformation couldn't be fetched: changed %t, ok %t", changed, ok)
+			}
+			if got := instance.Provider().GetWorkspaceIDForWriteKey("write-key-destination-1"); got != "workspace-1" {
+				t.Errorf("got workspace %q for the write key while the config is held back, want workspace-1", got)
+			}
+			if libraries := instance.GetWorkspaceLibrariesForWorkspaceID("workspace-1"); len(libraries) != 1 || libraries[0].VersionID != "library-1" {
+				t.Errorf("got libraries %v while the config is held back, want library-1", libraries)
+			}
+			if libraries := instance.GetWorkspaceLibrariesForWorkspaceID("workspace-2"); len(libraries) != 0 {
+				t.Errorf("got libraries %v of the held back workspace", libraries)
+			}
+
+			backend.setCodeStatusCode(0)
+			backend.setCode("transformation-1", `{"code": "1"}`)
+			backend.setCode("library-2", `{"code": "2"}`)
+			if changed, ok := instance.configUpdate(testStatConfigBackendError); !changed || !ok {
+				t.Fatalf("config not published: changed %t, ok %t", changed, ok)
+			}
+			if got := instance.Provider().GetWorkspaceIDForWriteKey("write-key-destination-1"); got != "workspace-2" {
+				t.Errorf("got workspace %q for the write key once the config is published, want workspace-2", got)
+			}
+			if libraries := instance.GetWorkspaceLibrariesForWorkspaceID("workspace-2"); len(libraries) != 1 || libraries[0].VersionID != "library-2" {
+				t.Errorf("got libraries %v once the config is published, want library-2", libraries)
+			}
+		})
+	}
+}
+
+func TestWorkspaceSettingsPublishedWithUnchangedConfig(t *testing.T) {
+	config := testSourcesConfig("destination-1")
+	config.Settings.DataRetention.Days = 7
+	backend := newTestConfigBackend(t, config)
+	instance := newTestInstance(t, backend, func(setup *BackendConfigSetup) {
+		setup.IsMultiWorkspace = true
+	})
+	if changed, ok := instance.configUpdate(testStatConfigBackendError); !changed || !ok {
+		t.Fatalf("first config not published: changed %t, ok %t", changed, ok)
+	}
+
+	// hosted workspace settings aren't part of the published config, changing them alone still makes them available
+	config.Settings.DataRetention.Days = 14
+	backend.setConfig(config)
+	if changed, ok := instance.configUpdate(testStatConfigBackendError); changed || !ok {
+		t.Fatalf("got changed %t, ok %t, want an unchanged config", changed, ok)
+	}
+	if days := instance.GetWorkspaceSettingsForWorkspaceID("workspace-1").DataRetention.Days; days != 14 {
+		t.Errorf("got retention of %d days, want 14", days)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+export-regulation-audit reports the regulation lifecycle events logged to RegulationAuditLogPath, for GDPR and CCPA audits.
+
+	export-regulation-audit -file /var/lib/rudderstack/regulation-audit.log [-from 2021-01-01] [-to 2021-03-31] [-format csv|json] [-output report.csv]
+
+-from and -to take a date or an RFC 3339 time. A date given to -to includes the whole day, a time is excluded.
+Events can be limited to a workspace, a source or a user with -workspace, -source and -user.
+*/
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	backendconfig "github.com/rudderlabs/rudder-platform/backend-config"
+)
+
+const dateLayout = "2006-01-02"
+
+var csvHeader = []string{"time", "event", "regulation_id", "regulation_type", "workspace_id", "source_id", "user_id"}
+
+func main() {
+	file := flag.String("file", "", "regulation audit log to export")
+	fromFlag := flag.String("from", "", "export events logged at or after this date or time")
+	toFlag := flag.String("to", "", "export events logged up to this date, or before this time")
+	format := flag.String("format", "csv", "report format, csv or json")
+	outputPath := flag.String("output", "", "file to write the report to, standard output by default")
+	workspaceID := flag.String("workspace", "", "only export events of this workspace")
+	sourceID := flag.String("source", "", "only export events of this source")
+	userID := flag.String("user", "", "only export events of this user")
+	flag.Parse()
+
+	if *file == "" {
+		exit(fmt.Errorf("-file is required"))
+	}
+	if *format != "csv" && *format != "json" {
+		exit(fmt.Errorf("unknown format %q, use csv or json", *format))
+	}
+	from, err := parseTime(*fromFlag, false)
+	if err != nil {
+		exit(err)
+	}
+	to, err := parseTime(*toFlag, true)
+	if err != nil {
+		exit(err)
+	}
+
+	var output io.Writer = os.Stdout
+	if *outputPath != "" {
+		outputFile, err := os.OpenFile(*outputPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			exit(err)
+		}
+		defer outputFile.Close()
+		output = outputFile
+	}
+
+	events := make([]backendconfig.RegulationAuditEventT, 0)
+	err = backendconfig.ReadRegulationAuditLog(*file, from, to, func(event backendconfig.RegulationAuditEventT) error {
+		if (*workspaceID == "" || event.WorkspaceID == *workspaceID) && (*sourceID == "" || event.SourceID == *sourceID) && (*userID == "" || event.UserID == *userID) {
+			events = append(events, event)
+		}
+		return nil
+	})
+	if err != nil {
+		exit(err)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(events)
+	} else {
+		err = writeCSV(output, events)
+	}
+	if err != nil {
+		exit(err)
+	}
+}
+
+func writeCSV(output io.Writer, events []backendconfig.RegulationAuditEventT) error {
+	writer := csv.NewWriter(output)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, event := range events {
+		record := []string{event.Time.UTC().Format(time.RFC3339Nano), event.Event, event.RegulationID, event.RegulationType, event.WorkspaceID, event.SourceID, event.UserID}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// parseTime parses a date or an RFC 3339 time. A date ending the range is moved to the start of the next day to include it.
+func parseTime(value string, endOfRange bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse(dateLayout, value); err == nil {
+		if endOfRange {
+			return date.AddDate(0, 0, 1), nil
+		}
+		return date, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date or time %q, use %s or RFC 3339", value, dateLayout)
+	}
+	return parsed, nil
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	os.Exit(1)
+}
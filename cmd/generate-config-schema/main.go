@@ -0,0 +1,39 @@
+/*
+generate-config-schema writes the JSON Schema of the workspace config served by the config backend.
+
+	generate-config-schema [-output workspace-config.schema.json]
+
+It is run by go generate in backend-config, whenever ConfigT or the types it contains change.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	backendconfig "github.com/rudderlabs/rudder-platform/backend-config"
+)
+
+func main() {
+	output := flag.String("output", "", "file to write the schema to, stdout by default")
+	flag.Parse()
+
+	schema, err := json.MarshalIndent(backendconfig.GenerateConfigSchema(), "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	schema = append(schema, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err = ioutil.WriteFile(*output, schema, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+validate-workspace-config checks a workspaceConfig.json file before it is used with ConfigFromFile.
+
+	validate-workspace-config [-file /etc/rudderstack/workspaceConfig.json] [-diff previous.json] [-json]
+
+The file is loaded the way the server loads it, then decoded strictly so that fields the server would silently ignore,
+and required fields that are missing, are reported with their line and column. Referential integrity is checked next, e.g. duplicate write keys or destinations without a definition.
+With -diff, the sources and destinations added, removed or changed since another config file or a saved snapshot are listed.
+The exit code is 1 if any problem is found.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	backendconfig "github.com/rudderlabs/rudder-platform/backend-config"
+)
+
+type report struct {
+	File   string                           `json:"file"`
+	Issues []backendconfig.ValidationIssueT `json:"issues"`
+	Diff   []backendconfig.ConfigDiffT      `json:"diff,omitempty"`
+}
+
+func main() {
+	file := flag.String("file", backendconfig.DefaultBackendConfigSetup.ConfigJSONPath, "workspace config file to validate")
+	diffAgainst := flag.String("diff", "", "config file or snapshot to diff the workspace config file against")
+	jsonOutput := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	config, data, err := backendconfig.LoadConfigFile(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	result := report{File: *file}
+	result.Issues, err = backendconfig.CheckConfigFields(data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	result.Issues = append(result.Issues, backendconfig.ValidateConfig(config)...)
+
+	if *diffAgainst != "" {
+		previousConfig, _, err := backendconfig.LoadConfigFile(*diffAgainst)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		result.Diff = backendconfig.DiffConfigs(previousConfig, config)
+	}
+
+	if *jsonOutput {
+		output, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(output))
+	} else {
+		printReport(result)
+	}
+
+	if len(result.Issues) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printReport(result report) {
+	for _, issue := range result.Issues {
+		fmt.Printf("%s:%s\n", result.File, issue.String())
+	}
+	for _, diff := range result.Diff {
+		if diff.Name == "" {
+			fmt.Printf("%s %s %s\n", diff.Change, diff.Kind, diff.ID)
+		} else {
+			fmt.Printf("%s %s %s (%s)\n", diff.Change, diff.Kind, diff.ID, diff.Name)
+		}
+	}
+	if len(result.Issues) == 0 {
+		fmt.Printf("%s: OK\n", result.File)
+	}
+}